@@ -1,41 +1,138 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/timotiusas11/amartha-assignment/common/driver/blob"
+	httpdriver "github.com/timotiusas11/amartha-assignment/common/driver/http"
+	"github.com/timotiusas11/amartha-assignment/common/driver/inmemlib"
+	"github.com/timotiusas11/amartha-assignment/common/driver/nsq"
+	"github.com/timotiusas11/amartha-assignment/common/health"
+	"github.com/timotiusas11/amartha-assignment/common/logger"
+	"github.com/timotiusas11/amartha-assignment/common/metrics"
+	"github.com/timotiusas11/amartha-assignment/common/middleware"
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/config"
 	"github.com/timotiusas11/amartha-assignment/internal/delivery"
 	"github.com/timotiusas11/amartha-assignment/internal/repository"
 	"github.com/timotiusas11/amartha-assignment/internal/usecase"
 )
 
 type application struct {
+	config       config.Config
 	router       *http.ServeMux
+	tracer       *tracing.Provider
+	health       *health.Registry
+	idempotency  inmemlib.InMemLib
+	nsqClient    nsq.NSQ
 	deliveries   delivery.Delivery
 	usecases     usecase.Usecase
 	repositories repository.Repository
 }
 
-func newApplication() *application {
+func newApplication(cfg config.Config) *application {
 	return &application{
+		config: cfg,
 		router: http.NewServeMux(),
+		health: health.New(cfg.Server.HealthCheckTimeout.Duration()),
 	}
 }
 
-func (a *application) repository() *application {
-	a.repositories = repository.NewRepository()
+func (a *application) tracing() *application {
+	var exporter tracing.Exporter
+	switch a.config.Trace.Exporter {
+	case "stdout":
+		exporter = tracing.NewStdoutExporter(os.Stdout)
+	case "otlp":
+		exporter = tracing.NewOTLPExporter(a.config.Trace.OTLPEndpoint, a.config.Trace.ServiceName)
+	}
+
+	// Spans are still created and propagated without an exporter, only not exported
+	a.tracer = tracing.NewProvider(exporter)
+	tracing.SetProvider(a.tracer)
 	return a
 }
 
+func (a *application) repository() (*application, error) {
+	inmemlibClient := inmemlib.New(inmemlib.WithSize(a.config.Cache.SizeMB * inmemlib.MB))
+	inmemlib.RegisterMetrics(metrics.Default, inmemlibClient)
+
+	// Stored responses must not evict the loans, nor the loans the responses
+	a.idempotency = inmemlib.New(inmemlib.WithSize(a.config.Cache.IdempotencySizeMB * inmemlib.MB))
+
+	a.nsqClient = nsq.New(a.config.NSQ.Addr, a.config.NSQ.OutboxSize)
+	httpClient := httpdriver.New(a.config.HTTP.BaseURL, a.config.HTTP.Timeout.Duration())
+
+	// Readiness probes of the dependencies
+	a.health.Register("storage", inmemlibClient.Ping)
+	a.health.Register("nsq", a.nsqClient.Ping)
+	if a.config.HTTP.BaseURL != "" {
+		a.health.Register("downstream_http", httpClient.Ping)
+	}
+
+	// Uploaded documents, only the local directory driver exists for now
+	blobStore, err := blob.NewLocal(a.config.Blob.Dir)
+	if err != nil {
+		return nil, err
+	}
+	a.health.Register("blob", blobStore.Ping)
+
+	a.repositories = repository.NewRepository(inmemlibClient, a.nsqClient, httpClient, blobStore)
+	return a, nil
+}
+
 func (a *application) usecase() *application {
-	a.usecases = usecase.NewUsecase(a.repositories)
+	a.usecases = usecase.NewUsecase(a.repositories, usecase.Limits{
+		MinPrincipalAmount:  a.config.Limits.MinPrincipalAmount,
+		MaxPrincipalAmount:  a.config.Limits.MaxPrincipalAmount,
+		MaxRate:             a.config.Limits.MaxRate,
+		MaxROI:              a.config.Limits.MaxROI,
+		MinInvestmentAmount: a.config.Limits.MinInvestmentAmount,
+		MaxDocumentSize:     int64(a.config.Blob.MaxSizeMB) << 20,
+		FundingPeriod:       a.config.Limits.FundingPeriod.Duration(),
+	}, usecase.FeeRules{
+		Default:  a.config.Fees.Terms(),
+		Products: a.config.Fees.ProductTerms(),
+	})
 	return a
 }
 
-func (a *application) delivery() *application {
+func (a *application) delivery() (*application, error) {
 	a.deliveries = delivery.NewDelivery(a.usecases)
 
-	// Health check
+	verifier, err := auth.NewVerifier(a.config.Auth.Keys, a.config.Auth.Issuer, a.config.Auth.Audience)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token verifier: %w", err)
+	}
+	// Retried POST requests with the same Idempotency-Key get the first response,
+	// keys are per caller. Bodies may be as large as a document upload, the
+	// largest document plus 1 MiB for the rest of the multipart form
+	maxBodyBytes := int64(a.config.Blob.MaxSizeMB+1) << 20
+	idempotent := middleware.Idempotency(a.idempotency, a.config.Server.IdempotencyTTL.Duration(), maxBodyBytes, func(r *http.Request) string {
+		principal, _ := auth.PrincipalFromContext(r.Context())
+		return strconv.FormatInt(principal.ActorID, 10)
+	})
+	authorized := func(permission auth.Permission, handler http.HandlerFunc) http.Handler {
+		return auth.Authenticate(verifier)(auth.Require(permission)(idempotent(handler)))
+	}
+
+	// Liveness and readiness probes
+	a.router.Handle("/livez", a.health.LivenessHandler())
+	a.router.Handle("/readyz", a.health.ReadinessHandler())
+
+	// Health check, kept for existing callers, prefer /livez and /readyz
 	a.router.HandleFunc("/healthcheck", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Content-Type", "application/json")
@@ -43,23 +140,104 @@ func (a *application) delivery() *application {
 		w.Write([]byte(`{"message": "Healthy"}`))
 	})
 
-	a.router.HandleFunc("/loans", a.deliveries.Loan)
-	a.router.HandleFunc("/loans/{loan_id}", a.deliveries.GetLoan)
-	a.router.HandleFunc("/loans/{loan_id}/approve", a.deliveries.Approve)
-	a.router.HandleFunc("/loans/{loan_id}/invest", a.deliveries.Invest)
-	a.router.HandleFunc("/loans/{loan_id}/disburse", a.deliveries.Disburse)
+	// Prometheus metrics
+	a.router.Handle("/metrics", metrics.Default.Handler())
 
-	// For admin only
-	a.router.HandleFunc("/admin/view/loans", a.deliveries.AdminViewLoans)
+	// Loan API
+	a.deliveries.Register(a.router, authorized)
 
-	return a
+	return a, nil
 }
 
-func (a *application) serve() {
-	fmt.Println("Server started at localhost:8080")
-	http.ListenAndServe(":8080", a.router)
+// serve runs the HTTP server until ctx is cancelled, then drains in-flight
+// requests and flushes pending NSQ messages within the shutdown timeout.
+func (a *application) serve(ctx context.Context) error {
+	server := &http.Server{
+		Addr: a.config.Server.Addr,
+		Handler: middleware.Chain(a.router,
+			middleware.RequestID,
+			middleware.Tracing(a.router),
+			middleware.AccessLog,
+			middleware.Metrics(a.router),
+			middleware.Deadline(a.config.Server.RequestTimeout.Duration()),
+		),
+		ReadTimeout:       a.config.Server.ReadTimeout.Duration(),
+		ReadHeaderTimeout: a.config.Server.ReadHeaderTimeout.Duration(),
+		WriteTimeout:      a.config.Server.WriteTimeout.Duration(),
+		IdleTimeout:       a.config.Server.IdleTimeout.Duration(),
+		MaxHeaderBytes:    a.config.Server.MaxHeaderBytes,
+	}
+
+	// Listen before serving so that startup failures are reported to the caller
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", server.Addr, err)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+	slog.Info("server started", "addr", listener.Addr().String())
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("server stopped unexpectedly: %w", err)
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down server", "drain_delay", a.config.Server.DrainDelay.String(), "timeout", a.config.Server.ShutdownTimeout.String())
+	a.health.SetShuttingDown()
+
+	// Keep serving while load balancers see the failing readiness probe and
+	// stop routing new requests here
+	time.Sleep(a.config.Server.DrainDelay.Duration())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.config.Server.ShutdownTimeout.Duration())
+	defer cancel()
+
+	// Stop accepting connections and wait for in-flight requests
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		err = fmt.Errorf("failed to drain in-flight requests: %w", err)
+	}
+
+	// Flush messages queued by the drained requests, then the spans they produced
+	return errors.Join(err, a.nsqClient.Stop(shutdownCtx), a.tracer.Shutdown(shutdownCtx))
+}
+
+func run() error {
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	log, err := logger.New(os.Stdout, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(log)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	app, err := newApplication(cfg).tracing().repository()
+	if err != nil {
+		return err
+	}
+	app, err = app.usecase().delivery()
+	if err != nil {
+		return err
+	}
+	return app.serve(ctx)
 }
 
 func main() {
-	newApplication().repository().usecase().delivery().serve()
+	if err := run(); err != nil {
+		slog.Error("loan service failed", "error", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/common/driver/nsq"
+	"github.com/timotiusas11/amartha-assignment/internal/config"
+)
+
+func TestServeDrainDelay(t *testing.T) {
+	tests := []struct {
+		name       string
+		drainDelay time.Duration
+	}{
+		{name: "no delay"},
+		{name: "delay", drainDelay: 300 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Borrow a free port, serve listens on the configured address
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			addr := listener.Addr().String()
+			listener.Close()
+
+			cfg := config.Default()
+			cfg.Server.Addr = addr
+			cfg.Server.DrainDelay = config.Duration(tt.drainDelay)
+			app := newApplication(cfg).tracing()
+			app.nsqClient = nsq.New(cfg.NSQ.Addr, 1)
+			app.router.Handle("/readyz", app.health.ReadinessHandler())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			served := make(chan error, 1)
+			go func() { served <- app.serve(ctx) }()
+
+			url := "http://" + addr + "/readyz"
+			if got := waitStatus(t, url); got != http.StatusOK {
+				t.Fatalf("readiness before shutdown = %d, want %d", got, http.StatusOK)
+			}
+
+			start := time.Now()
+			cancel()
+
+			// The listener stays open during the delay, answering that the
+			// service is no longer ready
+			if tt.drainDelay > 0 {
+				time.Sleep(tt.drainDelay / 3)
+				if got := status(url); got != http.StatusServiceUnavailable {
+					t.Errorf("readiness while draining = %d, want %d", got, http.StatusServiceUnavailable)
+				}
+			}
+
+			select {
+			case err := <-served:
+				if err != nil {
+					t.Fatalf("serve() error = %v", err)
+				}
+			case <-time.After(tt.drainDelay + 5*time.Second):
+				t.Fatal("serve() did not return")
+			}
+			if elapsed := time.Since(start); elapsed < tt.drainDelay {
+				t.Errorf("serve() returned after %v, before the %v drain delay", elapsed, tt.drainDelay)
+			}
+			if got := status(url); got != 0 {
+				t.Errorf("readiness after shutdown = %d, want the connection refused", got)
+			}
+		})
+	}
+}
+
+// status returns the status code of a GET on url, 0 when the request fails.
+func status(url string) int {
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// waitStatus polls url until the server answers.
+func waitStatus(t *testing.T, url string) int {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if got := status(url); got != 0 {
+			return got
+		}
+	}
+	t.Fatalf("server at %s did not start", url)
+	return 0
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+)
+
+// tokens mints a bearer token per actor and roles, once.
+type tokens struct {
+	opts   options
+	mu     sync.Mutex
+	issued map[string]string
+}
+
+func newTokens(opts options) (*tokens, error) {
+	if len(opts.secret) < auth.MinKeyLength {
+		return nil, fmt.Errorf("-secret or LOAN_AUTH_SECRET must be at least %d bytes", auth.MinKeyLength)
+	}
+	return &tokens{opts: opts, issued: map[string]string{}}, nil
+}
+
+func (t *tokens) token(actor int64, roles []string) (string, error) {
+	key := strconv.FormatInt(actor, 10) + ":" + strings.Join(roles, ",")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if token, ok := t.issued[key]; ok {
+		return token, nil
+	}
+
+	// Long enough to outlive any run
+	now := time.Now()
+	claims := auth.Claims{
+		Subject:   strconv.FormatInt(actor, 10),
+		Issuer:    t.opts.issuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(24 * time.Hour).Unix(),
+		Roles:     roles,
+	}
+	if t.opts.audience != "" {
+		claims.Audience = auth.Audience{t.opts.audience}
+	}
+	token, err := auth.Sign(t.opts.kid, t.opts.secret, claims)
+	if err != nil {
+		return "", err
+	}
+	t.issued[key] = token
+	return token, nil
+}
+
+// client sends the requests, holding back to the concurrency and rate limits.
+type client struct {
+	baseURL string
+	timeout time.Duration
+	http    *http.Client
+	tokens  *tokens
+	slots   chan struct{}
+	limiter *limiter
+}
+
+func newClient(opts options, tokens *tokens) *client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = opts.concurrency
+	return &client{
+		baseURL: strings.TrimSuffix(opts.baseURL, "/"),
+		timeout: opts.timeout,
+		http:    &http.Client{Transport: transport},
+		tokens:  tokens,
+		slots:   make(chan struct{}, opts.concurrency),
+		limiter: newLimiter(opts.rate),
+	}
+}
+
+// response is what the report needs of an answer.
+type response struct {
+	status   int
+	location string
+	body     []byte
+	latency  time.Duration
+}
+
+// do sends a request and reads the whole answer. The latency excludes the time
+// spent waiting for a slot.
+func (c *client) do(ctx context.Context, method, path string, actor int64, roles []string, headers map[string]string, body string) (response, error) {
+	select {
+	case c.slots <- struct{}{}:
+		defer func() { <-c.slots }()
+	case <-ctx.Done():
+		return response{}, ctx.Err()
+	}
+	if err := c.limiter.wait(ctx); err != nil {
+		return response{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, strings.NewReader(body))
+	if err != nil {
+		return response{}, err
+	}
+	if actor != 0 {
+		token, err := c.tokens.token(actor, roles)
+		if err != nil {
+			return response{}, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	started := time.Now()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return response{}, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return response{}, err
+	}
+	return response{
+		status:   resp.StatusCode,
+		location: resp.Header.Get("Location"),
+		body:     data,
+		latency:  time.Since(started),
+	}, nil
+}
+
+// getJSON decodes the 200 response of a GET request into v.
+func (c *client) getJSON(ctx context.Context, path string, actor int64, roles []string, v interface{}) error {
+	resp, err := c.do(ctx, http.MethodGet, path, actor, roles, nil, "")
+	if err != nil {
+		return err
+	}
+	if resp.status != http.StatusOK {
+		return fmt.Errorf("GET %s: %d: %s", path, resp.status, strings.TrimSpace(string(resp.body)))
+	}
+	return json.Unmarshal(resp.body, v)
+}
+
+// limiter spaces requests evenly to stay under a rate.
+type limiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	next     time.Time
+}
+
+func newLimiter(rate float64) *limiter {
+	if rate <= 0 {
+		return &limiter{}
+	}
+	return &limiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+func (l *limiter) wait(ctx context.Context) error {
+	if l.interval == 0 {
+		return nil
+	}
+
+	// Reserve the next free slot, then sleep until it comes
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	at := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,148 @@
+// Command loadgen replays a JSONL file of API requests against the loan
+// service, then reports latency percentiles, status codes and whether the loan
+// book still holds its invariants, e.g. that no loan is overfunded.
+//
+//	loadgen -f cmd/loadgen/testdata/sample.jsonl -iterations 50 -concurrency 32
+//	loadgen -url http://localhost:8080 -kid dev -secret "$SECRET" -rate 200 -f requests.jsonl
+//
+// Without -url the requests go to an in-process server wired like the real
+// one. The exit code is 1 when a request got an unexpected status or an
+// invariant is broken. See the README for the file format.
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/internal/config"
+)
+
+// options are the command line flags.
+type options struct {
+	file        string
+	baseURL     string
+	kid         string
+	secret      string
+	issuer      string
+	audience    string
+	iterations  int
+	concurrency int
+	rate        float64
+	timeout     time.Duration
+	adminID     int64
+	cacheMB     int
+	output      string
+	verbose     bool
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	ok, err := run(ctx, os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "loadgen:", err)
+		os.Exit(2)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// run returns false when the run completed but found problems.
+func run(ctx context.Context, args []string) (bool, error) {
+	opts := options{}
+	flags := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	flags.StringVar(&opts.file, "f", "cmd/loadgen/testdata/sample.jsonl", "JSONL file of requests, - for stdin")
+	flags.StringVar(&opts.baseURL, "url", "", "base URL of the loan service, empty for an in-process server")
+	flags.StringVar(&opts.kid, "kid", "loadgen", "key ID of the signing key")
+	flags.StringVar(&opts.secret, "secret", os.Getenv("LOAN_AUTH_SECRET"), "signing secret (env LOAN_AUTH_SECRET), random for the in-process server")
+	flags.StringVar(&opts.issuer, "iss", "", "issuer claim of the tokens")
+	flags.StringVar(&opts.audience, "aud", "", "audience claim of the tokens")
+	flags.IntVar(&opts.iterations, "iterations", 1, "number of copies of the file replayed side by side")
+	flags.IntVar(&opts.concurrency, "concurrency", 16, "maximum number of requests in flight")
+	flags.Float64Var(&opts.rate, "rate", 0, "maximum requests per second, 0 for no limit")
+	flags.DurationVar(&opts.timeout, "timeout", 10*time.Second, "timeout of each request")
+	flags.Int64Var(&opts.adminID, "admin", 1, "actor ID of the admin reading the loan book for the invariant checks")
+	flags.IntVar(&opts.cacheMB, "cache-mb", config.Default().Cache.SizeMB, "cache size of the in-process server in MB, the server default unless given")
+	flags.StringVar(&opts.output, "o", "table", "output format: table or json")
+	flags.BoolVar(&opts.verbose, "v", false, "log the in-process server to stderr")
+	if err := flags.Parse(args); err != nil {
+		return false, err
+	}
+	if opts.iterations < 1 || opts.concurrency < 1 || opts.rate < 0 {
+		return false, errors.New("-iterations and -concurrency must be positive and -rate not negative")
+	}
+	if opts.output != "table" && opts.output != "json" {
+		return false, fmt.Errorf("-o must be table or json, got %q", opts.output)
+	}
+
+	scenario, err := loadScenario(opts.file)
+	if err != nil {
+		return false, err
+	}
+
+	if !opts.verbose {
+		slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}
+	if opts.baseURL == "" {
+		if opts.secret == "" {
+			opts.secret = randomSecret()
+		}
+		blobDir, err := os.MkdirTemp("", "loadgen-blobs-")
+		if err != nil {
+			return false, err
+		}
+		defer os.RemoveAll(blobDir)
+		server, err := startServer(opts, blobDir)
+		if err != nil {
+			return false, err
+		}
+		defer server.Close()
+		opts.baseURL = server.URL
+	}
+
+	tokens, err := newTokens(opts)
+	if err != nil {
+		return false, err
+	}
+	client := newClient(opts, tokens)
+
+	started := time.Now()
+	results := replay(ctx, client, scenario, opts)
+	report := newReport(results, time.Since(started))
+
+	// Check the loan book once every request is answered
+	report.Violations, err = checkInvariants(ctx, client, opts.adminID, results)
+	if err != nil {
+		return false, fmt.Errorf("failed to check invariants: %w", err)
+	}
+
+	if opts.output == "json" {
+		err = printJSON(report)
+	} else {
+		err = printReport(report)
+	}
+	if err != nil {
+		return false, err
+	}
+	return report.OK(), nil
+}
+
+func randomSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// result is the outcome of one request sent.
+type result struct {
+	iteration int
+	line      int
+	route     string
+	status    int // 0 when no response was received
+	latency   time.Duration
+	err       error  // Transport error, or the reason the request was skipped
+	skipped   bool   // A reference could not be resolved
+	expected  bool   // The status is one the file accepts
+	body      string // Kept for unexpected statuses
+	captured  string // Last segment of the Location header, for requests with as
+	loanID    int64  // Set when the Location header is a loan
+}
+
+// replay runs every iteration of the scenario side by side and returns the
+// result of each request.
+func replay(ctx context.Context, c *client, scenario []step, opts options) []result {
+	var (
+		mu      sync.Mutex
+		results []result
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < opts.iterations; i++ {
+		wg.Add(1)
+		go func(iteration int) {
+			defer wg.Done()
+			r := replayIteration(ctx, c, scenario, iteration)
+			mu.Lock()
+			results = append(results, r...)
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// replayIteration runs the steps in order, each one after the previous step is
+// fully answered so that its captures can be referenced.
+func replayIteration(ctx context.Context, c *client, scenario []step, iteration int) []result {
+	refs := map[string]string{}
+	var results []result
+	for _, s := range scenario {
+		stepResults := make([][]result, len(s.requests))
+		var wg sync.WaitGroup
+		for i, req := range s.requests {
+			stepResults[i] = make([]result, req.Repeat)
+			for n := 0; n < req.Repeat; n++ {
+				wg.Add(1)
+				go func(i, n int, req request) {
+					defer wg.Done()
+					stepResults[i][n] = send(ctx, c, req, refs, iteration)
+				}(i, n, req)
+			}
+		}
+		wg.Wait()
+
+		// Captures are only visible to the next steps
+		for i, req := range s.requests {
+			for _, r := range stepResults[i] {
+				if req.As != "" && r.captured != "" {
+					refs[req.As] = r.captured
+				}
+				results = append(results, r)
+			}
+		}
+	}
+	return results
+}
+
+func send(ctx context.Context, c *client, req request, refs map[string]string, iteration int) result {
+	r := result{iteration: iteration, line: req.line, route: req.route()}
+
+	// A failed capture leaves nothing to reference, the request is pointless
+	for _, ref := range req.refs() {
+		if refs[ref] == "" {
+			r.skipped = true
+			r.err = errMissingRef(ref)
+			return r
+		}
+	}
+
+	body, contentType, err := req.body(refs)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	headers := map[string]string{}
+	if body != "" {
+		headers["Content-Type"] = contentType
+	}
+	for k, v := range req.Headers {
+		headers[k] = expand(v, refs)
+	}
+	resp, err := c.do(ctx, req.Method, expand(req.Path, refs), req.Actor, req.Roles, headers, body)
+	if err != nil {
+		r.err = err
+		return r
+	}
+
+	r.status = resp.status
+	r.latency = resp.latency
+	r.expected = req.expected(resp.status)
+	if !r.expected {
+		r.body = strings.TrimSpace(string(resp.body))
+	}
+	if req.As != "" && resp.location != "" {
+		r.captured = resp.location[strings.LastIndex(resp.location, "/")+1:]
+		r.loanID = loanIDFromLocation(resp.location)
+	}
+	return r
+}
+
+// loanIDFromLocation parses the loan ID of a /loans/{loan_id} Location header.
+func loanIDFromLocation(location string) int64 {
+	id, err := strconv.ParseInt(strings.TrimPrefix(location, "/loans/"), 10, 64)
+	if err != nil || !strings.HasPrefix(location, "/loans/") {
+		return 0
+	}
+	return id
+}
+
+type errMissingRef string
+
+func (e errMissingRef) Error() string {
+	return "$" + string(e) + " was not captured"
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/internal/ledger"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+// maxSamples caps the unexpected responses listed in the report.
+const maxSamples = 10
+
+type report struct {
+	Requests   int          `json:"requests"`
+	Errors     int          `json:"errors"`     // Transport errors and skipped requests
+	Unexpected int          `json:"unexpected"` // Responses with a status the file does not accept
+	DurationMS int64        `json:"duration_ms"`
+	Throughput float64      `json:"throughput"` // Responses per second
+	Routes     []routeStats `json:"routes"`
+	Samples    []sample     `json:"samples,omitempty"`
+	Violations []string     `json:"violations"`
+}
+
+type routeStats struct {
+	Route      string         `json:"route"`
+	Count      int            `json:"count"`
+	Statuses   map[string]int `json:"statuses"`
+	Errors     int            `json:"errors"`
+	Unexpected int            `json:"unexpected"`
+	P50MS      float64        `json:"p50_ms"`
+	P90MS      float64        `json:"p90_ms"`
+	P95MS      float64        `json:"p95_ms"`
+	P99MS      float64        `json:"p99_ms"`
+	MaxMS      float64        `json:"max_ms"`
+}
+
+// sample is a request that did not go as the file expects.
+type sample struct {
+	Line      int    `json:"line"`
+	Iteration int    `json:"iteration"`
+	Route     string `json:"route"`
+	Status    int    `json:"status,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Body      string `json:"body,omitempty"`
+}
+
+// OK reports whether every request went as expected and the loan book is sound.
+func (r report) OK() bool {
+	return r.Errors == 0 && r.Unexpected == 0 && len(r.Violations) == 0
+}
+
+func newReport(results []result, elapsed time.Duration) report {
+	r := report{
+		Requests:   len(results),
+		DurationMS: elapsed.Milliseconds(),
+		Violations: []string{},
+	}
+
+	byRoute := map[string][]result{}
+	for _, res := range results {
+		byRoute[res.route] = append(byRoute[res.route], res)
+	}
+	answered := 0
+	for route, rs := range byRoute {
+		stats := routeStats{Route: route, Count: len(rs), Statuses: map[string]int{}}
+		var latencies []time.Duration
+		for _, res := range rs {
+			switch {
+			case res.err != nil:
+				stats.Errors++
+				r.addSample(res)
+				continue
+			case !res.expected:
+				stats.Unexpected++
+				r.addSample(res)
+			}
+			stats.Statuses[strconv.Itoa(res.status)]++
+			latencies = append(latencies, res.latency)
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		stats.P50MS = percentile(latencies, 50)
+		stats.P90MS = percentile(latencies, 90)
+		stats.P95MS = percentile(latencies, 95)
+		stats.P99MS = percentile(latencies, 99)
+		stats.MaxMS = percentile(latencies, 100)
+
+		answered += len(latencies)
+		r.Errors += stats.Errors
+		r.Unexpected += stats.Unexpected
+		r.Routes = append(r.Routes, stats)
+	}
+	sort.Slice(r.Routes, func(i, j int) bool { return r.Routes[i].Route < r.Routes[j].Route })
+	sort.Slice(r.Samples, func(i, j int) bool {
+		if r.Samples[i].Line != r.Samples[j].Line {
+			return r.Samples[i].Line < r.Samples[j].Line
+		}
+		return r.Samples[i].Iteration < r.Samples[j].Iteration
+	})
+
+	if elapsed > 0 {
+		r.Throughput = float64(answered) / elapsed.Seconds()
+	}
+	return r
+}
+
+func (r *report) addSample(res result) {
+	if len(r.Samples) >= maxSamples {
+		return
+	}
+	s := sample{Line: res.line, Iteration: res.iteration, Route: res.route, Status: res.status, Body: res.body}
+	if res.err != nil {
+		s.Error = res.err.Error()
+	}
+	r.Samples = append(r.Samples, s)
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies, in
+// milliseconds.
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return float64(sorted[rank-1].Microseconds()) / 1000
+}
+
+// checkInvariants reads the whole loan book as an admin and returns the rules
+// it breaks. Loans created by others are checked as well.
+func checkInvariants(ctx context.Context, c *client, adminID int64, results []result) ([]string, error) {
+	var loans []model.Loan
+	if err := c.getJSON(ctx, "/admin/view/loans", adminID, []string{"admin"}, &loans); err != nil {
+		return nil, err
+	}
+
+	violations := []string{}
+	byID := make(map[int64]model.Loan, len(loans))
+	for _, loan := range loans {
+		byID[loan.LoanID] = loan
+
+		invested := loan.InvestedAmount()
+		if invested > loan.PrincipalAmount {
+			violations = append(violations, fmt.Sprintf("loan %d is overfunded: %v invested of %v", loan.LoanID, invested, loan.PrincipalAmount))
+		}
+		switch loan.State {
+		case model.StateEnumProposed:
+			if len(loan.Investments) > 0 {
+				violations = append(violations, fmt.Sprintf("loan %d is proposed but has %d investments", loan.LoanID, len(loan.Investments)))
+			}
+		case model.StateEnumInvested, model.StateEnumDisbursed:
+			if invested != loan.PrincipalAmount {
+				violations = append(violations, fmt.Sprintf("loan %d is %s but only %v of %v is invested", loan.LoanID, loan.State, invested, loan.PrincipalAmount))
+			}
+		}
+	}
+
+	// Wallets hold exactly what the loans say: reserved for loans still
+	// funding, invested in disbursed loans until paid back
+	var wallets []model.Wallet
+	if err := c.getJSON(ctx, "/admin/wallets", adminID, []string{"admin"}, &wallets); err != nil {
+		return nil, err
+	}
+	reserved := map[int64]float64{}
+	lent := map[int64]float64{}
+	for _, loan := range loans {
+		for _, inv := range loan.Investments {
+			switch loan.State {
+			case model.StateEnumApproved, model.StateEnumInvested:
+				reserved[inv.InvestorID] += inv.InvestedAmount
+			case model.StateEnumDisbursed:
+				lent[inv.InvestorID] += inv.InvestedAmount
+			}
+		}
+		if loan.State == model.StateEnumDisbursed {
+			for _, repayment := range loan.Repayments {
+				for _, payout := range repayment.Payouts {
+					lent[payout.InvestorID] -= payout.Principal
+				}
+			}
+		}
+	}
+	for _, wallet := range wallets {
+		if !wallet.Balanced() {
+			violations = append(violations, fmt.Sprintf("wallet of investor %d is unbalanced: %v available, %v reserved and %v invested out of %v deposited and %v earned",
+				wallet.InvestorID, wallet.Available, wallet.Reserved, wallet.Invested, wallet.Deposited, wallet.Earned))
+		}
+		if want := model.RoundAmount(reserved[wallet.InvestorID]); wallet.Reserved != want {
+			violations = append(violations, fmt.Sprintf("wallet of investor %d has %v reserved, its loans still funding hold %v", wallet.InvestorID, wallet.Reserved, want))
+		}
+		if want := model.RoundAmount(lent[wallet.InvestorID]); wallet.Invested != want {
+			violations = append(violations, fmt.Sprintf("wallet of investor %d has %v invested, its disbursed loans owe %v", wallet.InvestorID, wallet.Invested, want))
+		}
+	}
+
+	// Every portfolio lists the loans its investor invested in, the investor
+	// index misses none
+	positions := map[int64]int{}
+	for _, loan := range loans {
+		seen := map[int64]bool{}
+		for _, inv := range loan.Investments {
+			if !seen[inv.InvestorID] {
+				seen[inv.InvestorID] = true
+				positions[inv.InvestorID]++
+			}
+		}
+	}
+	for _, wallet := range wallets {
+		var portfolio model.Portfolio
+		path := "/investors/" + strconv.FormatInt(wallet.InvestorID, 10) + "/portfolio"
+		if err := c.getJSON(ctx, path, adminID, []string{"admin"}, &portfolio); err != nil {
+			return nil, err
+		}
+		if got, want := len(portfolio.Positions), positions[wallet.InvestorID]; got != want {
+			violations = append(violations, fmt.Sprintf("portfolio of investor %d lists %d loans, the investor is in %d", wallet.InvestorID, got, want))
+		}
+	}
+
+	// The books sum to zero, and the investor wallet accounts owe what the
+	// wallets hold
+	var trialBalance ledger.TrialBalance
+	if err := c.getJSON(ctx, "/admin/ledger/trial-balance", adminID, []string{"admin"}, &trialBalance); err != nil {
+		return nil, err
+	}
+	if !trialBalance.Balanced {
+		violations = append(violations, fmt.Sprintf("ledger is unbalanced: %v debited and %v credited", trialBalance.Debit, trialBalance.Credit))
+	}
+	var owed float64
+	for _, wallet := range wallets {
+		owed += wallet.Deposited + wallet.Earned
+	}
+	if got := -trialBalance.Kinds[ledger.KindInvestorWallet].Balance; got != model.RoundAmount(owed) {
+		violations = append(violations, fmt.Sprintf("ledger owes investors %v, their wallets hold %v", got, model.RoundAmount(owed)))
+	}
+	for _, difference := range trialBalance.Differences {
+		violations = append(violations, fmt.Sprintf("ledger account %s owes %v, the wallet holds %v", difference.Account, difference.Owed, difference.Held))
+	}
+
+	// Every loan created by the run got its own ID and is in the book
+	created := map[int64]result{}
+	for _, res := range results {
+		if res.loanID == 0 {
+			continue
+		}
+		if previous, ok := created[res.loanID]; ok {
+			violations = append(violations, fmt.Sprintf("loan %d was created by line %d of iteration %d and line %d of iteration %d", res.loanID, previous.line, previous.iteration, res.line, res.iteration))
+			continue
+		}
+		created[res.loanID] = res
+		if _, ok := byID[res.loanID]; !ok {
+			violations = append(violations, fmt.Sprintf("loan %d was created by line %d of iteration %d but is missing", res.loanID, res.line, res.iteration))
+		}
+	}
+	sort.Strings(violations)
+	return violations, nil
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func printReport(r report) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "requests\t%d\n", r.Requests)
+	fmt.Fprintf(w, "duration\t%s\n", (time.Duration(r.DurationMS) * time.Millisecond).String())
+	fmt.Fprintf(w, "throughput\t%.1f req/s\n", r.Throughput)
+	fmt.Fprintf(w, "errors\t%d\n", r.Errors)
+	fmt.Fprintf(w, "unexpected\t%d\n\n", r.Unexpected)
+
+	fmt.Fprintln(w, "ROUTE\tCOUNT\tSTATUSES\tERRORS\tUNEXPECTED\tP50 MS\tP90 MS\tP95 MS\tP99 MS\tMAX MS")
+	for _, s := range r.Routes {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%d\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\n",
+			s.Route, s.Count, formatStatuses(s.Statuses), s.Errors, s.Unexpected, s.P50MS, s.P90MS, s.P95MS, s.P99MS, s.MaxMS)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(r.Samples) > 0 {
+		fmt.Println("\nunexpected responses:")
+		for _, s := range r.Samples {
+			outcome := s.Error
+			if outcome == "" {
+				outcome = strconv.Itoa(s.Status) + " " + s.Body
+			}
+			fmt.Printf("  line %d, iteration %d, %s: %s\n", s.Line, s.Iteration, s.Route, outcome)
+		}
+	}
+
+	fmt.Println("\ninvariants:")
+	if len(r.Violations) == 0 {
+		fmt.Println("  ok")
+	}
+	for _, v := range r.Violations {
+		fmt.Println("  " + v)
+	}
+	return nil
+}
+
+func formatStatuses(statuses map[string]int) string {
+	codes := make([]string, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	parts := make([]string, len(codes))
+	for i, code := range codes {
+		parts[i] = code + "=" + strconv.Itoa(statuses[code])
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// request is a line of the JSONL file.
+type request struct {
+	Step    int               `json:"step"`    // Steps run in ascending order, the requests of a step side by side
+	Method  string            `json:"method"`  // Defaults to GET
+	Path    string            `json:"path"`    // May reference captured IDs, e.g. /loans/$loan/approve
+	Actor   int64             `json:"actor"`   // Subject of the bearer token, 0 for no token
+	Roles   []string          `json:"roles"`   // Roles of the bearer token
+	Headers map[string]string `json:"headers"` // Extra headers, references are expanded
+	Body    json.RawMessage   `json:"body"`    // Sent as is, references are expanded
+	Form    map[string]string `json:"form"`    // Multipart fields, sent instead of body
+	Files   map[string]string `json:"files"`   // Multipart files by field, relative to the JSONL file
+	Repeat  int               `json:"repeat"`  // Number of copies sent at once, defaults to 1
+	As      string            `json:"as"`      // Captures the last segment of the Location header under this name
+	Expect  []int             `json:"expect"`  // Accepted statuses, defaults to any 2xx
+
+	line  int
+	files map[string]file // Files read when loading, by field
+}
+
+type file struct {
+	name    string
+	content []byte
+}
+
+// step is the requests of a scenario sharing a step number.
+type step struct {
+	number   int
+	requests []request
+}
+
+// refPattern matches a reference to a captured ID, e.g. $loan.
+var refPattern = regexp.MustCompile(`\$[A-Za-z_][A-Za-z0-9_]*`)
+
+// loadScenario reads the requests of path, grouped by step.
+func loadScenario(path string) ([]step, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	steps := map[int][]request{}
+	captured := map[string]int{} // Name to the line capturing it
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 || raw[0] == '#' {
+			continue
+		}
+
+		var req request
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		req.line = line
+		if err := req.normalize(); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if err := req.readFiles(filepath.Dir(path)); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+
+		if req.As != "" {
+			if previous, ok := captured[req.As]; ok {
+				return nil, fmt.Errorf("%s:%d: $%s is already captured on line %d", path, line, req.As, previous)
+			}
+			captured[req.As] = line
+		}
+		steps[req.Step] = append(steps[req.Step], req)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("%s has no requests", path)
+	}
+
+	scenario := make([]step, 0, len(steps))
+	for number, requests := range steps {
+		scenario = append(scenario, step{number: number, requests: requests})
+	}
+	sort.Slice(scenario, func(i, j int) bool { return scenario[i].number < scenario[j].number })
+
+	// A reference must be captured by an earlier step
+	for i, s := range scenario {
+		for _, req := range s.requests {
+			for _, ref := range req.refs() {
+				line, ok := captured[ref]
+				if !ok {
+					return nil, fmt.Errorf("%s:%d: $%s is never captured", path, req.line, ref)
+				}
+				if !capturedBefore(scenario[:i], line) {
+					return nil, fmt.Errorf("%s:%d: $%s is captured on line %d, which is not in an earlier step", path, req.line, ref, line)
+				}
+			}
+		}
+	}
+	return scenario, nil
+}
+
+func (req *request) normalize() error {
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+	req.Method = strings.ToUpper(req.Method)
+	if !strings.HasPrefix(req.Path, "/") {
+		return fmt.Errorf("path %q must start with /", req.Path)
+	}
+	if req.Repeat == 0 {
+		req.Repeat = 1
+	}
+	if req.Repeat < 0 {
+		return fmt.Errorf("repeat must not be negative, got %d", req.Repeat)
+	}
+	if req.As != "" && (req.Method != http.MethodPost || req.Repeat != 1) {
+		return fmt.Errorf("as %q needs a single POST request", req.As)
+	}
+	if req.multipart() && len(req.Body) > 0 {
+		return errors.New("a request has either a body or form and files")
+	}
+	return nil
+}
+
+func (req request) multipart() bool {
+	return len(req.Form) > 0 || len(req.Files) > 0
+}
+
+// readFiles loads the files once, so that every copy sends the same bytes.
+func (req *request) readFiles(dir string) error {
+	req.files = make(map[string]file, len(req.Files))
+	for field, name := range req.Files {
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		req.files[field] = file{name: filepath.Base(name), content: content}
+	}
+	return nil
+}
+
+// body returns the payload and its content type, with references expanded.
+func (req request) body(refs map[string]string) (string, string, error) {
+	if !req.multipart() {
+		return expand(string(req.Body), refs), "application/json", nil
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for field, value := range req.Form {
+		if err := writer.WriteField(field, expand(value, refs)); err != nil {
+			return "", "", err
+		}
+	}
+	for field, f := range req.files {
+		part, err := writer.CreateFormFile(field, f.name)
+		if err != nil {
+			return "", "", err
+		}
+		if _, err := part.Write(f.content); err != nil {
+			return "", "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", "", err
+	}
+	return buf.String(), writer.FormDataContentType(), nil
+}
+
+// refs returns the names referenced by the request, without the $.
+func (req request) refs() []string {
+	text := req.Path + string(req.Body)
+	for _, v := range req.Headers {
+		text += v
+	}
+	for _, v := range req.Form {
+		text += v
+	}
+	var names []string
+	for _, ref := range refPattern.FindAllString(text, -1) {
+		names = append(names, ref[1:])
+	}
+	return names
+}
+
+// expected reports whether status is an accepted response of the request.
+func (req request) expected(status int) bool {
+	if len(req.Expect) == 0 {
+		return status >= 200 && status < 300
+	}
+	for _, s := range req.Expect {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// route names the request in the report, references are kept so that the
+// requests of every iteration add up.
+func (req request) route() string {
+	return req.Method + " " + req.Path
+}
+
+func capturedBefore(steps []step, line int) bool {
+	for _, s := range steps {
+		for _, req := range s.requests {
+			if req.line == line {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expand replaces the references in text with the IDs captured in refs.
+func expand(text string, refs map[string]string) string {
+	return refPattern.ReplaceAllStringFunc(text, func(ref string) string {
+		return refs[ref[1:]]
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/common/driver/blob"
+	httpdriver "github.com/timotiusas11/amartha-assignment/common/driver/http"
+	"github.com/timotiusas11/amartha-assignment/common/driver/inmemlib"
+	"github.com/timotiusas11/amartha-assignment/common/driver/nsq"
+	"github.com/timotiusas11/amartha-assignment/common/middleware"
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/config"
+	"github.com/timotiusas11/amartha-assignment/internal/delivery"
+	"github.com/timotiusas11/amartha-assignment/internal/repository"
+	"github.com/timotiusas11/amartha-assignment/internal/usecase"
+)
+
+// startServer runs the loan API in-process, wired like app/main.go with the
+// default limits. NSQ messages are only logged, as in the service, and
+// documents are kept in blobDir.
+func startServer(opts options, blobDir string) (*httptest.Server, error) {
+	cfg := config.Default()
+
+	cache := inmemlib.New(inmemlib.WithSize(opts.cacheMB * inmemlib.MB))
+	idempotencyCache := inmemlib.New(inmemlib.WithSize(cfg.Cache.IdempotencySizeMB * inmemlib.MB))
+	nsqClient := nsq.New("", cfg.NSQ.OutboxSize)
+	httpClient := httpdriver.New("", cfg.HTTP.Timeout.Duration())
+	blobStore, err := blob.NewLocal(blobDir)
+	if err != nil {
+		return nil, err
+	}
+	repositories := repository.NewRepository(cache, nsqClient, httpClient, blobStore)
+	usecases := usecase.NewUsecase(repositories, usecase.Limits{
+		MinPrincipalAmount:  cfg.Limits.MinPrincipalAmount,
+		MaxPrincipalAmount:  cfg.Limits.MaxPrincipalAmount,
+		MaxRate:             cfg.Limits.MaxRate,
+		MaxROI:              cfg.Limits.MaxROI,
+		MinInvestmentAmount: cfg.Limits.MinInvestmentAmount,
+		MaxDocumentSize:     int64(cfg.Blob.MaxSizeMB) << 20,
+		FundingPeriod:       cfg.Limits.FundingPeriod.Duration(),
+	}, usecase.FeeRules{
+		Default:  cfg.Fees.Terms(),
+		Products: cfg.Fees.ProductTerms(),
+	})
+
+	verifier, err := auth.NewVerifier(map[string]string{opts.kid: opts.secret}, opts.issuer, opts.audience)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token verifier: %w", err)
+	}
+	idempotent := middleware.Idempotency(idempotencyCache, 24*time.Hour, int64(cfg.Blob.MaxSizeMB+1)<<20, func(r *http.Request) string {
+		principal, _ := auth.PrincipalFromContext(r.Context())
+		return strconv.FormatInt(principal.ActorID, 10)
+	})
+	authorized := func(permission auth.Permission, handler http.HandlerFunc) http.Handler {
+		return auth.Authenticate(verifier)(auth.Require(permission)(idempotent(handler)))
+	}
+
+	router := http.NewServeMux()
+	delivery.NewDelivery(usecases).Register(router, authorized)
+	return httptest.NewServer(middleware.Chain(router, middleware.RequestID)), nil
+}
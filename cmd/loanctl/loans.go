@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+func runList(ctx context.Context, opts *options, flags *flag.FlagSet, args []string) error {
+	state := flags.String("state", "", "comma separated states")
+	borrower := flags.Int64("borrower", 0, "borrower ID")
+	sort := flags.String("sort", "", `sort field, "-" prefix for descending, e.g. -principal_amount`)
+	limit := flags.Int("limit", 0, "maximum number of loans, 0 for all")
+	if err := parse(opts, flags, args); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	if *state != "" {
+		query.Set("state", *state)
+	}
+	if *borrower != 0 {
+		query.Set("borrower_id", strconv.FormatInt(*borrower, 10))
+	}
+	if *sort != "" {
+		query.Set("sort", *sort)
+	}
+
+	// Follow the cursor until every page, or enough loans, are read
+	loans := make([]model.LoanInformation, 0)
+	for {
+		var page model.LoanInformationPage
+		if err := getJSON(ctx, opts, "/loans?"+query.Encode(), &page); err != nil {
+			return err
+		}
+		loans = append(loans, page.Loans...)
+		if page.NextCursor == "" || (*limit > 0 && len(loans) >= *limit) {
+			break
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+	if *limit > 0 && len(loans) > *limit {
+		loans = loans[:*limit]
+	}
+
+	if opts.output == "json" {
+		return printJSON(loans)
+	}
+	rows := [][]string{{"LOAN ID", "BORROWER", "STATE", "PRINCIPAL", "RATE", "ROI", "FUNDED", "FUNDED %", "INVESTORS"}}
+	for _, loan := range loans {
+		rows = append(rows, []string{
+			formatID(loan.LoanID),
+			formatID(loan.BorrowerID),
+			loan.State.String(),
+			formatAmount(loan.PrincipalAmount),
+			formatAmount(loan.Rate),
+			formatAmount(loan.ROI),
+			formatAmount(loan.FundedAmount),
+			formatAmount(loan.FundingPercentage),
+			strconv.Itoa(loan.InvestorCount),
+		})
+	}
+	return printTable(rows)
+}
+
+func runInspect(ctx context.Context, opts *options, flags *flag.FlagSet, args []string) error {
+	asOf := flags.String("as-of", "", "show the loan as it was at this time, RFC 3339 or YYYY-MM-DD")
+	if err := parse(opts, flags, args); err != nil {
+		return err
+	}
+	loanID, err := loanIDArg(flags)
+	if err != nil {
+		return err
+	}
+
+	path := "/admin/loans/" + strconv.FormatInt(loanID, 10)
+	if *asOf != "" {
+		path += "?as_of=" + url.QueryEscape(*asOf)
+	}
+	var loan model.Loan
+	if err := getJSON(ctx, opts, path, &loan); err != nil {
+		return err
+	}
+
+	if opts.output == "json" {
+		return printJSON(loan)
+	}
+	rows := [][]string{
+		{"FIELD", "VALUE"},
+		{"loan_id", formatID(loan.LoanID)},
+		{"borrower_id", formatID(loan.BorrowerID)},
+		{"state", loan.State.String()},
+		{"principal_amount", formatAmount(loan.PrincipalAmount)},
+		{"rate", formatAmount(loan.Rate)},
+		{"roi", formatAmount(loan.ROI)},
+		{"created_at", formatTime(loan.CreatedAt)},
+		{"funded_amount", formatAmount(loan.InvestedAmount())},
+		{"remaining_amount", formatAmount(loan.RemainingAmount())},
+		{"agreement_letter_url", loan.AgreementLetterURL},
+		{"approved_by", formatID(loan.ApprovalInfo.FieldValidatorID)},
+		{"approval_date", formatTime(loan.ApprovalInfo.ApprovalDate)},
+		{"picture_proof_url", loan.ApprovalInfo.PictureProofURL},
+		{"disbursed_by", formatID(loan.DisbursementInfo.FieldOfficerID)},
+		{"disbursement_date", formatTime(loan.DisbursementInfo.DisbursementDate)},
+		{"signed_agreement_letter_url", loan.DisbursementInfo.SignedAgreementLetterURL},
+		{"cancelled_by", formatID(loan.CancellationInfo.CancelledBy)},
+		{"cancellation_date", formatTime(loan.CancellationInfo.CancellationDate)},
+		{"cancellation_reason", loan.CancellationInfo.Reason},
+	}
+	for i, inv := range loan.Investments {
+		rows = append(rows, []string{
+			fmt.Sprintf("investment[%d]", i),
+			fmt.Sprintf("investor %d, %s", inv.InvestorID, formatAmount(inv.InvestedAmount)),
+		})
+	}
+	return printTable(rows)
+}
+
+func runEvents(ctx context.Context, opts *options, flags *flag.FlagSet, args []string) error {
+	types := flags.String("type", "", "comma separated event types")
+	if err := parse(opts, flags, args); err != nil {
+		return err
+	}
+
+	// Without a loan ID the whole log is shown
+	path := "/admin/events"
+	if flags.NArg() > 0 {
+		loanID, err := loanIDArg(flags)
+		if err != nil {
+			return err
+		}
+		path = "/loans/" + strconv.FormatInt(loanID, 10) + "/events"
+	}
+
+	query := url.Values{}
+	if *types != "" {
+		query.Set("type", *types)
+	}
+	events := make([]model.Event, 0)
+	for {
+		var page model.EventPage
+		if err := getJSON(ctx, opts, path+"?"+query.Encode(), &page); err != nil {
+			return err
+		}
+		events = append(events, page.Events...)
+		if page.NextAfter == 0 {
+			break
+		}
+		query.Set("after", strconv.FormatInt(page.NextAfter, 10))
+	}
+
+	if opts.output == "json" {
+		return printJSON(events)
+	}
+	rows := [][]string{{"EVENT ID", "OCCURRED AT", "LOAN ID", "TYPE", "ACTOR", "PAYLOAD"}}
+	for _, event := range events {
+		rows = append(rows, []string{
+			formatID(event.EventID),
+			formatTime(event.OccurredAt),
+			formatID(event.LoanID),
+			string(event.Type),
+			formatID(event.ActorID),
+			string(event.Payload),
+		})
+	}
+	return printTable(rows)
+}
+
+func runTransition(ctx context.Context, opts *options, flags *flag.FlagSet, args []string) error {
+	state := flags.String("state", "", "target state, e.g. cancelled")
+	reason := flags.String("reason", "", "why the state machine is bypassed, kept in the audit log")
+	if err := parse(opts, flags, args); err != nil {
+		return err
+	}
+	loanID, err := loanIDArg(flags)
+	if err != nil {
+		return err
+	}
+	to, err := model.ParseStateEnum(*state)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(*reason) == "" {
+		return errors.New("-reason is required")
+	}
+
+	body, err := postJSON(ctx, opts, fmt.Sprintf("/admin/loans/%d/transition", loanID), map[string]interface{}{
+		"state":  to,
+		"reason": *reason,
+	})
+	if err != nil {
+		return err
+	}
+	return printMessage(opts, string(body))
+}
+
+func runReplayNSQ(ctx context.Context, opts *options, flags *flag.FlagSet, args []string) error {
+	channel := flags.String("channel", "email_agreement_letter", "email_agreement_letter or generate_agreement_letter")
+	if err := parse(opts, flags, args); err != nil {
+		return err
+	}
+	loanID, err := loanIDArg(flags)
+	if err != nil {
+		return err
+	}
+
+	body, err := postJSON(ctx, opts, fmt.Sprintf("/admin/loans/%d/republish", loanID), map[string]string{
+		"channel": *channel,
+	})
+	if err != nil {
+		return err
+	}
+	return printMessage(opts, string(body))
+}
+
+func runRebuild(ctx context.Context, opts *options, flags *flag.FlagSet, args []string) error {
+	dryRun := flags.Bool("dry-run", false, "only report the loans that would change")
+	if err := parse(opts, flags, args); err != nil {
+		return err
+	}
+
+	body, err := call(ctx, opts, http.MethodPost, "/admin/projections/rebuild?dry_run="+strconv.FormatBool(*dryRun), nil)
+	if err != nil {
+		return err
+	}
+	var report model.RebuildReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return err
+	}
+
+	if opts.output == "json" {
+		return printJSON(report)
+	}
+	return printTable([][]string{
+		{"FIELD", "VALUE"},
+		{"dry_run", strconv.FormatBool(report.DryRun)},
+		{"events", strconv.Itoa(report.Events)},
+		{"loans", strconv.Itoa(report.Loans)},
+		{"changed_loans", formatIDs(report.ChangedLoans)},
+		{"missing_loans", formatIDs(report.MissingLoans)},
+		{"orphan_loans", formatIDs(report.OrphanLoans)},
+		{"skipped_loans", formatIDs(report.SkippedLoans)},
+		{"duration_ms", strconv.FormatInt(report.DurationMS, 10)},
+	})
+}
+
+func runExpire(ctx context.Context, opts *options, flags *flag.FlagSet, args []string) error {
+	if err := parse(opts, flags, args); err != nil {
+		return err
+	}
+
+	body, err := call(ctx, opts, http.MethodPost, "/admin/loans/expire", nil)
+	if err != nil {
+		return err
+	}
+	var report model.ExpiryReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return err
+	}
+
+	if opts.output == "json" {
+		return printJSON(report)
+	}
+	return printTable([][]string{
+		{"FIELD", "VALUE"},
+		{"expired", formatIDs(report.Expired)},
+	})
+}
+
+func loanIDArg(flags *flag.FlagSet) (int64, error) {
+	if flags.NArg() != 1 {
+		return 0, errors.New("expected exactly one loan ID after the flags")
+	}
+	loanID, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+	if err != nil || loanID <= 0 {
+		return 0, fmt.Errorf("invalid loan ID %q", flags.Arg(0))
+	}
+	return loanID, nil
+}
+
+func printMessage(opts *options, message string) error {
+	if opts.output == "json" {
+		return printJSON(map[string]string{"message": message})
+	}
+	fmt.Println(message)
+	return nil
+}
+
+func formatIDs(ids []int64) string {
+	if len(ids) == 0 {
+		return "-"
+	}
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,121 @@
+// Command loanctl operates a running loan service through its HTTP API. It
+// needs a bearer token with the admin role, see loantoken.
+//
+//	export LOAN_URL=http://localhost:8080 LOAN_TOKEN="$(loantoken -kid dev -secret "$SECRET" -sub 1 -roles admin)"
+//	loanctl list -state approved
+//	loanctl inspect -as-of 2026-01-31 1717171717171
+//	loanctl transition -state cancelled -reason "duplicate of 1717171717000" 1717171717171
+//	loanctl export -f loanbook.jsonl
+//
+// Flags go before the loan ID. Every command accepts -o json for scripts.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+)
+
+const usage = `usage: loanctl <command> [flags] [loan_id]
+
+Loans:
+  list        list loans, with filters
+  inspect     show every detail of a loan, optionally as it was at a point in time
+  events      show the audit log of a loan
+  transition  force a loan into a state, bypassing the state machine, with a reason
+  replay-nsq  publish the NSQ messages of a loan again
+  stats       portfolio totals by state
+  expire      cancel approved loans whose funding period is over, releasing their investors' funds
+
+Data:
+  export      download the loan book to an archive
+  verify      check the version, checksums and record counts of an archive
+  restore     upload an archive into a service with an empty store
+  rebuild     rebuild the loan book from the event log
+
+Run loanctl <command> -h for the flags of a command.
+`
+
+// commands parse their own flags, on top of the shared ones in opts.
+var commands = map[string]func(ctx context.Context, opts *options, flags *flag.FlagSet, args []string) error{
+	"list":       runList,
+	"inspect":    runInspect,
+	"events":     runEvents,
+	"transition": runTransition,
+	"replay-nsq": runReplayNSQ,
+	"stats":      runStats,
+	"expire":     runExpire,
+	"export":     runExport,
+	"verify":     runVerify,
+	"restore":    runRestore,
+	"rebuild":    runRebuild,
+}
+
+// options are the flags shared by every command.
+type options struct {
+	baseURL string
+	token   string
+	timeout time.Duration
+	output  string
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, "loanctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return errors.New("missing command")
+	}
+	if args[0] == "-h" || args[0] == "-help" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprint(os.Stderr, usage)
+		return flag.ErrHelp
+	}
+
+	runCommand, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	opts := &options{}
+	flags := flag.NewFlagSet("loanctl "+args[0], flag.ContinueOnError)
+	flags.StringVar(&opts.baseURL, "url", envOr("LOAN_URL", "http://localhost:8080"), "base URL of the loan service (env LOAN_URL)")
+	flags.StringVar(&opts.token, "token", os.Getenv("LOAN_TOKEN"), "admin bearer token (env LOAN_TOKEN)")
+	flags.DurationVar(&opts.timeout, "timeout", 30*time.Second, "timeout of each HTTP request")
+	flags.StringVar(&opts.output, "o", "table", "output format: table or json")
+
+	return runCommand(ctx, opts, flags, args[1:])
+}
+
+// parse parses the command flags and checks the output format.
+func parse(opts *options, flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if opts.output != "table" && opts.output != "json" {
+		return fmt.Errorf("-o must be table or json, got %q", opts.output)
+	}
+	return nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
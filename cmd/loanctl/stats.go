@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strconv"
+
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+type stateStats struct {
+	Loans     int     `json:"loans"`
+	Principal float64 `json:"principal_amount"`
+	Funded    float64 `json:"funded_amount"`
+	Remaining float64 `json:"remaining_amount"`
+}
+
+type portfolioStats struct {
+	States    map[string]*stateStats `json:"states"`
+	Total     stateStats             `json:"total"`
+	Borrowers int                    `json:"borrowers"`
+	Investors int                    `json:"investors"`
+}
+
+var stateOrder = []model.StateEnum{
+	model.StateEnumProposed,
+	model.StateEnumApproved,
+	model.StateEnumInvested,
+	model.StateEnumDisbursed,
+	model.StateEnumCancelled,
+}
+
+func runStats(ctx context.Context, opts *options, flags *flag.FlagSet, args []string) error {
+	if err := parse(opts, flags, args); err != nil {
+		return err
+	}
+
+	var loans []model.Loan
+	if err := getJSON(ctx, opts, "/admin/view/loans", &loans); err != nil {
+		return err
+	}
+
+	stats := portfolioStats{States: make(map[string]*stateStats)}
+	for _, state := range stateOrder {
+		stats.States[state.String()] = &stateStats{}
+	}
+	borrowers := make(map[int64]bool)
+	investors := make(map[int64]bool)
+	for _, loan := range loans {
+		for _, s := range []*stateStats{stats.States[loan.State.String()], &stats.Total} {
+			s.Loans++
+			s.Principal += loan.PrincipalAmount
+			s.Funded += loan.InvestedAmount()
+			s.Remaining += loan.RemainingAmount()
+		}
+		borrowers[loan.BorrowerID] = true
+		for _, inv := range loan.Investments {
+			investors[inv.InvestorID] = true
+		}
+	}
+	stats.Borrowers = len(borrowers)
+	stats.Investors = len(investors)
+
+	if opts.output == "json" {
+		return printJSON(stats)
+	}
+	rows := [][]string{{"STATE", "LOANS", "PRINCIPAL", "FUNDED", "REMAINING"}}
+	addRow := func(name string, s stateStats) {
+		rows = append(rows, []string{name, strconv.Itoa(s.Loans), formatAmount(s.Principal), formatAmount(s.Funded), formatAmount(s.Remaining)})
+	}
+	for _, state := range stateOrder {
+		addRow(state.String(), *stats.States[state.String()])
+	}
+	addRow("total", stats.Total)
+	rows = append(rows, []string{}, []string{"borrowers", strconv.Itoa(stats.Borrowers)}, []string{"investors", strconv.Itoa(stats.Investors)})
+	return printTable(rows)
+}
@@ -0,0 +1,53 @@
+// Command loantoken mints HS256 bearer tokens for the loan service, for local
+// development and operators holding a signing key.
+//
+//	loantoken -kid dev -secret "$SECRET" -sub 42 -ttl 1h
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+)
+
+func main() {
+	kid := flag.String("kid", "", "key ID of the signing key")
+	secret := flag.String("secret", os.Getenv("LOAN_AUTH_SECRET"), "signing secret (env LOAN_AUTH_SECRET)")
+	subject := flag.Int64("sub", 0, "actor ID the token authenticates")
+	roles := flag.String("roles", "", "comma separated roles")
+	issuer := flag.String("iss", "", "issuer claim")
+	audience := flag.String("aud", "", "comma-separated audience claim")
+	ttl := flag.Duration("ttl", time.Hour, "token lifetime")
+	flag.Parse()
+
+	if *subject <= 0 || len(*secret) < auth.MinKeyLength {
+		fmt.Fprintf(os.Stderr, "loantoken: -sub must be positive and -secret at least %d bytes\n", auth.MinKeyLength)
+		os.Exit(2)
+	}
+
+	now := time.Now()
+	claims := auth.Claims{
+		Subject:   strconv.FormatInt(*subject, 10),
+		Issuer:    *issuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(*ttl).Unix(),
+	}
+	if *audience != "" {
+		claims.Audience = strings.Split(*audience, ",")
+	}
+	if *roles != "" {
+		claims.Roles = strings.Split(*roles, ",")
+	}
+
+	token, err := auth.Sign(*kid, *secret, claims)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "loantoken:", err)
+		os.Exit(1)
+	}
+	fmt.Println(token)
+}
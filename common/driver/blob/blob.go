@@ -0,0 +1,120 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+)
+
+// ErrNotFound is returned by Get when nothing is stored under the key.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobInterface stores opaque content by key. Keys are slash separated paths,
+// e.g. "documents/sha256/ab12...". Implementations must make a Put visible
+// all at once, a concurrent Get never sees half a blob.
+type BlobInterface interface {
+	Put(ctx context.Context, key string, content io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Ping(ctx context.Context) error
+}
+
+// Local keeps blobs as files under a directory.
+type Local struct {
+	dir string
+}
+
+// NewLocal creates dir when missing and returns a store keeping blobs in it.
+func NewLocal(dir string) (Local, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return Local{}, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	return Local{dir: dir}, nil
+}
+
+// Put writes content to a temporary file first and renames it into place.
+func (l Local) Put(ctx context.Context, key string, content io.Reader) error {
+	_, span := tracing.Start(ctx, "blob.Put", tracing.String("blob.key", key))
+	defer span.End()
+
+	err := l.put(key, content)
+	span.RecordError(err)
+	return err
+}
+
+func (l Local) put(key string, content io.Reader) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create blob file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to store blob: %w", err)
+	}
+	return nil
+}
+
+func (l Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	_, span := tracing.Start(ctx, "blob.Get", tracing.String("blob.key", key))
+	defer span.End()
+
+	path, err := l.path(key)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to open blob: %w", err)
+	}
+	return f, nil
+}
+
+// Ping reports whether the directory is still there.
+func (l Local) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	info, err := os.Stat(l.dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", l.dir)
+	}
+	return nil
+}
+
+// path maps key into the directory, rejecting keys that would escape it.
+func (l Local) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || !fs.ValidPath(key) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
@@ -1,18 +1,102 @@
 package http
 
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+)
+
 type HTTPInterface interface {
-	Post() error
+	Post(ctx context.Context, path string, body interface{}) error
+	Ping(ctx context.Context) error
 }
 
 type HTTP struct {
-	// Client
+	baseURL string
+	client  *http.Client
+}
+
+func New(baseURL string, timeout time.Duration) HTTP {
+	return HTTP{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client: &http.Client{
+			Timeout: timeout,
+		},
+	}
 }
 
-func New() HTTP {
-	return HTTP{}
+// Post calls the downstream path with body encoded as JSON, propagating the
+// current trace through the traceparent header.
+func (h HTTP) Post(ctx context.Context, path string, body interface{}) error {
+	url := h.baseURL + path
+	ctx, span := tracing.StartWithKind(ctx, tracing.SpanKindClient, "HTTP POST",
+		tracing.String("http.method", http.MethodPost),
+		tracing.String("http.url", url),
+	)
+	defer span.End()
+
+	err := h.post(ctx, url, body, span)
+	span.RecordError(err)
+	return err
 }
 
-func (h HTTP) Post() error {
-	// Call downstream using POST method
+func (h HTTP) post(ctx context.Context, url string, body interface{}, span *tracing.Span) error {
+	if h.baseURL == "" {
+		return fmt.Errorf("downstream base URL is not configured")
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, tracing.HeaderCarrier(req.Header))
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	span.SetAttributes(tracing.Int64("http.status_code", int64(resp.StatusCode)))
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("downstream responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// Ping checks the downstream service answers. Any non-5xx response counts as reachable.
+func (h HTTP) Ping(ctx context.Context) error {
+	if h.baseURL == "" {
+		return fmt.Errorf("downstream base URL is not configured")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, h.baseURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("downstream responded with %s", resp.Status)
+	}
 	return nil
 }
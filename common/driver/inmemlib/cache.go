@@ -0,0 +1,162 @@
+package inmemlib
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Cache is a typed layer on top of InMemLibInterface. It owns the encoding of
+// T so callers no longer have to hand-roll unmarshal closures.
+type Cache[T any] struct {
+	store  InMemLibInterface
+	codec  Codec
+	prefix string
+	ttl    time.Duration
+	group  *singleflight.Group
+}
+
+type cacheOptions struct {
+	codec  Codec
+	prefix string
+	ttl    time.Duration
+}
+
+type CacheOption func(*cacheOptions)
+
+// WithCodec sets the codec used to encode values. Defaults to JSONCodec.
+func WithCodec(codec Codec) CacheOption {
+	return func(o *cacheOptions) {
+		o.codec = codec
+	}
+}
+
+// WithPrefix namespaces every key of the cache.
+func WithPrefix(prefix string) CacheOption {
+	return func(o *cacheOptions) {
+		o.prefix = prefix
+	}
+}
+
+// WithTTL sets the default TTL used by Set, SetMany and GetOrLoad. Zero means no expiry.
+func WithTTL(ttl time.Duration) CacheOption {
+	return func(o *cacheOptions) {
+		o.ttl = ttl
+	}
+}
+
+func NewCache[T any](store InMemLibInterface, opts ...CacheOption) *Cache[T] {
+	o := cacheOptions{codec: JSONCodec}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Cache[T]{
+		store:  store,
+		codec:  o.codec,
+		prefix: o.prefix,
+		ttl:    o.ttl,
+		group:  &singleflight.Group{},
+	}
+}
+
+func (c *Cache[T]) key(key string) string {
+	return c.prefix + key
+}
+
+// Get returns the value stored under key and whether it exists.
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var value T
+	data, exists, err := c.store.GetBytes(ctx, c.key(key))
+	if err != nil || !exists {
+		return value, false, err
+	}
+	if err := c.codec.Unmarshal(data, &value); err != nil {
+		return value, false, fmt.Errorf("failed to decode %q with %s codec: %w", key, c.codec.Name(), err)
+	}
+	return value, true, nil
+}
+
+// Set stores value under key using the cache's default TTL.
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	return c.SetWithTTL(ctx, key, value, c.ttl)
+}
+
+// SetWithTTL stores value under key with a per-key TTL. Zero means no expiry.
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %q with %s codec: %w", key, c.codec.Name(), err)
+	}
+	return c.store.SetBytes(ctx, c.key(key), data, ttl)
+}
+
+// Delete removes key and reports whether it was present.
+func (c *Cache[T]) Delete(ctx context.Context, key string) (bool, error) {
+	return c.store.Delete(ctx, c.key(key))
+}
+
+// GetOrLoad returns the cached value for key, calling load on a miss and
+// storing its result. Concurrent misses for the same key share one load call;
+// a caller whose ctx is done stops waiting without cancelling the shared load.
+func (c *Cache[T]) GetOrLoad(ctx context.Context, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	value, exists, err := c.Get(ctx, key)
+	if err != nil || exists {
+		return value, err
+	}
+
+	// The shared load must not be cancelled by whichever caller happened to start it
+	loadCtx := context.WithoutCancel(ctx)
+	result := c.group.DoChan(c.key(key), func() (interface{}, error) {
+		// Another caller may have filled the key while we were waiting
+		value, exists, err := c.Get(loadCtx, key)
+		if err != nil || exists {
+			return value, err
+		}
+
+		value, err = load(loadCtx)
+		if err != nil {
+			return value, err
+		}
+		return value, c.Set(loadCtx, key, value)
+	})
+
+	var zero T
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case res := <-result:
+		if res.Err != nil {
+			return zero, res.Err
+		}
+		// A nil interface T comes back as an untyped nil, which is its zero value
+		value, _ := res.Val.(T)
+		return value, nil
+	}
+}
+
+// GetMany returns the values found for keys. Missing keys are left out of the result.
+func (c *Cache[T]) GetMany(ctx context.Context, keys []string) (map[string]T, error) {
+	values := make(map[string]T, len(keys))
+	for _, key := range keys {
+		value, exists, err := c.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// SetMany stores every item using the cache's default TTL.
+func (c *Cache[T]) SetMany(ctx context.Context, items map[string]T) error {
+	for key, value := range items {
+		if err := c.Set(ctx, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package inmemlib
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	errLoad := errors.New("load failed")
+
+	tests := []struct {
+		name    string
+		cached  bool // The key holds "cached" before the call
+		load    func(ctx context.Context) (any, error)
+		want    any
+		wantErr error
+		stored  bool // The key holds a value after the call
+	}{
+		{
+			name:   "miss loads and stores",
+			load:   func(ctx context.Context) (any, error) { return "loaded", nil },
+			want:   "loaded",
+			stored: true,
+		},
+		{
+			name:   "hit skips the loader",
+			cached: true,
+			load: func(ctx context.Context) (any, error) {
+				return nil, errors.New("loader called on a hit")
+			},
+			want:   "cached",
+			stored: true,
+		},
+		{
+			name:   "nil interface from the loader",
+			load:   func(ctx context.Context) (any, error) { return nil, nil },
+			want:   nil,
+			stored: true,
+		},
+		{
+			name:    "loader error is not stored",
+			load:    func(ctx context.Context) (any, error) { return nil, errLoad },
+			wantErr: errLoad,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			cache := NewCache[any](New())
+			if tt.cached {
+				if err := cache.Set(ctx, "key", "cached"); err != nil {
+					t.Fatalf("Set() error = %v", err)
+				}
+			}
+
+			got, err := cache.GetOrLoad(ctx, "key", tt.load)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetOrLoad() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetOrLoad() = %v, want %v", got, tt.want)
+			}
+			if _, stored, _ := cache.store.GetBytes(ctx, "key"); stored != tt.stored {
+				t.Errorf("key stored = %v, want %v", stored, tt.stored)
+			}
+		})
+	}
+}
+
+func TestGetOrLoadSharesLoads(t *testing.T) {
+	cache := NewCache[int](New())
+	release := make(chan struct{})
+	var calls atomic.Int64
+	load := func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]int, 8)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = cache.GetOrLoad(context.Background(), "key", load)
+		}()
+	}
+
+	// A caller that gives up stops waiting, the load goes on for the others
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cache.GetOrLoad(ctx, "key", load); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetOrLoad() with a cancelled context error = %v, want %v", err, context.Canceled)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("loader called %d times, want 1", calls.Load())
+	}
+	for i, got := range results {
+		if got != 42 {
+			t.Errorf("caller %d got %d, want 42", i, got)
+		}
+	}
+}
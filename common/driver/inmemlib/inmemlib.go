@@ -1,8 +1,17 @@
 package inmemlib
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/coocood/freecache"
 )
@@ -10,40 +19,315 @@ import (
 const MB = 1024 * 1024
 
 type InMemLibInterface interface {
-	Set(key string, value interface{}) error
-	Get(key string, unmarshalFn func(val []byte) error) (bool, error)
+	Set(ctx context.Context, key string, value interface{}) error
+	Get(ctx context.Context, key string, unmarshalFn func(val []byte) error) (bool, error)
+	SetBytes(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	GetBytes(ctx context.Context, key string) ([]byte, bool, error)
+	Delete(ctx context.Context, key string) (bool, error)
+	Watch(key string)
+	WatchPrefix(prefix string)
+	Stats() Stats
+	Ping(ctx context.Context) error
+}
+
+// DefaultSize is the cache capacity used when no size is configured.
+const DefaultSize = 10 * MB
+
+// minSize is the smallest capacity freecache allocates.
+const minSize = 512 * 1024
+
+// ErrEntryTooLarge is returned when a value does not fit in a single cache
+// entry. freecache refuses entries above 1/1024 of the cache capacity.
+var ErrEntryTooLarge = errors.New("cache entry too large")
+
+// EvictionHandler is called when a watched key is found missing although it
+// was neither deleted nor expired, i.e. freecache evicted it to make room.
+type EvictionHandler func(key string)
+
+type Option func(*InMemLib)
+
+// WithSize sets the cache capacity in bytes. freecache enforces a 512KB minimum.
+func WithSize(size int) Option {
+	return func(m *InMemLib) {
+		if size > 0 {
+			m.size = size
+		}
+	}
+}
+
+// WithEvictionHandler replaces the default handler, which logs the evicted key.
+func WithEvictionHandler(handler EvictionHandler) Option {
+	return func(m *InMemLib) {
+		m.onEvict = handler
+	}
 }
 
 type InMemLib struct {
-	client *freecache.Cache
+	client  *freecache.Cache
+	size    int
+	onEvict EvictionHandler
+	watched *watchlist
 }
 
-func New() InMemLib {
-	client := freecache.NewCache(10 * MB)
-	return InMemLib{
-		client: client,
+func New(opts ...Option) InMemLib {
+	m := InMemLib{
+		size:    DefaultSize,
+		onEvict: logEviction,
+		watched: &watchlist{keys: make(map[string]watchedEntry)},
 	}
+	for _, opt := range opts {
+		opt(&m)
+	}
+	m.size = max(m.size, minSize)
+	m.client = freecache.NewCache(m.size)
+	return m
 }
 
-func (m InMemLib) Set(key string, value interface{}) error {
+func (m InMemLib) Set(ctx context.Context, key string, value interface{}) error {
 	// This is a custom wrapper, allowing us to add custom logs or metrics here.
-	bkey := []byte(key)
 	data, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
-	return m.client.Set(bkey, data, 0)
+	return m.SetBytes(ctx, key, data, 0)
 }
 
-func (m InMemLib) Get(key string, unmarshalFn func(val []byte) error) (bool, error) {
+func (m InMemLib) Get(ctx context.Context, key string, unmarshalFn func(val []byte) error) (bool, error) {
 	// This is a custom wrapper, allowing us to add custom logs or metrics here.
-	bkey := []byte(key)
-	val, err := m.client.Get(bkey)
+	val, exists, err := m.GetBytes(ctx, key)
+	if err != nil || !exists {
+		return false, err
+	}
+	return true, unmarshalFn(val)
+}
+
+// SetBytes stores an already encoded value. A zero ttl keeps the entry until it is evicted.
+func (m InMemLib) SetBytes(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	// freecache calls cannot be interrupted, so cancellation is only honored before them
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := m.client.Set([]byte(key), value, expireSeconds(ttl))
+	if errors.Is(err, freecache.ErrLargeEntry) {
+		return fmt.Errorf("%w: %q takes %d bytes, entries are limited to %d bytes by the cache size",
+			ErrEntryTooLarge, key, len(key)+len(value), m.MaxEntrySize())
+	}
+	if err != nil {
+		return err
+	}
+	m.watched.stored(key, ttl)
+
+	// freecache makes room on writes, so a write that evacuated entries may
+	// have taken a watched key with it
+	if count := m.client.EvacuateCount(); m.watched.evacuated.Swap(count) != count {
+		m.checkWatched()
+	}
+	return nil
+}
+
+// checkWatched reports the watched keys that are gone although they were
+// neither deleted nor expired.
+func (m InMemLib) checkWatched() {
+	for _, key := range m.watched.present() {
+		_, err := m.client.Peek([]byte(key))
+		if errors.Is(err, freecache.ErrNotFound) && m.watched.evicted(key) {
+			m.onEvict(key)
+		}
+	}
+}
+
+// GetBytes returns the raw value stored under key and whether it exists.
+func (m InMemLib) GetBytes(ctx context.Context, key string) ([]byte, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	val, err := m.client.Get([]byte(key))
 	if errors.Is(err, freecache.ErrNotFound) {
-		return false, nil
+		if m.watched.evicted(key) {
+			m.onEvict(key)
+		}
+		return nil, false, nil
 	}
 	if err != nil {
+		return nil, false, err
+	}
+	return val, true, nil
+}
+
+// Delete removes key and reports whether it was present.
+func (m InMemLib) Delete(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
 		return false, err
 	}
-	return true, unmarshalFn(val)
+	m.watched.deleted(key)
+	return m.client.Del([]byte(key)), nil
+}
+
+// Watch marks key as one the caller relies on, so that its eviction is reported
+// through the eviction handler and counted in Stats. The eviction is reported by
+// the write that caused it, or else by the next read that misses the key.
+func (m InMemLib) Watch(key string) {
+	m.watched.watch(key)
+}
+
+// WatchPrefix watches every key starting with prefix from the moment it is
+// stored, for data kept one entry per record.
+func (m InMemLib) WatchPrefix(prefix string) {
+	m.watched.watchPrefix(prefix)
+}
+
+// MaxEntrySize is the largest key plus value freecache accepts, 1/1024 of the
+// capacity less its entry header.
+func (m InMemLib) MaxEntrySize() int {
+	return m.size/1024 - freecache.ENTRY_HDR_SIZE
+}
+
+const pingKey = "inmemlib:ping"
+
+// Ping checks the cache is writable by storing and reading back a short-lived probe.
+func (m InMemLib) Ping(ctx context.Context) error {
+	probe := []byte(time.Now().Format(time.RFC3339Nano))
+	if err := m.SetBytes(ctx, pingKey, probe, time.Second); err != nil {
+		return fmt.Errorf("failed to write probe: %w", err)
+	}
+	val, exists, err := m.GetBytes(ctx, pingKey)
+	if err != nil {
+		return fmt.Errorf("failed to read probe: %w", err)
+	}
+	if !exists || !bytes.Equal(val, probe) {
+		return errors.New("probe was not stored")
+	}
+	return nil
+}
+
+// Stats is a snapshot of freecache's counters plus the watched-key evictions.
+type Stats struct {
+	Capacity         int     `json:"capacity"`
+	EntryCount       int64   `json:"entry_count"`
+	HitCount         int64   `json:"hit_count"`
+	MissCount        int64   `json:"miss_count"`
+	HitRate          float64 `json:"hit_rate"`
+	EvacuateCount    int64   `json:"evacuate_count"`
+	ExpiredCount     int64   `json:"expired_count"`
+	OverwriteCount   int64   `json:"overwrite_count"`
+	WatchedEvictions int64   `json:"watched_evictions"`
+}
+
+func (m InMemLib) Stats() Stats {
+	return Stats{
+		Capacity:         m.size,
+		EntryCount:       m.client.EntryCount(),
+		HitCount:         m.client.HitCount(),
+		MissCount:        m.client.MissCount(),
+		HitRate:          m.client.HitRate(),
+		EvacuateCount:    m.client.EvacuateCount(),
+		ExpiredCount:     m.client.ExpiredCount(),
+		OverwriteCount:   m.client.OverwriteCount(),
+		WatchedEvictions: m.watched.evictions.Load(),
+	}
+}
+
+func logEviction(key string) {
+	slog.Warn("inmemlib watched key evicted, consider increasing the cache size", "key", key)
+}
+
+type watchedEntry struct {
+	present  bool
+	expireAt time.Time
+}
+
+// watchlist remembers which watched keys should currently be in the cache.
+type watchlist struct {
+	mu        sync.Mutex
+	keys      map[string]watchedEntry
+	prefixes  []string
+	evictions atomic.Int64
+	evacuated atomic.Int64 // freecache's evacuate count when the keys were last checked
+}
+
+func (w *watchlist) watch(key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.keys[key]; !ok {
+		w.keys[key] = watchedEntry{}
+	}
+}
+
+func (w *watchlist) watchPrefix(prefix string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !slices.Contains(w.prefixes, prefix) {
+		w.prefixes = append(w.prefixes, prefix)
+	}
+}
+
+func (w *watchlist) stored(key string, ttl time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.keys[key]; !ok && !w.prefixed(key) {
+		return
+	}
+	entry := watchedEntry{present: true}
+	if ttl > 0 {
+		entry.expireAt = time.Now().Add(ttl)
+	}
+	w.keys[key] = entry
+}
+
+// prefixed reports whether key falls under a watched prefix. The caller holds
+// the lock.
+func (w *watchlist) prefixed(key string) bool {
+	for _, prefix := range w.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// present lists the watched keys that should be in the cache.
+func (w *watchlist) present() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	keys := make([]string, 0, len(w.keys))
+	for key, entry := range w.keys {
+		if entry.present {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (w *watchlist) deleted(key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.keys[key]; ok {
+		w.keys[key] = watchedEntry{}
+	}
+}
+
+// evicted reports whether a miss on key is an eviction rather than an expiry,
+// and forgets the entry so the same eviction is only reported once.
+func (w *watchlist) evicted(key string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	entry, ok := w.keys[key]
+	if !ok || !entry.present {
+		return false
+	}
+	w.keys[key] = watchedEntry{}
+	if !entry.expireAt.IsZero() && !time.Now().Before(entry.expireAt) {
+		return false
+	}
+	w.evictions.Add(1)
+	return true
+}
+
+// expireSeconds converts a ttl into freecache's whole-second expiry, rounding up
+// so that sub-second TTLs do not turn into "never expire".
+func expireSeconds(ttl time.Duration) int {
+	if ttl <= 0 {
+		return 0
+	}
+	return int((ttl + time.Second - 1) / time.Second)
 }
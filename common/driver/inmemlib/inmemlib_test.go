@@ -0,0 +1,150 @@
+package inmemlib
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchedEviction(t *testing.T) {
+	tests := []struct {
+		name    string
+		watch   func(m InMemLib)
+		key     string
+		ttl     time.Duration
+		delete  bool // The key is deleted before the cache fills up
+		wait    time.Duration
+		want    []string
+		evicted int64
+	}{
+		{
+			name:    "watched key",
+			watch:   func(m InMemLib) { m.Watch("loans:ids:0") },
+			key:     "loans:ids:0",
+			want:    []string{"loans:ids:0"},
+			evicted: 1,
+		},
+		{
+			name:    "key under a watched prefix",
+			watch:   func(m InMemLib) { m.WatchPrefix("loan:") },
+			key:     "loan:1",
+			want:    []string{"loan:1"},
+			evicted: 1,
+		},
+		{
+			name:  "unwatched key",
+			watch: func(m InMemLib) { m.WatchPrefix("loan:") },
+			key:   "borrower:1",
+		},
+		{
+			name:   "deleted key",
+			watch:  func(m InMemLib) { m.Watch("loans:ids:0") },
+			key:    "loans:ids:0",
+			delete: true,
+		},
+		{
+			name:  "expired key",
+			watch: func(m InMemLib) { m.Watch("loans:ids:0") },
+			key:   "loans:ids:0",
+			ttl:   time.Second,
+			wait:  1100 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			var reported []string
+			m := New(WithSize(minSize), WithEvictionHandler(func(key string) {
+				reported = append(reported, key)
+			}))
+			tt.watch(m)
+
+			if err := m.SetBytes(ctx, tt.key, []byte("value"), tt.ttl); err != nil {
+				t.Fatalf("SetBytes() error = %v", err)
+			}
+			if tt.delete {
+				if _, err := m.Delete(ctx, tt.key); err != nil {
+					t.Fatalf("Delete() error = %v", err)
+				}
+			}
+			time.Sleep(tt.wait)
+
+			// Write four times the capacity so that every segment wraps around
+			value := []byte(strings.Repeat("x", 400))
+			for i := 0; i < 4*minSize/len(value); i++ {
+				if err := m.SetBytes(ctx, "filler:"+strconv.Itoa(i), value, 0); err != nil {
+					t.Fatalf("SetBytes() error = %v", err)
+				}
+			}
+			if _, exists, _ := m.GetBytes(ctx, tt.key); exists {
+				t.Fatalf("%s was not evicted by the filler", tt.key)
+			}
+
+			if !reflect.DeepEqual(reported, tt.want) {
+				t.Errorf("evictions reported = %v, want %v", reported, tt.want)
+			}
+			if got := m.Stats().WatchedEvictions; got != tt.evicted {
+				t.Errorf("Stats().WatchedEvictions = %d, want %d", got, tt.evicted)
+			}
+		})
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []Option
+		wantCapacity int
+		wantMaxEntry int
+	}{
+		{
+			name:         "default",
+			wantCapacity: DefaultSize,
+			wantMaxEntry: 10*1024 - 24,
+		},
+		{
+			name:         "configured",
+			opts:         []Option{WithSize(64 * MB)},
+			wantCapacity: 64 * MB,
+			wantMaxEntry: 64*1024 - 24,
+		},
+		{
+			name:         "zero keeps the default",
+			opts:         []Option{WithSize(0)},
+			wantCapacity: DefaultSize,
+			wantMaxEntry: 10*1024 - 24,
+		},
+		{
+			name:         "below the freecache minimum",
+			opts:         []Option{WithSize(1024)},
+			wantCapacity: minSize,
+			wantMaxEntry: 512 - 24,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			m := New(tt.opts...)
+			if got := m.Stats().Capacity; got != tt.wantCapacity {
+				t.Errorf("Stats().Capacity = %d, want %d", got, tt.wantCapacity)
+			}
+			if got := m.MaxEntrySize(); got != tt.wantMaxEntry {
+				t.Errorf("MaxEntrySize() = %d, want %d", got, tt.wantMaxEntry)
+			}
+
+			key := "loan:1"
+			fits := make([]byte, tt.wantMaxEntry-len(key))
+			if err := m.SetBytes(ctx, key, fits, 0); err != nil {
+				t.Errorf("SetBytes() of the largest entry error = %v", err)
+			}
+			err := m.SetBytes(ctx, key, append(fits, 0), 0)
+			if !errors.Is(err, ErrEntryTooLarge) {
+				t.Errorf("SetBytes() of a larger entry error = %v, want %v", err, ErrEntryTooLarge)
+			}
+		})
+	}
+}
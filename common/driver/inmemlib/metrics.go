@@ -0,0 +1,27 @@
+package inmemlib
+
+import "github.com/timotiusas11/amartha-assignment/common/metrics"
+
+// RegisterMetrics exposes the cache counters of m on reg.
+func RegisterMetrics(reg *metrics.Registry, m InMemLibInterface) {
+	reg.MustRegister(
+		metrics.NewCounterFunc("inmemlib_hits_total", "Number of cache lookups that found the key.", func() float64 {
+			return float64(m.Stats().HitCount)
+		}),
+		metrics.NewCounterFunc("inmemlib_misses_total", "Number of cache lookups that missed the key.", func() float64 {
+			return float64(m.Stats().MissCount)
+		}),
+		metrics.NewCounterFunc("inmemlib_evictions_total", "Number of entries evicted to make room for new ones.", func() float64 {
+			return float64(m.Stats().EvacuateCount)
+		}),
+		metrics.NewCounterFunc("inmemlib_expired_total", "Number of entries removed because their TTL passed.", func() float64 {
+			return float64(m.Stats().ExpiredCount)
+		}),
+		metrics.NewCounterFunc("inmemlib_watched_evictions_total", "Number of evictions of keys the application relies on.", func() float64 {
+			return float64(m.Stats().WatchedEvictions)
+		}),
+		metrics.NewGaugeFunc("inmemlib_entries", "Number of entries currently in the cache.", func() float64 {
+			return float64(m.Stats().EntryCount)
+		}),
+	)
+}
@@ -0,0 +1,21 @@
+package nsq
+
+import "github.com/timotiusas11/amartha-assignment/common/metrics"
+
+var publishTotal = metrics.NewCounterVec(
+	"nsq_publish_total",
+	"Number of NSQ publish attempts by channel and result (success or failure).",
+	"channel", "result",
+)
+
+func init() {
+	metrics.Default.MustRegister(publishTotal)
+}
+
+func observePublish(channel string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	publishTotal.WithLabelValues(channel, result).Inc()
+}
@@ -1,38 +1,162 @@
 package nsq
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
 )
 
 // "github.com/nsqio/go-nsq"
 
+// ErrStopped is returned by Send once the producer has been stopped.
+var ErrStopped = errors.New("nsq producer is stopped")
+
 type NSQInterface interface {
-	Send(channel string, value interface{}) error
+	Send(ctx context.Context, channel string, value interface{}) error
+	Stop(ctx context.Context) error
+	Ping(ctx context.Context) error
+}
+
+// Envelope is the wire format of every message. NSQ has no message headers, so
+// metadata such as the W3C traceparent travels next to the body.
+type Envelope struct {
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+type message struct {
+	channel string
+	body    []byte
 }
 
 type NSQ struct {
 	// Client
+	addr   string
+	outbox *outbox
+}
+
+// outbox buffers messages so Send does not block on the broker and pending
+// messages can be flushed on shutdown.
+type outbox struct {
+	mu       sync.RWMutex
+	stopped  bool
+	messages chan message
+	done     chan struct{}
 }
 
-func New() NSQ {
+func New(addr string, outboxSize int) NSQ {
 	// config := nsq.NewConfig()
-	// w, err := nsq.NewProducer("127.0.0.1:4150", config)
+	// w, err := nsq.NewProducer(addr, config)
 	// if err != nil {
 	// 	log.Panic("Error while connecting producer to nsqd")
 	// }
-	return NSQ{
+	n := NSQ{
 		// Set client
+		addr: addr,
+		outbox: &outbox{
+			messages: make(chan message, outboxSize),
+			done:     make(chan struct{}),
+		},
 	}
+	go n.run()
+	return n
 }
 
-func (n NSQ) Send(channel string, value interface{}) error {
-	bvalue, err := json.Marshal(value)
+func (n NSQ) Send(ctx context.Context, channel string, value interface{}) error {
+	ctx, span := tracing.StartWithKind(ctx, tracing.SpanKindProducer, "nsq.Send",
+		tracing.String("messaging.system", "nsq"),
+		tracing.String("messaging.destination", channel),
+	)
+	defer span.End()
+
+	body, err := json.Marshal(value)
 	if err != nil {
+		span.RecordError(err)
+		observePublish(channel, err)
+		return err
+	}
+
+	// Propagate the trace to the consumers
+	headers := tracing.MapCarrier{}
+	tracing.Inject(ctx, headers)
+
+	bvalue, err := json.Marshal(Envelope{Headers: headers, Body: body})
+	if err != nil {
+		span.RecordError(err)
+		observePublish(channel, err)
+		return err
+	}
+
+	n.outbox.mu.RLock()
+	defer n.outbox.mu.RUnlock()
+	if n.outbox.stopped {
+		span.RecordError(ErrStopped)
+		observePublish(channel, ErrStopped)
+		return ErrStopped
+	}
+
+	// Wait for room in the outbox, unless the caller gives up first
+	select {
+	case n.outbox.messages <- message{channel: channel, body: bvalue}:
+		return nil
+	case <-ctx.Done():
+		span.RecordError(ctx.Err())
+		observePublish(channel, ctx.Err())
+		return ctx.Err()
+	}
+}
+
+// Ping reports whether the producer can still accept and publish messages.
+func (n NSQ) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
-	// n.Client.Send(...)
-	fmt.Println("Message sent!")
-	fmt.Println(string(bvalue))
+	n.outbox.mu.RLock()
+	defer n.outbox.mu.RUnlock()
+	if n.outbox.stopped {
+		return ErrStopped
+	}
+	// return n.Client.Ping()
+	return nil
+}
+
+// Stop rejects new messages and waits until every pending message is published
+// or ctx is done.
+func (n NSQ) Stop(ctx context.Context) error {
+	n.outbox.mu.Lock()
+	if !n.outbox.stopped {
+		n.outbox.stopped = true
+		close(n.outbox.messages)
+	}
+	n.outbox.mu.Unlock()
+
+	select {
+	case <-n.outbox.done:
+		// n.Client.Stop()
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("nsq outbox not flushed, %d message(s) pending: %w", len(n.outbox.messages), ctx.Err())
+	}
+}
+
+func (n NSQ) run() {
+	defer close(n.outbox.done)
+	for msg := range n.outbox.messages {
+		err := n.publish(msg)
+		observePublish(msg.channel, err)
+		if err != nil {
+			slog.Error("failed to publish nsq message", "channel", msg.channel, "addr", n.addr, "error", err)
+		}
+	}
+}
+
+func (n NSQ) publish(msg message) error {
+	// return n.Client.Publish(msg.channel, msg.body)
+	slog.Info("nsq message published", "channel", msg.channel, "addr", n.addr, "body", json.RawMessage(msg.body))
 	return nil
 }
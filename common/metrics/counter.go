@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"bufio"
+	"sync"
+)
+
+// Counter is a value that only goes up.
+type Counter struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add increases the counter, ignoring negative deltas.
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+	c.mu.Lock()
+	c.value += delta
+	c.mu.Unlock()
+}
+
+func (c *Counter) Value() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value
+}
+
+type CounterVec struct {
+	*vec[*Counter]
+}
+
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{
+		vec: newVec(name, help, labelNames, func() *Counter { return &Counter{} }),
+	}
+}
+
+func (v *CounterVec) WithLabelValues(values ...string) *Counter {
+	return v.with(values)
+}
+
+func (v *CounterVec) Write(w *bufio.Writer) {
+	v.writeHeader(w, "counter")
+	v.each(func(labels string, c *Counter) {
+		writeSample(w, v.name, labels, c.Value())
+	})
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (g *Gauge) Set(value float64) {
+	g.mu.Lock()
+	g.value = value
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Add(delta float64) {
+	g.mu.Lock()
+	g.value += delta
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Value() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.value
+}
+
+type GaugeVec struct {
+	*vec[*Gauge]
+}
+
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{
+		vec: newVec(name, help, labelNames, func() *Gauge { return &Gauge{} }),
+	}
+}
+
+func (v *GaugeVec) WithLabelValues(values ...string) *Gauge {
+	return v.with(values)
+}
+
+func (v *GaugeVec) Write(w *bufio.Writer) {
+	v.writeHeader(w, "gauge")
+	v.each(func(labels string, g *Gauge) {
+		writeSample(w, v.name, labels, g.Value())
+	})
+}
+
+// Func is a counter or gauge whose value is read on every scrape, for values
+// already tracked elsewhere such as the cache counters.
+type Func struct {
+	name string
+	help string
+	kind string
+	fn   func() float64
+}
+
+func NewCounterFunc(name, help string, fn func() float64) *Func {
+	return &Func{name: name, help: help, kind: "counter", fn: fn}
+}
+
+func NewGaugeFunc(name, help string, fn func() float64) *Func {
+	return &Func{name: name, help: help, kind: "gauge", fn: fn}
+}
+
+func (f *Func) Name() string {
+	return f.name
+}
+
+func (f *Func) Write(w *bufio.Writer) {
+	writeHeader(w, f.name, f.help, f.kind)
+	writeSample(w, f.name, "", f.fn())
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"sort"
+	"sync"
+)
+
+// DefBuckets are the default latency buckets in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (h *Histogram) Observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// Buckets are cumulative, so an observation counts for every bucket whose bound it fits in
+	for i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets); i++ {
+		h.counts[i]++
+	}
+	h.sum += value
+	h.count++
+}
+
+type HistogramVec struct {
+	*vec[*Histogram]
+	buckets []float64
+}
+
+// NewHistogramVec creates a histogram family. Nil buckets means DefBuckets.
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &HistogramVec{
+		vec:     newVec(name, help, labelNames, func() *Histogram { return newHistogram(buckets) }),
+		buckets: buckets,
+	}
+}
+
+func (v *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	return v.with(values)
+}
+
+func (v *HistogramVec) Write(w *bufio.Writer) {
+	v.writeHeader(w, "histogram")
+	v.each(func(labels string, h *Histogram) {
+		h.mu.Lock()
+		counts := append([]uint64(nil), h.counts...)
+		sum, count := h.sum, h.count
+		h.mu.Unlock()
+
+		for i, bound := range v.buckets {
+			writeSample(w, v.name+"_bucket", joinLabels(labels, `le="`+formatFloat(bound)+`"`), float64(counts[i]))
+		}
+		writeSample(w, v.name+"_bucket", joinLabels(labels, `le="`+formatFloat(math.Inf(1))+`"`), float64(count))
+		writeSample(w, v.name+"_sum", labels, sum)
+		writeSample(w, v.name+"_count", labels, float64(count))
+	})
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// vec holds one child per distinct combination of label values.
+type vec[T any] struct {
+	name       string
+	help       string
+	labelNames []string
+	newChild   func() T
+
+	mu       sync.RWMutex
+	children map[string]*labeled[T]
+}
+
+type labeled[T any] struct {
+	values []string
+	child  T
+}
+
+func newVec[T any](name, help string, labelNames []string, newChild func() T) *vec[T] {
+	return &vec[T]{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		newChild:   newChild,
+		children:   make(map[string]*labeled[T]),
+	}
+}
+
+func (v *vec[T]) Name() string {
+	return v.name
+}
+
+func (v *vec[T]) with(values []string) T {
+	if len(values) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labelNames), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mu.RLock()
+	l, ok := v.children[key]
+	v.mu.RUnlock()
+	if ok {
+		return l.child
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if l, ok := v.children[key]; ok {
+		return l.child
+	}
+	l = &labeled[T]{values: append([]string(nil), values...), child: v.newChild()}
+	v.children[key] = l
+	return l.child
+}
+
+// each visits the children ordered by their label values.
+func (v *vec[T]) each(fn func(labels string, child T)) {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.children))
+	for key := range v.children {
+		keys = append(keys, key)
+	}
+	children := make([]*labeled[T], 0, len(keys))
+	sort.Strings(keys)
+	for _, key := range keys {
+		children = append(children, v.children[key])
+	}
+	v.mu.RUnlock()
+
+	for _, l := range children {
+		fn(formatLabels(v.labelNames, l.values), l.child)
+	}
+}
+
+func (v *vec[T]) writeHeader(w *bufio.Writer, kind string) {
+	writeHeader(w, v.name, v.help, kind)
+}
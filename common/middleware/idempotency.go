@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+	MaxIdempotencyKeyLength  = 255
+)
+
+// IdempotencyStore keeps the recorded responses, inmemlib.InMemLib satisfies it.
+type IdempotencyStore interface {
+	SetBytes(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	GetBytes(ctx context.Context, key string) ([]byte, bool, error)
+}
+
+// idempotentResponse is what is stored under an idempotency key.
+type idempotentResponse struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Location    string `json:"location,omitempty"`
+	Body        []byte `json:"body"`
+}
+
+// Idempotency makes POST requests carrying an Idempotency-Key header safe to
+// retry. The first response for a key is stored for ttl and replayed to later
+// requests with the same key, a request reusing the key with another method,
+// path or body is rejected with 422. Keys are namespaced by scope, typically
+// the caller, so that two callers cannot collide. Server errors and abandoned
+// requests are not stored, retrying them runs the handler again. The body is
+// read to fingerprint it, so it must fit in maxBodyBytes, document uploads
+// included, larger ones get 413.
+func Idempotency(store IdempotencyStore, ttl time.Duration, maxBodyBytes int64, scope func(*http.Request) string) Middleware {
+	var (
+		mu       sync.Mutex
+		inFlight = make(map[string]struct{})
+	)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderIdempotencyKey)
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > MaxIdempotencyKeyLength {
+				http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+
+			// Read the body to fingerprint it, then hand a fresh reader to the handler
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Bad request", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			storeKey := "idempotency:" + scope(r) + ":" + key
+			fingerprint := requestFingerprint(r, body)
+
+			// Only one request per key runs at a time
+			mu.Lock()
+			if _, busy := inFlight[storeKey]; busy {
+				mu.Unlock()
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "A request with this Idempotency-Key is still in progress", http.StatusConflict)
+				return
+			}
+			inFlight[storeKey] = struct{}{}
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				delete(inFlight, storeKey)
+				mu.Unlock()
+			}()
+
+			stored, found, err := loadIdempotentResponse(r.Context(), store, storeKey)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "failed to load idempotent response", "error", err)
+				http.Error(w, "Failed to check Idempotency-Key", http.StatusInternalServerError)
+				return
+			}
+			if found {
+				if stored.Fingerprint != fingerprint {
+					http.Error(w, "Idempotency-Key was already used with a different request", http.StatusUnprocessableEntity)
+					return
+				}
+				if stored.ContentType != "" {
+					w.Header().Set("Content-Type", stored.ContentType)
+				}
+				if stored.Location != "" {
+					w.Header().Set("Location", stored.Location)
+				}
+				w.Header().Set(HeaderIdempotentReplayed, "true")
+				w.WriteHeader(stored.Status)
+				w.Write(stored.Body)
+				return
+			}
+
+			recorder := &responseRecorder{statusRecorder: newStatusRecorder(w)}
+			next.ServeHTTP(recorder, r)
+
+			if recorder.status >= http.StatusInternalServerError || r.Context().Err() != nil {
+				return
+			}
+			data, _ := json.Marshal(idempotentResponse{
+				Fingerprint: fingerprint,
+				Status:      recorder.status,
+				ContentType: recorder.Header().Get("Content-Type"),
+				Location:    recorder.Header().Get("Location"),
+				Body:        recorder.body.Bytes(),
+			})
+			// The response was already sent, a retry simply runs the handler again
+			err = store.SetBytes(context.WithoutCancel(r.Context()), storeKey, data, ttl)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "failed to store idempotent response", "error", err)
+			}
+		})
+	}
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.Path+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func loadIdempotentResponse(ctx context.Context, store IdempotencyStore, key string) (idempotentResponse, bool, error) {
+	data, found, err := store.GetBytes(ctx, key)
+	if err != nil || !found {
+		return idempotentResponse{}, false, err
+	}
+	var stored idempotentResponse
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return idempotentResponse{}, false, err
+	}
+	return stored, true, nil
+}
+
+// responseRecorder keeps a copy of the body while writing it through.
+type responseRecorder struct {
+	*statusRecorder
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.statusRecorder.Write(b)
+}
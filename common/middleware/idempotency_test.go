@@ -0,0 +1,276 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testMaxBodyBytes = 1 << 10
+
+// memoryStore is an IdempotencyStore over a map.
+type memoryStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{values: make(map[string][]byte)}
+}
+
+func (s *memoryStore) SetBytes(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	return nil
+}
+
+func (s *memoryStore) GetBytes(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.values[key]
+	return value, ok, nil
+}
+
+func (s *memoryStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.values)
+}
+
+func callerScope(r *http.Request) string {
+	return r.Header.Get("X-Caller")
+}
+
+func idempotentRequest(key, caller, path, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	r.Header.Set(HeaderIdempotencyKey, key)
+	r.Header.Set("X-Caller", caller)
+	return r
+}
+
+func serve(handler http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	var calls atomic.Int64
+	handler := Idempotency(newMemoryStore(), time.Hour, testMaxBodyBytes, callerScope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Location", "/loans/"+strings.Repeat("1", int(n)))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("Loan created successfully"))
+	}))
+
+	first := serve(handler, idempotentRequest("key-1", "101", "/loans", `{"principal_amount":1000}`))
+	second := serve(handler, idempotentRequest("key-1", "101", "/loans", `{"principal_amount":1000}`))
+
+	if calls.Load() != 1 {
+		t.Fatalf("handler called %d times, want 1", calls.Load())
+	}
+	if first.Header().Get(HeaderIdempotentReplayed) != "" {
+		t.Errorf("first response is marked as replayed")
+	}
+	if second.Header().Get(HeaderIdempotentReplayed) != "true" {
+		t.Errorf("%s = %q, want true", HeaderIdempotentReplayed, second.Header().Get(HeaderIdempotentReplayed))
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() || second.Header().Get("Location") != first.Header().Get("Location") {
+		t.Errorf("replay = %d %q %q, want %d %q %q",
+			second.Code, second.Header().Get("Location"), second.Body.String(),
+			first.Code, first.Header().Get("Location"), first.Body.String())
+	}
+
+	// The same key from another caller is another request
+	serve(handler, idempotentRequest("key-1", "102", "/loans", `{"principal_amount":1000}`))
+	if calls.Load() != 2 {
+		t.Errorf("handler called %d times for another caller, want 2", calls.Load())
+	}
+}
+
+func TestIdempotencyFingerprintMismatch(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		body string
+	}{
+		{name: "other body", path: "/loans/1/invest", body: `{"invested_amount":200}`},
+		{name: "other path", path: "/loans/2/invest", body: `{"invested_amount":100}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int64
+			handler := Idempotency(newMemoryStore(), time.Hour, testMaxBodyBytes, callerScope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			serve(handler, idempotentRequest("key-1", "301", "/loans/1/invest", `{"invested_amount":100}`))
+			w := serve(handler, idempotentRequest("key-1", "301", tt.path, tt.body))
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+			}
+			if calls.Load() != 1 {
+				t.Errorf("handler called %d times, want 1", calls.Load())
+			}
+		})
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := Idempotency(newMemoryStore(), time.Hour, testMaxBodyBytes, callerScope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- serve(handler, idempotentRequest("key-1", "301", "/loans/1/invest", `{"invested_amount":100}`))
+	}()
+	<-started
+
+	w := serve(handler, idempotentRequest("key-1", "301", "/loans/1/invest", `{"invested_amount":100}`))
+	if w.Code != http.StatusConflict {
+		t.Errorf("status while in flight = %d, want %d", w.Code, http.StatusConflict)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Errorf("Retry-After header is missing")
+	}
+
+	close(release)
+	if first := <-done; first.Code != http.StatusOK {
+		t.Errorf("first status = %d, want %d", first.Code, http.StatusOK)
+	}
+
+	// Once done, the retry gets the stored response
+	w = serve(handler, idempotentRequest("key-1", "301", "/loans/1/invest", `{"invested_amount":100}`))
+	if w.Code != http.StatusOK || w.Header().Get(HeaderIdempotentReplayed) != "true" {
+		t.Errorf("retry = %d replayed %q, want %d replayed true", w.Code, w.Header().Get(HeaderIdempotentReplayed), http.StatusOK)
+	}
+}
+
+func TestIdempotencyStoredStatuses(t *testing.T) {
+	tests := []struct {
+		status     int
+		wantStored bool
+	}{
+		{status: http.StatusCreated, wantStored: true},
+		{status: http.StatusConflict, wantStored: true},
+		{status: http.StatusInternalServerError, wantStored: false},
+		{status: http.StatusServiceUnavailable, wantStored: false},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			store := newMemoryStore()
+			var calls atomic.Int64
+			handler := Idempotency(store, time.Hour, testMaxBodyBytes, callerScope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tt.status)
+			}))
+
+			for range 2 {
+				if w := serve(handler, idempotentRequest("key-1", "101", "/loans", `{}`)); w.Code != tt.status {
+					t.Fatalf("status = %d, want %d", w.Code, tt.status)
+				}
+			}
+
+			wantCalls := int64(1)
+			if !tt.wantStored {
+				wantCalls = 2
+			}
+			if calls.Load() != wantCalls {
+				t.Errorf("handler called %d times, want %d", calls.Load(), wantCalls)
+			}
+			if stored := store.len() == 1; stored != tt.wantStored {
+				t.Errorf("stored = %v, want %v", stored, tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestIdempotencyPassThrough(t *testing.T) {
+	tests := []struct {
+		name    string
+		request func() *http.Request
+	}{
+		{
+			name:    "without key",
+			request: func() *http.Request { return httptest.NewRequest(http.MethodPost, "/loans", strings.NewReader(`{}`)) },
+		},
+		{
+			name: "not a POST",
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/loans", nil)
+				r.Header.Set(HeaderIdempotencyKey, "key-1")
+				return r
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			var calls atomic.Int64
+			handler := Idempotency(store, time.Hour, testMaxBodyBytes, callerScope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			serve(handler, tt.request())
+			serve(handler, tt.request())
+			if calls.Load() != 2 || store.len() != 0 {
+				t.Errorf("handler called %d times with %d stored, want 2 calls and nothing stored", calls.Load(), store.len())
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeyTooLong(t *testing.T) {
+	handler := Idempotency(newMemoryStore(), time.Hour, testMaxBodyBytes, callerScope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler called for an over-long key")
+	}))
+
+	w := serve(handler, idempotentRequest(strings.Repeat("k", MaxIdempotencyKeyLength+1), "101", "/loans", `{}`))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestIdempotencyBodyTooLarge(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int
+		wantStatus int
+	}{
+		{name: "at the limit", size: testMaxBodyBytes, wantStatus: http.StatusOK},
+		{name: "over the limit", size: testMaxBodyBytes + 1, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received int
+			handler := Idempotency(newMemoryStore(), time.Hour, testMaxBodyBytes, callerScope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				received = len(body)
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			w := serve(handler, idempotentRequest("key-1", "101", "/borrowers/1/documents", strings.Repeat("x", tt.size)))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && received != tt.size {
+				t.Errorf("handler received %d bytes, want %d", received, tt.size)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/common/metrics"
+)
+
+var (
+	httpRequestsTotal = metrics.NewCounterVec(
+		"http_requests_total",
+		"Number of HTTP requests by method, route and status code.",
+		"method", "route", "status",
+	)
+	httpRequestDuration = metrics.NewHistogramVec(
+		"http_request_duration_seconds",
+		"Latency of HTTP requests by method and route.",
+		nil,
+		"method", "route",
+	)
+)
+
+func init() {
+	metrics.Default.MustRegister(httpRequestsTotal, httpRequestDuration)
+}
+
+// Metrics records request counts and latencies labelled with the mux route
+// pattern rather than the raw path, so loan IDs do not explode the label set.
+func Metrics(mux *http.ServeMux) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := newStatusRecorder(w)
+
+			next.ServeHTTP(recorder, r)
+
+			_, route := mux.Handler(r)
+			if route == "" {
+				route = "unmatched"
+			}
+			httpRequestsTotal.WithLabelValues(r.Method, route, strconv.Itoa(recorder.status)).Inc()
+			httpRequestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+		})
+	}
+}
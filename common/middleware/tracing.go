@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/timotiusas11/amartha-assignment/common/logger"
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+)
+
+// Tracing starts a server span per request, continuing the caller's trace when
+// a traceparent header is present.
+func Tracing(mux *http.ServeMux) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, route := mux.Handler(r)
+			if route == "" {
+				route = "unmatched"
+			}
+
+			ctx := tracing.Extract(r.Context(), tracing.HeaderCarrier(r.Header))
+			ctx, span := tracing.StartWithKind(ctx, tracing.SpanKindServer, r.Method+" "+route,
+				tracing.String("http.method", r.Method),
+				tracing.String("http.route", route),
+				tracing.String("http.target", r.URL.Path),
+				tracing.String("request_id", logger.RequestID(ctx)),
+			)
+			defer span.End()
+
+			recorder := newStatusRecorder(w)
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			span.SetAttributes(tracing.Int64("http.status_code", int64(recorder.status)))
+			if recorder.status >= http.StatusInternalServerError {
+				span.RecordError(errorStatus(recorder.status))
+			}
+		})
+	}
+}
+
+type errorStatus int
+
+func (e errorStatus) Error() string {
+	return http.StatusText(int(e))
+}
@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Exporter sends batches of ended spans to a backend.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+const (
+	queueSize     = 2048
+	batchSize     = 512
+	flushInterval = 5 * time.Second
+)
+
+// Provider batches ended spans and exports them in the background.
+type Provider struct {
+	exporter Exporter
+
+	mu      sync.RWMutex
+	stopped bool
+	queue   chan SpanData
+	done    chan struct{}
+}
+
+func NewProvider(exporter Exporter) *Provider {
+	p := &Provider{
+		exporter: exporter,
+		queue:    make(chan SpanData, queueSize),
+		done:     make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+var global atomic.Pointer[Provider]
+
+// SetProvider installs the provider used by Start.
+func SetProvider(p *Provider) {
+	global.Store(p)
+}
+
+func currentProvider() *Provider {
+	return global.Load()
+}
+
+func (p *Provider) enqueue(data SpanData) {
+	if p == nil || p.exporter == nil {
+		return
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		return
+	}
+	select {
+	case p.queue <- data:
+	default:
+		// Never block the request path on a slow exporter
+	}
+}
+
+func (p *Provider) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 || p.exporter == nil {
+			return
+		}
+		if err := p.exporter.Export(context.Background(), batch); err != nil {
+			slog.Warn("failed to export spans", "spans", len(batch), "error", err)
+		}
+		batch = make([]SpanData, 0, batchSize)
+	}
+
+	for {
+		select {
+		case data, ok := <-p.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, data)
+			if len(batch) == batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Shutdown stops accepting spans and exports the queued ones, until ctx is done.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (e *recordingExporter) Export(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// record installs a provider exporting to a recording exporter for the test,
+// and returns a function that shuts it down and returns the exported spans.
+func record(t *testing.T) func() []SpanData {
+	t.Helper()
+	exporter := &recordingExporter{}
+	provider := NewProvider(exporter)
+	previous := currentProvider()
+	SetProvider(provider)
+	t.Cleanup(func() { SetProvider(previous) })
+
+	return func() []SpanData {
+		if err := provider.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Shutdown() error = %v", err)
+		}
+		exporter.mu.Lock()
+		defer exporter.mu.Unlock()
+		return exporter.spans
+	}
+}
+
+func TestSpanRecordError(t *testing.T) {
+	tests := []struct {
+		name string
+		use  func(span *Span)
+		want string
+	}{
+		{
+			name: "no error",
+			use:  func(span *Span) { span.End() },
+		},
+		{
+			name: "nil error is ignored",
+			use: func(span *Span) {
+				span.RecordError(nil)
+				span.End()
+			},
+		},
+		{
+			name: "error",
+			use: func(span *Span) {
+				span.RecordError(errors.New("loan not found"))
+				span.End()
+			},
+			want: "loan not found",
+		},
+		{
+			name: "last error wins",
+			use: func(span *Span) {
+				span.RecordError(errors.New("first"))
+				span.RecordError(errors.New("second"))
+				span.End()
+			},
+			want: "second",
+		},
+		{
+			name: "error after End is not exported",
+			use: func(span *Span) {
+				span.End()
+				span.RecordError(errors.New("too late"))
+				span.End()
+			},
+		},
+		{
+			name: "deferred like the usecases do",
+			use: func(span *Span) {
+				func() (err error) {
+					defer func() {
+						span.RecordError(err)
+						span.End()
+					}()
+					return errors.New("invalid state")
+				}()
+			},
+			want: "invalid state",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exported := record(t)
+			_, span := Start(context.Background(), "usecase.Invest", Int64("loan_id", 1))
+			tt.use(span)
+
+			spans := exported()
+			if len(spans) != 1 {
+				t.Fatalf("exported %d spans, want 1", len(spans))
+			}
+			if spans[0].Error != tt.want {
+				t.Errorf("span error = %q, want %q", spans[0].Error, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartChild(t *testing.T) {
+	exported := record(t)
+	ctx, parent := Start(context.Background(), "delivery.Invest")
+	_, child := Start(ctx, "usecase.Invest")
+	child.RecordError(errors.New("insufficient funds"))
+	child.End()
+	parent.End()
+
+	spans := exported()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	got, root := spans[0], spans[1]
+	if got.TraceID != root.TraceID || got.ParentSpanID != root.SpanID {
+		t.Errorf("child span %s/%s has parent %s, want trace %s and parent %s", got.TraceID, got.SpanID, got.ParentSpanID, root.TraceID, root.SpanID)
+	}
+	if got.Error == "" || root.Error != "" {
+		t.Errorf("errors = %q on the child and %q on the parent, want the child's only", got.Error, root.Error)
+	}
+
+	// Spans without a provider and nil spans are no-ops
+	SetProvider(nil)
+	_, span := Start(context.Background(), "orphan")
+	span.RecordError(errors.New("ignored"))
+	span.End()
+	var nilSpan *Span
+	nilSpan.RecordError(errors.New("ignored"))
+	nilSpan.End()
+}
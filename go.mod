@@ -2,6 +2,10 @@ module github.com/timotiusas11/amartha-assignment
 
 go 1.22.0
 
-require github.com/coocood/freecache v1.2.4
+require (
+	github.com/coocood/freecache v1.2.4
+	golang.org/x/sync v0.9.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require github.com/cespare/xxhash/v2 v2.1.2 // indirect
@@ -0,0 +1,307 @@
+// Package archive encodes the loan book as a versioned JSON-lines archive.
+//
+// The first line is a header with the format version, then one line per loan,
+// per event, per borrower, per wallet entry and per journal entry, each
+// carrying the SHA-256 of its data, and last a trailer with the record counts
+// and the SHA-256 of every line before it:
+//
+//	{"kind":"header","version":4,"created_at":"...","last_event_id":42}
+//	{"kind":"loan","data":{...},"sha256":"..."}
+//	{"kind":"event","data":{...},"sha256":"..."}
+//	{"kind":"borrower","data":{...},"sha256":"..."}
+//	{"kind":"wallet_entry","data":{...},"sha256":"..."}
+//	{"kind":"journal_entry","data":{...},"sha256":"..."}
+//	{"kind":"trailer","loans":1,"events":42,"borrowers":1,"wallet_entries":7,"journal_entries":9,"sha256":"..."}
+//
+// Version 1 archives predate the borrower registry and have no borrowers,
+// version 2 archives predate the wallets and have no wallet entries, version
+// 3 archives predate the ledger and have no journal entries.
+package archive
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/internal/ledger"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+// Version is the format written by Write. Read accepts it and every earlier
+// version.
+const Version = 4
+
+// ErrInvalidArchive wraps every reason an archive is rejected.
+var ErrInvalidArchive = errors.New("invalid archive")
+
+const (
+	kindHeader   = "header"
+	kindLoan     = "loan"
+	kindEvent    = "event"
+	kindBorrower = "borrower"
+	kindWallet   = "wallet_entry"
+	kindJournal  = "journal_entry"
+	kindTrailer  = "trailer"
+)
+
+// maxLineBytes bounds a single record, loans and events are far smaller.
+const maxLineBytes = 16 << 20
+
+// Snapshot is the content of an archive.
+type Snapshot struct {
+	CreatedAt     time.Time
+	LastEventID   int64
+	Loans         []model.Loan
+	Events        []model.Event
+	Borrowers     []model.Borrower
+	WalletEntries []model.WalletEntry
+	Journal       []ledger.Entry
+	Checksum      string // SHA-256 of the archive from its trailer, set by Read
+}
+
+type line struct {
+	Kind           string          `json:"kind"`
+	Version        int             `json:"version,omitempty"`
+	CreatedAt      *time.Time      `json:"created_at,omitempty"`
+	LastEventID    int64           `json:"last_event_id,omitempty"`
+	Data           json.RawMessage `json:"data,omitempty"`
+	Loans          int             `json:"loans,omitempty"`
+	Events         int             `json:"events,omitempty"`
+	Borrowers      int             `json:"borrowers,omitempty"`
+	WalletEntries  int             `json:"wallet_entries,omitempty"`
+	JournalEntries int             `json:"journal_entries,omitempty"`
+	SHA256         string          `json:"sha256,omitempty"`
+}
+
+// Write encodes snapshot to w.
+func Write(w io.Writer, snapshot Snapshot) error {
+	buf := bufio.NewWriter(w)
+	digest := sha256.New()
+	out := io.MultiWriter(buf, digest)
+
+	writeLine := func(l line) error {
+		data, err := json.Marshal(l)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(append(data, '\n'))
+		return err
+	}
+
+	createdAt := snapshot.CreatedAt
+	err := writeLine(line{Kind: kindHeader, Version: Version, CreatedAt: &createdAt, LastEventID: snapshot.LastEventID})
+	if err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	for _, loan := range snapshot.Loans {
+		if err := writeRecord(writeLine, kindLoan, loan); err != nil {
+			return fmt.Errorf("failed to write loan %d: %w", loan.LoanID, err)
+		}
+	}
+	for _, event := range snapshot.Events {
+		if err := writeRecord(writeLine, kindEvent, event); err != nil {
+			return fmt.Errorf("failed to write event %d: %w", event.EventID, err)
+		}
+	}
+	for _, borrower := range snapshot.Borrowers {
+		if err := writeRecord(writeLine, kindBorrower, borrower); err != nil {
+			return fmt.Errorf("failed to write borrower %d: %w", borrower.BorrowerID, err)
+		}
+	}
+	for _, entry := range snapshot.WalletEntries {
+		if err := writeRecord(writeLine, kindWallet, entry); err != nil {
+			return fmt.Errorf("failed to write wallet entry %d: %w", entry.EntryID, err)
+		}
+	}
+	for _, entry := range snapshot.Journal {
+		if err := writeRecord(writeLine, kindJournal, entry); err != nil {
+			return fmt.Errorf("failed to write journal entry %d: %w", entry.EntryID, err)
+		}
+	}
+
+	// The trailer checksum covers everything above it, so it is written to buf only
+	trailer, err := json.Marshal(line{
+		Kind:           kindTrailer,
+		Loans:          len(snapshot.Loans),
+		Events:         len(snapshot.Events),
+		Borrowers:      len(snapshot.Borrowers),
+		WalletEntries:  len(snapshot.WalletEntries),
+		JournalEntries: len(snapshot.Journal),
+		SHA256:         hex.EncodeToString(digest.Sum(nil)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write trailer: %w", err)
+	}
+	if _, err := buf.Write(append(trailer, '\n')); err != nil {
+		return fmt.Errorf("failed to write trailer: %w", err)
+	}
+	return buf.Flush()
+}
+
+func writeRecord(writeLine func(line) error, kind string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return writeLine(line{Kind: kind, Data: data, SHA256: checksum(data)})
+}
+
+// Read decodes and verifies an archive. It rejects unknown versions, corrupted
+// or missing records, duplicate loan, event, borrower, wallet or journal entry
+// IDs and truncated archives.
+func Read(r io.Reader) (Snapshot, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+	digest := sha256.New()
+
+	var (
+		snapshot    Snapshot
+		version     int
+		lineNumber  int
+		header      bool
+		trailer     *line
+		loanIDs     = make(map[int64]bool)
+		eventIDs    = make(map[int64]bool)
+		borrowerIDs = make(map[int64]bool)
+		entryIDs    = make(map[int64]bool)
+		journalIDs  = make(map[int64]bool)
+	)
+	for scanner.Scan() {
+		lineNumber++
+		raw := scanner.Bytes()
+		if trailer != nil {
+			return Snapshot{}, invalid(lineNumber, "data after the trailer")
+		}
+
+		var l line
+		if err := json.Unmarshal(raw, &l); err != nil {
+			return Snapshot{}, invalid(lineNumber, "malformed line: %v", err)
+		}
+
+		switch {
+		case !header && l.Kind != kindHeader:
+			return Snapshot{}, invalid(lineNumber, "archive must start with a header")
+		case l.Kind == kindHeader:
+			if header {
+				return Snapshot{}, invalid(lineNumber, "duplicate header")
+			}
+			if l.Version < 1 || l.Version > Version {
+				return Snapshot{}, invalid(lineNumber, "unsupported version %d, expected at most %d", l.Version, Version)
+			}
+			header = true
+			version = l.Version
+			if l.CreatedAt != nil {
+				snapshot.CreatedAt = *l.CreatedAt
+			}
+			snapshot.LastEventID = l.LastEventID
+		case l.Kind == kindTrailer:
+			trailer = &l
+			continue // Not part of its own checksum
+		case l.Kind == kindLoan:
+			var loan model.Loan
+			if err := decodeRecord(l, &loan); err != nil {
+				return Snapshot{}, invalid(lineNumber, "%v", err)
+			}
+			if loan.LoanID == 0 || loanIDs[loan.LoanID] {
+				return Snapshot{}, invalid(lineNumber, "missing or duplicate loan ID %d", loan.LoanID)
+			}
+			loanIDs[loan.LoanID] = true
+			snapshot.Loans = append(snapshot.Loans, loan)
+		case l.Kind == kindEvent:
+			var event model.Event
+			if err := decodeRecord(l, &event); err != nil {
+				return Snapshot{}, invalid(lineNumber, "%v", err)
+			}
+			if event.EventID <= 0 || eventIDs[event.EventID] {
+				return Snapshot{}, invalid(lineNumber, "missing or duplicate event ID %d", event.EventID)
+			}
+			eventIDs[event.EventID] = true
+			snapshot.Events = append(snapshot.Events, event)
+		case l.Kind == kindBorrower && version >= 2:
+			var borrower model.Borrower
+			if err := decodeRecord(l, &borrower); err != nil {
+				return Snapshot{}, invalid(lineNumber, "%v", err)
+			}
+			if borrower.BorrowerID == 0 || borrowerIDs[borrower.BorrowerID] {
+				return Snapshot{}, invalid(lineNumber, "missing or duplicate borrower ID %d", borrower.BorrowerID)
+			}
+			borrowerIDs[borrower.BorrowerID] = true
+			snapshot.Borrowers = append(snapshot.Borrowers, borrower)
+		case l.Kind == kindWallet && version >= 3:
+			var entry model.WalletEntry
+			if err := decodeRecord(l, &entry); err != nil {
+				return Snapshot{}, invalid(lineNumber, "%v", err)
+			}
+			if entry.EntryID <= 0 || entryIDs[entry.EntryID] {
+				return Snapshot{}, invalid(lineNumber, "missing or duplicate wallet entry ID %d", entry.EntryID)
+			}
+			entryIDs[entry.EntryID] = true
+			snapshot.WalletEntries = append(snapshot.WalletEntries, entry)
+		case l.Kind == kindJournal && version >= 4:
+			var entry ledger.Entry
+			if err := decodeRecord(l, &entry); err != nil {
+				return Snapshot{}, invalid(lineNumber, "%v", err)
+			}
+			if entry.EntryID <= 0 || journalIDs[entry.EntryID] {
+				return Snapshot{}, invalid(lineNumber, "missing or duplicate journal entry ID %d", entry.EntryID)
+			}
+			if err := entry.Validate(); err != nil {
+				return Snapshot{}, invalid(lineNumber, "journal entry %d: %v", entry.EntryID, err)
+			}
+			journalIDs[entry.EntryID] = true
+			snapshot.Journal = append(snapshot.Journal, entry)
+		default:
+			return Snapshot{}, invalid(lineNumber, "unknown record kind %q", l.Kind)
+		}
+
+		writeDigest(digest, raw)
+	}
+	if err := scanner.Err(); err != nil {
+		return Snapshot{}, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+
+	if trailer == nil {
+		return Snapshot{}, fmt.Errorf("%w: missing trailer, the archive is truncated", ErrInvalidArchive)
+	}
+	if trailer.Loans != len(snapshot.Loans) || trailer.Events != len(snapshot.Events) || trailer.Borrowers != len(snapshot.Borrowers) ||
+		trailer.WalletEntries != len(snapshot.WalletEntries) || trailer.JournalEntries != len(snapshot.Journal) {
+		return Snapshot{}, fmt.Errorf("%w: trailer expects %d loans, %d events, %d borrowers, %d wallet entries and %d journal entries, found %d, %d, %d, %d and %d",
+			ErrInvalidArchive, trailer.Loans, trailer.Events, trailer.Borrowers, trailer.WalletEntries, trailer.JournalEntries,
+			len(snapshot.Loans), len(snapshot.Events), len(snapshot.Borrowers), len(snapshot.WalletEntries), len(snapshot.Journal))
+	}
+	if trailer.SHA256 != hex.EncodeToString(digest.Sum(nil)) {
+		return Snapshot{}, fmt.Errorf("%w: archive checksum mismatch", ErrInvalidArchive)
+	}
+	snapshot.Checksum = trailer.SHA256
+	return snapshot, nil
+}
+
+func decodeRecord(l line, value interface{}) error {
+	if checksum(l.Data) != l.SHA256 {
+		return fmt.Errorf("%s checksum mismatch", l.Kind)
+	}
+	if err := json.Unmarshal(l.Data, value); err != nil {
+		return fmt.Errorf("malformed %s: %v", l.Kind, err)
+	}
+	return nil
+}
+
+func writeDigest(digest hash.Hash, raw []byte) {
+	digest.Write(raw)
+	digest.Write([]byte{'\n'})
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func invalid(lineNumber int, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: line %d: %s", ErrInvalidArchive, lineNumber, fmt.Sprintf(format, args...))
+}
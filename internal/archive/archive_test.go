@@ -0,0 +1,255 @@
+package archive
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/internal/ledger"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+func testSnapshot() Snapshot {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	deposit := ledger.Deposit(7, 250)
+	deposit.EntryID = 1
+	deposit.OccurredAt = createdAt
+	return Snapshot{
+		CreatedAt:   createdAt,
+		LastEventID: 2,
+		Loans: []model.Loan{
+			{LoanID: 1, BorrowerID: 3, PrincipalAmount: 1000, Rate: 10, ROI: 8, State: model.StateEnumProposed},
+			{LoanID: 2, BorrowerID: 3, PrincipalAmount: 500, Rate: 12, ROI: 9, State: model.StateEnumApproved},
+		},
+		Events: []model.Event{
+			{EventID: 1, LoanID: 1, Type: model.EventLoanCreated, ActorID: 3, OccurredAt: createdAt},
+			{EventID: 2, LoanID: 2, Type: model.EventLoanCreated, ActorID: 3, OccurredAt: createdAt},
+		},
+		Borrowers:     []model.Borrower{{BorrowerID: 3, Name: "Borrower"}},
+		WalletEntries: []model.WalletEntry{withEntryID(model.NewWalletEntry(7, 0, model.WalletEntryDeposit, 250), 1)},
+		Journal:       []ledger.Entry{deposit},
+	}
+}
+
+func withEntryID(entry model.WalletEntry, entryID int64) model.WalletEntry {
+	entry.EntryID = entryID
+	return entry
+}
+
+func mustWrite(t *testing.T, snapshot Snapshot) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Write(&buf, snapshot); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+// record is a line of a hand-built archive.
+type record struct {
+	kind  string
+	value interface{}
+}
+
+// build encodes records the way an archive of version would hold them, with a
+// valid checksum on every record and on the trailer.
+func build(t *testing.T, version int, records ...record) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	digest := sha256.New()
+	writeLine := func(l line) {
+		data, err := json.Marshal(l)
+		if err != nil {
+			t.Fatalf("json.Marshal() error = %v", err)
+		}
+		buf.Write(append(data, '\n'))
+		writeDigest(digest, data)
+	}
+
+	createdAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	writeLine(line{Kind: kindHeader, Version: version, CreatedAt: &createdAt, LastEventID: 1})
+	counts := make(map[string]int)
+	for _, r := range records {
+		data, err := json.Marshal(r.value)
+		if err != nil {
+			t.Fatalf("json.Marshal() error = %v", err)
+		}
+		writeLine(line{Kind: r.kind, Data: data, SHA256: checksum(data)})
+		counts[r.kind]++
+	}
+
+	trailer, err := json.Marshal(line{
+		Kind:           kindTrailer,
+		Loans:          counts[kindLoan],
+		Events:         counts[kindEvent],
+		Borrowers:      counts[kindBorrower],
+		WalletEntries:  counts[kindWallet],
+		JournalEntries: counts[kindJournal],
+		SHA256:         hex.EncodeToString(digest.Sum(nil)),
+	})
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	buf.Write(append(trailer, '\n'))
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	snapshot := testSnapshot()
+	data := mustWrite(t, snapshot)
+
+	got, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if got.Checksum == "" {
+		t.Errorf("Read() left Checksum empty")
+	}
+
+	// Written again, the snapshot read back gives the same archive
+	got.Checksum = ""
+	if again := mustWrite(t, got); !bytes.Equal(again, data) {
+		t.Errorf("archive changed over a round trip:\n%s\nwant\n%s", again, data)
+	}
+	if !got.CreatedAt.Equal(snapshot.CreatedAt) || got.LastEventID != snapshot.LastEventID {
+		t.Errorf("Read() header = %v %d, want %v %d", got.CreatedAt, got.LastEventID, snapshot.CreatedAt, snapshot.LastEventID)
+	}
+	if len(got.Loans) != 2 || len(got.Events) != 2 || len(got.Borrowers) != 1 || len(got.WalletEntries) != 1 || len(got.Journal) != 1 {
+		t.Errorf("Read() = %d loans, %d events, %d borrowers, %d wallet entries and %d journal entries, want 2, 2, 1, 1 and 1",
+			len(got.Loans), len(got.Events), len(got.Borrowers), len(got.WalletEntries), len(got.Journal))
+	}
+}
+
+func TestReadRejects(t *testing.T) {
+	valid := string(mustWrite(t, testSnapshot()))
+	lines := strings.SplitAfter(strings.TrimSuffix(valid, "\n"), "\n")
+	loan := model.Loan{LoanID: 1, BorrowerID: 3, PrincipalAmount: 1000}
+	event := model.Event{EventID: 1, LoanID: 1, Type: model.EventLoanCreated}
+
+	tests := []struct {
+		name    string
+		archive string
+	}{
+		{
+			name:    "corrupted record",
+			archive: strings.Replace(valid, `"principal_amount":1000`, `"principal_amount":9000`, 1),
+		},
+		{
+			name: "corrupted trailer checksum",
+			archive: func() string {
+				trailer := lines[len(lines)-1]
+				i := strings.LastIndex(trailer, `"sha256":"`) + len(`"sha256":"`)
+				flipped := "0"
+				if trailer[i] == '0' {
+					flipped = "1"
+				}
+				return strings.Join(lines[:len(lines)-1], "") + trailer[:i] + flipped + trailer[i+1:]
+			}(),
+		},
+		{
+			name:    "truncated before the trailer",
+			archive: strings.Join(lines[:len(lines)-1], ""),
+		},
+		{
+			name:    "truncated mid record",
+			archive: strings.Join(lines[:2], "") + lines[2][:len(lines[2])/2],
+		},
+		{
+			name:    "record dropped",
+			archive: lines[0] + strings.Join(lines[2:], ""),
+		},
+		{
+			name:    "data after the trailer",
+			archive: valid + lines[1],
+		},
+		{
+			name:    "empty",
+			archive: "",
+		},
+		{
+			name:    "duplicate loan ID",
+			archive: string(build(t, Version, record{kindLoan, loan}, record{kindLoan, loan})),
+		},
+		{
+			name:    "duplicate event ID",
+			archive: string(build(t, Version, record{kindEvent, event}, record{kindEvent, event})),
+		},
+		{
+			name: "duplicate borrower ID",
+			archive: string(build(t, Version,
+				record{kindBorrower, model.Borrower{BorrowerID: 3}}, record{kindBorrower, model.Borrower{BorrowerID: 3}})),
+		},
+		{
+			name:    "missing loan ID",
+			archive: string(build(t, Version, record{kindLoan, model.Loan{PrincipalAmount: 1000}})),
+		},
+		{
+			name: "unbalanced journal entry",
+			archive: string(build(t, Version, record{kindJournal, ledger.Entry{EntryID: 1, Type: ledger.EntryDeposit, Postings: []ledger.Posting{
+				{Account: ledger.AccountCash, Debit: 100},
+				{Account: ledger.InvestorWallet(7), Credit: 90},
+			}}})),
+		},
+		{
+			name:    "newer version",
+			archive: string(build(t, Version+1)),
+		},
+		{
+			name:    "borrowers in a version 1 archive",
+			archive: string(build(t, 1, record{kindBorrower, model.Borrower{BorrowerID: 3}})),
+		},
+		{
+			name:    "wallet entries in a version 2 archive",
+			archive: string(build(t, 2, record{kindWallet, withEntryID(model.NewWalletEntry(7, 0, model.WalletEntryDeposit, 250), 1)})),
+		},
+		{
+			name:    "journal entries in a version 3 archive",
+			archive: string(build(t, 3, record{kindJournal, testSnapshot().Journal[0]})),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.archive))
+			if !errors.Is(err, ErrInvalidArchive) {
+				t.Fatalf("Read() error = %v, want %v", err, ErrInvalidArchive)
+			}
+		})
+	}
+}
+
+func TestReadEarlierVersions(t *testing.T) {
+	loan := record{kindLoan, model.Loan{LoanID: 1, BorrowerID: 3, PrincipalAmount: 1000}}
+	event := record{kindEvent, model.Event{EventID: 1, LoanID: 1, Type: model.EventLoanCreated}}
+	borrower := record{kindBorrower, model.Borrower{BorrowerID: 3}}
+	walletEntry := record{kindWallet, withEntryID(model.NewWalletEntry(7, 0, model.WalletEntryDeposit, 250), 1)}
+
+	tests := []struct {
+		version       int
+		records       []record
+		borrowers     int
+		walletEntries int
+	}{
+		{version: 1, records: []record{loan, event}},
+		{version: 2, records: []record{loan, event, borrower}, borrowers: 1},
+		{version: 3, records: []record{loan, event, borrower, walletEntry}, borrowers: 1, walletEntries: 1},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("version %d", tt.version), func(t *testing.T) {
+			got, err := Read(bytes.NewReader(build(t, tt.version, tt.records...)))
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if len(got.Loans) != 1 || len(got.Events) != 1 || len(got.Borrowers) != tt.borrowers ||
+				len(got.WalletEntries) != tt.walletEntries || len(got.Journal) != 0 {
+				t.Errorf("Read() = %d loans, %d events, %d borrowers, %d wallet entries and %d journal entries, want 1, 1, %d, %d and 0",
+					len(got.Loans), len(got.Events), len(got.Borrowers), len(got.WalletEntries), len(got.Journal), tt.borrowers, tt.walletEntries)
+			}
+		})
+	}
+}
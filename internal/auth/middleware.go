@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Authenticate rejects requests without a valid bearer token and stores the
+// token's principal in the request context.
+func Authenticate(verifier *Verifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" {
+				unauthorized(w, "Missing bearer token")
+				return
+			}
+
+			principal, err := verifier.Verify(strings.TrimSpace(token))
+			if err != nil {
+				slog.WarnContext(r.Context(), "rejected bearer token", "error", err)
+				unauthorized(w, "Invalid bearer token")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+func unauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="loan-service"`)
+	http.Error(w, message, http.StatusUnauthorized)
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+)
+
+type Role string
+
+const (
+	RoleBorrower       Role = "borrower"
+	RoleFieldValidator Role = "field_validator"
+	RoleInvestor       Role = "investor"
+	RoleFieldOfficer   Role = "field_officer"
+	RoleAdmin          Role = "admin"
+)
+
+type Permission string
+
+const (
+	PermissionCreateLoan     Permission = "loan:create"
+	PermissionReadLoan       Permission = "loan:read"
+	PermissionApproveLoan    Permission = "loan:approve"
+	PermissionInvestLoan     Permission = "loan:invest"
+	PermissionDisburseLoan   Permission = "loan:disburse"
+	PermissionCancelLoan     Permission = "loan:cancel"
+	PermissionReadEvents     Permission = "loan:events"
+	PermissionUploadDocument Permission = "document:upload"
+	PermissionReadDocuments  Permission = "document:read"
+	PermissionWriteBorrower  Permission = "borrower:write"
+	PermissionReadBorrower   Permission = "borrower:read"
+	PermissionReviewKYC      Permission = "borrower:kyc"
+	PermissionRepayLoan      Permission = "loan:repay"
+	PermissionReadWallet     Permission = "wallet:read"
+	PermissionDepositWallet  Permission = "wallet:deposit"
+	PermissionAdmin          Permission = "admin"
+)
+
+// rolePermissions is the single source of truth of who may do what. Ownership
+// rules, such as only the owning borrower cancelling a loan, are enforced by
+// the usecase on top of these permissions.
+var rolePermissions = map[Role][]Permission{
+	RoleBorrower:       {PermissionCreateLoan, PermissionReadLoan, PermissionCancelLoan, PermissionReadEvents, PermissionUploadDocument, PermissionReadDocuments, PermissionWriteBorrower, PermissionReadBorrower, PermissionRepayLoan},
+	RoleFieldValidator: {PermissionReadLoan, PermissionApproveLoan, PermissionReadEvents, PermissionUploadDocument, PermissionReadDocuments, PermissionReadBorrower, PermissionReviewKYC},
+	RoleInvestor:       {PermissionReadLoan, PermissionInvestLoan, PermissionReadWallet, PermissionDepositWallet},
+	RoleFieldOfficer:   {PermissionReadLoan, PermissionDisburseLoan, PermissionReadEvents, PermissionUploadDocument, PermissionReadDocuments, PermissionReadBorrower, PermissionRepayLoan},
+	RoleAdmin:          {PermissionReadLoan, PermissionCancelLoan, PermissionReadEvents, PermissionUploadDocument, PermissionReadDocuments, PermissionWriteBorrower, PermissionReadBorrower, PermissionReviewKYC, PermissionReadWallet, PermissionDepositWallet, PermissionAdmin},
+}
+
+func (p Principal) HasRole(role Role) bool {
+	return slices.Contains(p.Roles, string(role))
+}
+
+// Can reports whether any of the principal's roles grants permission.
+func (p Principal) Can(permission Permission) bool {
+	for _, role := range p.Roles {
+		if slices.Contains(rolePermissions[Role(role)], permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// Require rejects authenticated requests whose principal lacks permission.
+// It must run after Authenticate.
+func Require(permission Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				unauthorized(w, "Missing bearer token")
+				return
+			}
+			if !principal.Can(permission) {
+				slog.WarnContext(r.Context(), "permission denied",
+					"actor_id", principal.ActorID,
+					"roles", principal.Roles,
+					"permission", string(permission),
+				)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
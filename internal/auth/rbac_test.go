@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequire(t *testing.T) {
+	tests := []struct {
+		name       string
+		principal  *Principal
+		permission Permission
+		wantStatus int
+	}{
+		{
+			name:       "unauthenticated",
+			permission: PermissionReadLoan,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "granted",
+			principal:  &Principal{ActorID: 1, Roles: []string{string(RoleInvestor)}},
+			permission: PermissionInvestLoan,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "granted by one of several roles",
+			principal:  &Principal{ActorID: 1, Roles: []string{string(RoleInvestor), string(RoleBorrower)}},
+			permission: PermissionCreateLoan,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "denied",
+			principal:  &Principal{ActorID: 1, Roles: []string{string(RoleBorrower)}},
+			permission: PermissionInvestLoan,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "unknown role",
+			principal:  &Principal{ActorID: 1, Roles: []string{"superuser"}},
+			permission: PermissionReadLoan,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Require(tt.permission)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/loans", nil)
+			if tt.principal != nil {
+				r = r.WithContext(WithPrincipal(r.Context(), *tt.principal))
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("WWW-Authenticate header is missing")
+			}
+		})
+	}
+}
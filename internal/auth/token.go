@@ -0,0 +1,200 @@
+// Package auth verifies HS256-signed JWT bearer tokens and carries the
+// authenticated principal through the request context.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformedToken = errors.New("malformed token")
+	ErrInvalidToken   = errors.New("invalid token")
+	ErrExpiredToken   = errors.New("token is expired or not yet valid")
+)
+
+// MinKeyLength is the minimum HMAC secret length, matching the SHA-256 output size.
+const MinKeyLength = 32
+
+// leeway tolerates clock skew between the token issuer and this service.
+const leeway = 30 * time.Second
+
+// Claims are the registered JWT claims used by the service plus the roles.
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+}
+
+// Audience is the aud claim. JWTs carry it as a single string or as an array
+// of strings, both are accepted.
+type Audience []string
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("aud must be a string or an array of strings: %w", err)
+	}
+	*a = many
+	return nil
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// Verifier checks tokens against a set of keys identified by their key ID, so
+// keys can be rotated by configuring the new and the old one side by side.
+type Verifier struct {
+	keys     map[string][]byte
+	issuer   string
+	audience string
+	now      func() time.Time
+}
+
+// NewVerifier creates a verifier. Empty issuer or audience are not checked.
+func NewVerifier(keys map[string]string, issuer, audience string) (*Verifier, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one signing key is required")
+	}
+	v := &Verifier{
+		keys:     make(map[string][]byte, len(keys)),
+		issuer:   issuer,
+		audience: audience,
+		now:      time.Now,
+	}
+	for kid, secret := range keys {
+		if len(secret) < MinKeyLength {
+			return nil, fmt.Errorf("signing key %q must be at least %d bytes", kid, MinKeyLength)
+		}
+		v.keys[kid] = []byte(secret)
+	}
+	return v, nil
+}
+
+// Verify checks the signature and time claims of token and returns its principal.
+func (v *Verifier) Verify(token string) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, ErrMalformedToken
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return Principal{}, ErrMalformedToken
+	}
+	if h.Algorithm != "HS256" {
+		return Principal{}, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, h.Algorithm)
+	}
+
+	key, err := v.key(h.KeyID)
+	if err != nil {
+		return Principal{}, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, ErrMalformedToken
+	}
+	if !hmac.Equal(signature, sign(key, parts[0]+"."+parts[1])) {
+		return Principal{}, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Principal{}, ErrMalformedToken
+	}
+	if err := v.validate(claims); err != nil {
+		return Principal{}, err
+	}
+
+	actorID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil || actorID <= 0 {
+		return Principal{}, fmt.Errorf("%w: subject must be a positive actor ID", ErrInvalidToken)
+	}
+	return Principal{ActorID: actorID, Roles: claims.Roles}, nil
+}
+
+func (v *Verifier) key(kid string) ([]byte, error) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, nil
+		}
+	}
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key ID %q", ErrInvalidToken, kid)
+	}
+	return key, nil
+}
+
+func (v *Verifier) validate(claims Claims) error {
+	now := v.now()
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(leeway)) {
+		return ErrExpiredToken
+	}
+	if claims.NotBefore != 0 && now.Add(leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return ErrExpiredToken
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if v.audience != "" && !slices.Contains(claims.Audience, v.audience) {
+		return fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+	return nil
+}
+
+// Sign issues an HS256 token for claims. The service itself only verifies
+// tokens, this is used by the tooling that mints them.
+func Sign(kid, secret string, claims Claims) (string, error) {
+	h, err := json.Marshal(header{Algorithm: "HS256", Type: "JWT", KeyID: kid})
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(secret), unsigned)), nil
+}
+
+func sign(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testSecret      = "test-secret-test-secret-test-secret"
+	testOtherSecret = "other-secret-other-secret-other-secret"
+)
+
+func newTestVerifier(t *testing.T, now time.Time, issuer, audience string) *Verifier {
+	t.Helper()
+	verifier, err := NewVerifier(map[string]string{"current": testSecret, "previous": testOtherSecret}, issuer, audience)
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	verifier.now = func() time.Time { return now }
+	return verifier
+}
+
+func mustSign(t *testing.T, kid, secret string, claims Claims) string {
+	t.Helper()
+	token, err := Sign(kid, secret, claims)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	return token
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	valid := Claims{Subject: "42", ExpiresAt: now.Add(time.Hour).Unix(), Roles: []string{"investor"}}
+	with := func(change func(*Claims)) Claims {
+		claims := valid
+		change(&claims)
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{
+			name:  "valid",
+			token: mustSign(t, "current", testSecret, valid),
+		},
+		{
+			name:  "rotated key",
+			token: mustSign(t, "previous", testOtherSecret, valid),
+		},
+		{
+			name:    "bad signature",
+			token:   mustSign(t, "current", testOtherSecret, valid),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "tampered claims",
+			token: func() string {
+				parts := strings.Split(mustSign(t, "current", testSecret, valid), ".")
+				forged := mustSign(t, "current", testSecret, with(func(c *Claims) { c.Roles = []string{"admin"} }))
+				return parts[0] + "." + strings.Split(forged, ".")[1] + "." + parts[2]
+			}(),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "unknown kid",
+			token:   mustSign(t, "retired", testSecret, valid),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "missing kid with several keys",
+			token:   mustSign(t, "", testSecret, valid),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "unsupported algorithm",
+			token: base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"current"}`)) + "." +
+				strings.Split(mustSign(t, "current", testSecret, valid), ".")[1] + ".",
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "malformed",
+			token:   "not-a-token",
+			wantErr: ErrMalformedToken,
+		},
+		{
+			name:  "expired within leeway",
+			token: mustSign(t, "current", testSecret, with(func(c *Claims) { c.ExpiresAt = now.Add(-leeway).Unix() })),
+		},
+		{
+			name:    "expired past leeway",
+			token:   mustSign(t, "current", testSecret, with(func(c *Claims) { c.ExpiresAt = now.Add(-leeway - time.Second).Unix() })),
+			wantErr: ErrExpiredToken,
+		},
+		{
+			name:    "missing expiry",
+			token:   mustSign(t, "current", testSecret, with(func(c *Claims) { c.ExpiresAt = 0 })),
+			wantErr: ErrExpiredToken,
+		},
+		{
+			name:  "not yet valid within leeway",
+			token: mustSign(t, "current", testSecret, with(func(c *Claims) { c.NotBefore = now.Add(leeway).Unix() })),
+		},
+		{
+			name:    "not yet valid past leeway",
+			token:   mustSign(t, "current", testSecret, with(func(c *Claims) { c.NotBefore = now.Add(leeway + time.Second).Unix() })),
+			wantErr: ErrExpiredToken,
+		},
+		{
+			name:    "non numeric subject",
+			token:   mustSign(t, "current", testSecret, with(func(c *Claims) { c.Subject = "alice" })),
+			wantErr: ErrInvalidToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := newTestVerifier(t, now, "", "").Verify(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (principal.ActorID != 42 || !principal.HasRole(RoleInvestor)) {
+				t.Errorf("Verify() principal = %+v, want actor 42 with the investor role", principal)
+			}
+		})
+	}
+}
+
+func TestVerifySingleKeyWithoutKid(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	verifier, err := NewVerifier(map[string]string{"only": testSecret}, "", "")
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	verifier.now = func() time.Time { return now }
+
+	token := mustSign(t, "", testSecret, Claims{Subject: "7", ExpiresAt: now.Add(time.Minute).Unix()})
+	if _, err := verifier.Verify(token); err != nil {
+		t.Fatalf("Verify() error = %v, want nil", err)
+	}
+}
+
+func TestVerifyIssuerAndAudience(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	claims := func(issuer string, audience Audience) Claims {
+		return Claims{Subject: "42", Issuer: issuer, Audience: audience, ExpiresAt: now.Add(time.Hour).Unix()}
+	}
+
+	tests := []struct {
+		name    string
+		claims  Claims
+		wantErr error
+	}{
+		{name: "single audience", claims: claims("issuer", Audience{"loans"})},
+		{name: "audience among several", claims: claims("issuer", Audience{"wallets", "loans"})},
+		{name: "other audience", claims: claims("issuer", Audience{"wallets"}), wantErr: ErrInvalidToken},
+		{name: "missing audience", claims: claims("issuer", nil), wantErr: ErrInvalidToken},
+		{name: "other issuer", claims: claims("someone", Audience{"loans"}), wantErr: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := mustSign(t, "current", testSecret, tt.claims)
+			_, err := newTestVerifier(t, now, "issuer", "loans").Verify(token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAudienceJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    Audience
+		wantErr bool
+	}{
+		{name: "string", json: `"loans"`, want: Audience{"loans"}},
+		{name: "array", json: `["loans","wallets"]`, want: Audience{"loans", "wallets"}},
+		{name: "number", json: `42`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Audience
+			err := got.UnmarshalJSON([]byte(tt.json))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("UnmarshalJSON() = %q, want %q", got, tt.want)
+			}
+
+			data, err := got.MarshalJSON()
+			if err != nil {
+				t.Fatalf("MarshalJSON() error = %v", err)
+			}
+			if string(data) != tt.json {
+				t.Errorf("MarshalJSON() = %s, want %s", data, tt.json)
+			}
+		})
+	}
+}
@@ -0,0 +1,556 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/common/logger"
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting of the loan service. Values are resolved with the
+// following precedence, lowest first: defaults, config file, environment, flags.
+type Config struct {
+	Server ServerConfig `json:"server" yaml:"server"`
+	Cache  CacheConfig  `json:"cache" yaml:"cache"`
+	NSQ    NSQConfig    `json:"nsq" yaml:"nsq"`
+	HTTP   HTTPConfig   `json:"http" yaml:"http"`
+	Limits LimitsConfig `json:"limits" yaml:"limits"`
+	Fees   FeesConfig   `json:"fees" yaml:"fees"`
+	Log    LogConfig    `json:"log" yaml:"log"`
+	Trace  TraceConfig  `json:"trace" yaml:"trace"`
+	Auth   AuthConfig   `json:"auth" yaml:"auth"`
+	Blob   BlobConfig   `json:"blob" yaml:"blob"`
+}
+
+// BlobConfig selects where uploaded documents are stored. "local" keeps them
+// as files under Dir.
+type BlobConfig struct {
+	Driver    string `json:"driver" yaml:"driver"`
+	Dir       string `json:"dir" yaml:"dir"`
+	MaxSizeMB int    `json:"max_size_mb" yaml:"max_size_mb"` // Largest accepted document
+}
+
+// AuthConfig holds the HMAC keys bearer tokens are verified with, by key ID.
+// Empty issuer or audience are not checked.
+type AuthConfig struct {
+	Keys     map[string]string `json:"keys" yaml:"keys"`
+	Issuer   string            `json:"issuer" yaml:"issuer"`
+	Audience string            `json:"audience" yaml:"audience"`
+}
+
+// TraceConfig selects where spans are exported: "none", "stdout" or "otlp".
+type TraceConfig struct {
+	Exporter     string `json:"exporter" yaml:"exporter"`
+	OTLPEndpoint string `json:"otlp_endpoint" yaml:"otlp_endpoint"`
+	ServiceName  string `json:"service_name" yaml:"service_name"`
+}
+
+type LogConfig struct {
+	Level  string `json:"level" yaml:"level"`
+	Format string `json:"format" yaml:"format"`
+}
+
+type ServerConfig struct {
+	Addr               string   `json:"addr" yaml:"addr"`
+	ReadTimeout        Duration `json:"read_timeout" yaml:"read_timeout"`
+	ReadHeaderTimeout  Duration `json:"read_header_timeout" yaml:"read_header_timeout"`
+	WriteTimeout       Duration `json:"write_timeout" yaml:"write_timeout"`
+	IdleTimeout        Duration `json:"idle_timeout" yaml:"idle_timeout"`
+	MaxHeaderBytes     int      `json:"max_header_bytes" yaml:"max_header_bytes"`
+	RequestTimeout     Duration `json:"request_timeout" yaml:"request_timeout"`
+	HealthCheckTimeout Duration `json:"health_check_timeout" yaml:"health_check_timeout"`
+	DrainDelay         Duration `json:"drain_delay" yaml:"drain_delay"`
+	ShutdownTimeout    Duration `json:"shutdown_timeout" yaml:"shutdown_timeout"`
+	IdempotencyTTL     Duration `json:"idempotency_ttl" yaml:"idempotency_ttl"`
+}
+
+type CacheConfig struct {
+	SizeMB            int `json:"size_mb" yaml:"size_mb"`
+	IdempotencySizeMB int `json:"idempotency_size_mb" yaml:"idempotency_size_mb"` // Separate cache of the responses kept for Idempotency-Key retries
+}
+
+type NSQConfig struct {
+	Addr       string `json:"addr" yaml:"addr"`
+	OutboxSize int    `json:"outbox_size" yaml:"outbox_size"`
+}
+
+type HTTPConfig struct {
+	BaseURL string   `json:"base_url" yaml:"base_url"`
+	Timeout Duration `json:"timeout" yaml:"timeout"`
+}
+
+// LimitsConfig holds the business limits enforced by the usecase.
+// A zero maximum means there is no upper bound.
+type LimitsConfig struct {
+	MinPrincipalAmount  float64  `json:"min_principal_amount" yaml:"min_principal_amount"`
+	MaxPrincipalAmount  float64  `json:"max_principal_amount" yaml:"max_principal_amount"`
+	MaxRate             float64  `json:"max_rate" yaml:"max_rate"`
+	MaxROI              float64  `json:"max_roi" yaml:"max_roi"`
+	MinInvestmentAmount float64  `json:"min_investment_amount" yaml:"min_investment_amount"`
+	FundingPeriod       Duration `json:"funding_period" yaml:"funding_period"` // How long an approved loan takes investments, 0 for ever
+}
+
+// FeeRulesConfig holds the platform fee rules, in percent.
+type FeeRulesConfig struct {
+	OriginationPercent     float64 `json:"origination_percent" yaml:"origination_percent"`           // Of the amount disbursed, withheld from the borrower
+	ServicingSpreadPercent float64 `json:"servicing_spread_percent" yaml:"servicing_spread_percent"` // Of the spread between rate and roi, kept on repayments
+	LateFeeSharePercent    float64 `json:"late_fee_share_percent" yaml:"late_fee_share_percent"`     // Of late fees, kept on repayments
+}
+
+// FeesConfig holds the fee rules of the default product and of the other loan
+// products. Loans can only be proposed for the products listed here.
+type FeesConfig struct {
+	FeeRulesConfig `yaml:",inline"`
+	Products       map[string]FeeRulesConfig `json:"products" yaml:"products"`
+}
+
+// Default returns the configuration used when nothing else is provided.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:               ":8080",
+			ReadTimeout:        Duration(10 * time.Second),
+			ReadHeaderTimeout:  Duration(5 * time.Second),
+			WriteTimeout:       Duration(15 * time.Second),
+			IdleTimeout:        Duration(60 * time.Second),
+			MaxHeaderBytes:     1 << 20,
+			RequestTimeout:     Duration(10 * time.Second),
+			HealthCheckTimeout: Duration(2 * time.Second),
+			DrainDelay:         Duration(5 * time.Second),
+			ShutdownTimeout:    Duration(20 * time.Second),
+			IdempotencyTTL:     Duration(24 * time.Hour),
+		},
+		Cache: CacheConfig{
+			SizeMB:            10,
+			IdempotencySizeMB: 10,
+		},
+		NSQ: NSQConfig{
+			Addr:       "127.0.0.1:4150",
+			OutboxSize: 1024,
+		},
+		HTTP: HTTPConfig{
+			Timeout: Duration(5 * time.Second),
+		},
+		Limits: LimitsConfig{
+			MinPrincipalAmount:  1,
+			MaxRate:             100,
+			MaxROI:              100,
+			MinInvestmentAmount: 1,
+			FundingPeriod:       Duration(30 * 24 * time.Hour),
+		},
+		Fees: FeesConfig{
+			FeeRulesConfig: FeeRulesConfig{
+				ServicingSpreadPercent: 100,
+				LateFeeSharePercent:    50,
+			},
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Trace: TraceConfig{
+			Exporter:     "none",
+			OTLPEndpoint: "http://localhost:4318",
+			ServiceName:  "loan-service",
+		},
+		Blob: BlobConfig{
+			Driver:    "local",
+			Dir:       "data/blobs",
+			MaxSizeMB: 10,
+		},
+	}
+}
+
+// setting binds one configuration value to its environment variable and flag.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(cfg *Config, raw string) error
+}
+
+const envConfigFile = "LOAN_CONFIG_FILE"
+
+// maxBlobSizeMB is the largest blob.max_size_mb, the delivery layer bounds an
+// upload request at 1 MiB above it.
+const maxBlobSizeMB = 64
+
+var settings = []setting{
+	{"LOAN_SERVER_ADDR", "addr", "address the HTTP server listens on", func(c *Config, raw string) error {
+		c.Server.Addr = raw
+		return nil
+	}},
+	{"LOAN_SERVER_READ_TIMEOUT", "read-timeout", "maximum duration for reading a whole request", func(c *Config, raw string) error {
+		return c.Server.ReadTimeout.Set(raw)
+	}},
+	{"LOAN_SERVER_READ_HEADER_TIMEOUT", "read-header-timeout", "maximum duration for reading request headers", func(c *Config, raw string) error {
+		return c.Server.ReadHeaderTimeout.Set(raw)
+	}},
+	{"LOAN_SERVER_WRITE_TIMEOUT", "write-timeout", "maximum duration before timing out writes of the response", func(c *Config, raw string) error {
+		return c.Server.WriteTimeout.Set(raw)
+	}},
+	{"LOAN_SERVER_IDLE_TIMEOUT", "idle-timeout", "maximum time to wait for the next request on keep-alive connections", func(c *Config, raw string) error {
+		return c.Server.IdleTimeout.Set(raw)
+	}},
+	{"LOAN_SERVER_MAX_HEADER_BYTES", "max-header-bytes", "maximum size of request headers in bytes", func(c *Config, raw string) error {
+		return parseInt(raw, &c.Server.MaxHeaderBytes)
+	}},
+	{"LOAN_SERVER_REQUEST_TIMEOUT", "request-timeout", "deadline of the context handed to each request", func(c *Config, raw string) error {
+		return c.Server.RequestTimeout.Set(raw)
+	}},
+	{"LOAN_SERVER_HEALTH_CHECK_TIMEOUT", "health-check-timeout", "time allowed for each readiness dependency check", func(c *Config, raw string) error {
+		return c.Server.HealthCheckTimeout.Set(raw)
+	}},
+	{"LOAN_SERVER_DRAIN_DELAY", "drain-delay", "time between failing readiness and closing the listener on shutdown, for load balancers to stop routing", func(c *Config, raw string) error {
+		return c.Server.DrainDelay.Set(raw)
+	}},
+	{"LOAN_SERVER_SHUTDOWN_TIMEOUT", "shutdown-timeout", "time allowed to drain in-flight requests and pending messages on shutdown", func(c *Config, raw string) error {
+		return c.Server.ShutdownTimeout.Set(raw)
+	}},
+	{"LOAN_SERVER_IDEMPOTENCY_TTL", "idempotency-ttl", "how long responses to requests with an Idempotency-Key are kept for replay", func(c *Config, raw string) error {
+		return c.Server.IdempotencyTTL.Set(raw)
+	}},
+	{"LOAN_CACHE_SIZE_MB", "cache-size-mb", "in-memory cache capacity in megabytes", func(c *Config, raw string) error {
+		return parseInt(raw, &c.Cache.SizeMB)
+	}},
+	{"LOAN_CACHE_IDEMPOTENCY_SIZE_MB", "idempotency-cache-size-mb", "capacity in megabytes of the cache of responses kept for Idempotency-Key retries", func(c *Config, raw string) error {
+		return parseInt(raw, &c.Cache.IdempotencySizeMB)
+	}},
+	{"LOAN_NSQ_ADDR", "nsq-addr", "nsqd TCP address", func(c *Config, raw string) error {
+		c.NSQ.Addr = raw
+		return nil
+	}},
+	{"LOAN_NSQ_OUTBOX_SIZE", "nsq-outbox-size", "number of messages buffered before publishing blocks", func(c *Config, raw string) error {
+		return parseInt(raw, &c.NSQ.OutboxSize)
+	}},
+	{"LOAN_HTTP_BASE_URL", "http-base-url", "base URL of the downstream HTTP service", func(c *Config, raw string) error {
+		c.HTTP.BaseURL = raw
+		return nil
+	}},
+	{"LOAN_HTTP_TIMEOUT", "http-timeout", "timeout of downstream HTTP calls", func(c *Config, raw string) error {
+		return c.HTTP.Timeout.Set(raw)
+	}},
+	{"LOAN_MIN_PRINCIPAL_AMOUNT", "min-principal-amount", "minimum principal amount of a loan", func(c *Config, raw string) error {
+		return parseFloat(raw, &c.Limits.MinPrincipalAmount)
+	}},
+	{"LOAN_MAX_PRINCIPAL_AMOUNT", "max-principal-amount", "maximum principal amount of a loan, 0 for no limit", func(c *Config, raw string) error {
+		return parseFloat(raw, &c.Limits.MaxPrincipalAmount)
+	}},
+	{"LOAN_MAX_RATE", "max-rate", "maximum interest rate of a loan", func(c *Config, raw string) error {
+		return parseFloat(raw, &c.Limits.MaxRate)
+	}},
+	{"LOAN_MAX_ROI", "max-roi", "maximum return on investment of a loan", func(c *Config, raw string) error {
+		return parseFloat(raw, &c.Limits.MaxROI)
+	}},
+	{"LOAN_MIN_INVESTMENT_AMOUNT", "min-investment-amount", "minimum amount of a single investment", func(c *Config, raw string) error {
+		return parseFloat(raw, &c.Limits.MinInvestmentAmount)
+	}},
+	{"LOAN_FUNDING_PERIOD", "funding-period", "how long an approved loan takes investments before it expires, 0 for ever", func(c *Config, raw string) error {
+		return c.Limits.FundingPeriod.Set(raw)
+	}},
+	{"LOAN_FEE_ORIGINATION_PERCENT", "fee-origination-percent", "percentage of the amount disbursed withheld as origination fee", func(c *Config, raw string) error {
+		return parseFloat(raw, &c.Fees.OriginationPercent)
+	}},
+	{"LOAN_FEE_SERVICING_SPREAD_PERCENT", "fee-servicing-spread-percent", "percentage of the spread between rate and roi kept by the platform", func(c *Config, raw string) error {
+		return parseFloat(raw, &c.Fees.ServicingSpreadPercent)
+	}},
+	{"LOAN_FEE_LATE_FEE_SHARE_PERCENT", "fee-late-fee-share-percent", "percentage of late fees kept by the platform", func(c *Config, raw string) error {
+		return parseFloat(raw, &c.Fees.LateFeeSharePercent)
+	}},
+	{"LOAN_LOG_LEVEL", "log-level", "log level: debug, info, warn or error", func(c *Config, raw string) error {
+		c.Log.Level = raw
+		return nil
+	}},
+	{"LOAN_LOG_FORMAT", "log-format", "log format: json or text", func(c *Config, raw string) error {
+		c.Log.Format = raw
+		return nil
+	}},
+	{"LOAN_TRACE_EXPORTER", "trace-exporter", "span exporter: none, stdout or otlp", func(c *Config, raw string) error {
+		c.Trace.Exporter = raw
+		return nil
+	}},
+	{"LOAN_TRACE_OTLP_ENDPOINT", "trace-otlp-endpoint", "base URL of the OTLP/HTTP collector", func(c *Config, raw string) error {
+		c.Trace.OTLPEndpoint = raw
+		return nil
+	}},
+	{"LOAN_AUTH_KEYS", "auth-keys", "comma separated kid=secret pairs used to verify bearer tokens", func(c *Config, raw string) error {
+		keys, err := parseKeys(raw)
+		if err != nil {
+			return err
+		}
+		c.Auth.Keys = keys
+		return nil
+	}},
+	{"LOAN_AUTH_ISSUER", "auth-issuer", "required iss claim of bearer tokens", func(c *Config, raw string) error {
+		c.Auth.Issuer = raw
+		return nil
+	}},
+	{"LOAN_AUTH_AUDIENCE", "auth-audience", "required aud claim of bearer tokens", func(c *Config, raw string) error {
+		c.Auth.Audience = raw
+		return nil
+	}},
+	{"LOAN_TRACE_SERVICE_NAME", "trace-service-name", "service.name reported on exported spans", func(c *Config, raw string) error {
+		c.Trace.ServiceName = raw
+		return nil
+	}},
+	{"LOAN_BLOB_DRIVER", "blob-driver", "blob store of uploaded documents: local", func(c *Config, raw string) error {
+		c.Blob.Driver = raw
+		return nil
+	}},
+	{"LOAN_BLOB_DIR", "blob-dir", "directory of the local blob store", func(c *Config, raw string) error {
+		c.Blob.Dir = raw
+		return nil
+	}},
+	{"LOAN_BLOB_MAX_SIZE_MB", "blob-max-size-mb", "largest accepted document in megabytes", func(c *Config, raw string) error {
+		return parseInt(raw, &c.Blob.MaxSizeMB)
+	}},
+}
+
+// Load resolves the configuration from defaults, the optional config file,
+// environment variables and the command-line arguments, then validates it.
+func Load(args []string) (Config, error) {
+	fs := flag.NewFlagSet("loan-service", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv(envConfigFile), "path to a YAML or JSON config file (env "+envConfigFile+")")
+	for _, s := range settings {
+		fs.String(s.flag, "", s.usage+" (env "+s.env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	cfg := Default()
+
+	// Config file
+	if *configFile != "" {
+		if err := loadFile(*configFile, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	// Environment variables
+	for _, s := range settings {
+		raw, ok := os.LookupEnv(s.env)
+		if !ok {
+			continue
+		}
+		if err := s.set(&cfg, raw); err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %w", s.env, err)
+		}
+	}
+
+	// Flags, only the ones explicitly passed
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag != f.Name || flagErr != nil {
+				continue
+			}
+			if err := s.set(&cfg, f.Value.String()); err != nil {
+				flagErr = fmt.Errorf("invalid -%s: %w", s.flag, err)
+			}
+		}
+	})
+	if flagErr != nil {
+		return Config{}, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".json":
+		err = json.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("unsupported config file extension %q, use .yaml, .yml or .json", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var errs []error
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr is required"))
+	}
+	if c.Server.ReadTimeout <= 0 || c.Server.ReadHeaderTimeout <= 0 || c.Server.WriteTimeout <= 0 || c.Server.IdleTimeout <= 0 {
+		errs = append(errs, errors.New("server read, read header, write and idle timeouts must be positive"))
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		errs = append(errs, errors.New("server.max_header_bytes must be positive"))
+	}
+	if c.Server.RequestTimeout <= 0 {
+		errs = append(errs, errors.New("server.request_timeout must be positive"))
+	}
+	if c.Server.HealthCheckTimeout <= 0 {
+		errs = append(errs, errors.New("server.health_check_timeout must be positive"))
+	}
+	if c.Server.DrainDelay < 0 {
+		errs = append(errs, errors.New("server.drain_delay must not be negative"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout must be positive"))
+	}
+	if c.Server.IdempotencyTTL <= 0 {
+		errs = append(errs, errors.New("server.idempotency_ttl must be positive"))
+	}
+	if c.Cache.SizeMB <= 0 {
+		errs = append(errs, errors.New("cache.size_mb must be positive"))
+	}
+	if c.Cache.IdempotencySizeMB <= 0 {
+		errs = append(errs, errors.New("cache.idempotency_size_mb must be positive"))
+	}
+	if c.NSQ.OutboxSize < 0 {
+		errs = append(errs, errors.New("nsq.outbox_size must not be negative"))
+	}
+	if c.HTTP.Timeout < 0 {
+		errs = append(errs, errors.New("http.timeout must not be negative"))
+	}
+	if c.Limits.MinPrincipalAmount < 0 {
+		errs = append(errs, errors.New("limits.min_principal_amount must not be negative"))
+	}
+	if c.Limits.MaxPrincipalAmount < 0 || (c.Limits.MaxPrincipalAmount > 0 && c.Limits.MaxPrincipalAmount < c.Limits.MinPrincipalAmount) {
+		errs = append(errs, errors.New("limits.max_principal_amount must be 0 or at least limits.min_principal_amount"))
+	}
+	if c.Limits.MaxRate <= 0 {
+		errs = append(errs, errors.New("limits.max_rate must be positive"))
+	}
+	if c.Limits.MaxROI <= 0 {
+		errs = append(errs, errors.New("limits.max_roi must be positive"))
+	}
+	if c.Limits.MinInvestmentAmount < 0 {
+		errs = append(errs, errors.New("limits.min_investment_amount must not be negative"))
+	}
+	if c.Limits.FundingPeriod < 0 {
+		errs = append(errs, errors.New("limits.funding_period must not be negative"))
+	}
+	errs = append(errs, c.Fees.validate("fees")...)
+	for name, rules := range c.Fees.Products {
+		switch {
+		case name == model.DefaultProduct:
+			errs = append(errs, fmt.Errorf("fees.products[%s]: the rules of the default product are set on fees itself", name))
+		case !model.ValidProduct(name):
+			errs = append(errs, fmt.Errorf("fees.products[%s]: product names are lowercase letters, digits, _ and -, at most 32 characters", name))
+		}
+		errs = append(errs, rules.validate("fees.products["+name+"]")...)
+	}
+	if _, err := logger.New(io.Discard, c.Log.Format, c.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("log: %w", err))
+	}
+	if len(c.Auth.Keys) == 0 {
+		errs = append(errs, errors.New("auth.keys requires at least one key"))
+	}
+	for kid, secret := range c.Auth.Keys {
+		if len(secret) < auth.MinKeyLength {
+			errs = append(errs, fmt.Errorf("auth.keys[%s] must be at least %d bytes", kid, auth.MinKeyLength))
+		}
+	}
+	switch c.Trace.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if c.Trace.OTLPEndpoint == "" {
+			errs = append(errs, errors.New("trace.otlp_endpoint is required with the otlp exporter"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("trace.exporter %q is invalid, use none, stdout or otlp", c.Trace.Exporter))
+	}
+	switch c.Blob.Driver {
+	case "local":
+		if c.Blob.Dir == "" {
+			errs = append(errs, errors.New("blob.dir is required with the local driver"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("blob.driver %q is invalid, use local", c.Blob.Driver))
+	}
+	if c.Blob.MaxSizeMB <= 0 || c.Blob.MaxSizeMB > maxBlobSizeMB {
+		errs = append(errs, fmt.Errorf("blob.max_size_mb must be between 1 and %d", maxBlobSizeMB))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// parseKeys reads "kid=secret,kid2=secret2".
+func parseKeys(raw string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kid, secret, ok := strings.Cut(pair, "=")
+		if !ok || kid == "" || secret == "" {
+			return nil, errors.New("keys must be comma separated kid=secret pairs")
+		}
+		keys[kid] = secret
+	}
+	return keys, nil
+}
+
+func parseInt(raw string, dst *int) error {
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return err
+	}
+	*dst = v
+	return nil
+}
+
+func parseFloat(raw string, dst *float64) error {
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return err
+	}
+	*dst = v
+	return nil
+}
+
+func (r FeeRulesConfig) validate(prefix string) []error {
+	if err := r.Terms().Validate(); err != nil {
+		return []error{fmt.Errorf("%s.%w", prefix, err)}
+	}
+	return nil
+}
+
+// Terms returns the rules as the fee terms of a loan.
+func (r FeeRulesConfig) Terms() model.FeeTerms {
+	return model.FeeTerms{
+		OriginationPercent:     r.OriginationPercent,
+		ServicingSpreadPercent: r.ServicingSpreadPercent,
+		LateFeeSharePercent:    r.LateFeeSharePercent,
+	}
+}
+
+// ProductTerms returns the fee terms of every product but the default one.
+func (f FeesConfig) ProductTerms() map[string]model.FeeTerms {
+	terms := make(map[string]model.FeeTerms, len(f.Products))
+	for name, rules := range f.Products {
+		terms[name] = rules.Terms()
+	}
+	return terms
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testKeys = "k1=0123456789abcdef0123456789abcdef"
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string // Name and content of the config file, if any
+		content string
+		env     map[string]string
+		args    []string
+		want    func(cfg Config) bool
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			want: func(cfg Config) bool {
+				return cfg.Cache.SizeMB == 10 && cfg.Server.DrainDelay.Duration() == 5*time.Second
+			},
+		},
+		{
+			name:    "yaml file over defaults",
+			file:    "config.yaml",
+			content: "cache:\n  size_mb: 20\nserver:\n  drain_delay: 1s\n",
+			want: func(cfg Config) bool {
+				return cfg.Cache.SizeMB == 20 && cfg.Server.DrainDelay.Duration() == time.Second
+			},
+		},
+		{
+			name:    "json file over defaults",
+			file:    "config.json",
+			content: `{"cache": {"size_mb": 20}}`,
+			want:    func(cfg Config) bool { return cfg.Cache.SizeMB == 20 && cfg.Cache.IdempotencySizeMB == 10 },
+		},
+		{
+			name:    "environment over file",
+			file:    "config.yaml",
+			content: "cache:\n  size_mb: 20\n",
+			env:     map[string]string{"LOAN_CACHE_SIZE_MB": "30"},
+			want:    func(cfg Config) bool { return cfg.Cache.SizeMB == 30 },
+		},
+		{
+			name:    "flags over environment",
+			file:    "config.yaml",
+			content: "cache:\n  size_mb: 20\n",
+			env:     map[string]string{"LOAN_CACHE_SIZE_MB": "30"},
+			args:    []string{"-cache-size-mb", "40"},
+			want:    func(cfg Config) bool { return cfg.Cache.SizeMB == 40 },
+		},
+		{
+			name:    "file named in the environment",
+			file:    "env.yaml",
+			content: "limits:\n  funding_period: 48h\n",
+			env:     map[string]string{envConfigFile: "env.yaml"},
+			want:    func(cfg Config) bool { return cfg.Limits.FundingPeriod.Duration() == 48*time.Hour },
+		},
+		{
+			name:    "invalid environment value",
+			env:     map[string]string{"LOAN_CACHE_SIZE_MB": "ten"},
+			wantErr: "invalid LOAN_CACHE_SIZE_MB",
+		},
+		{
+			name:    "invalid flag value",
+			args:    []string{"-drain-delay", "soon"},
+			wantErr: "invalid -drain-delay",
+		},
+		{
+			name:    "unknown file extension",
+			file:    "config.toml",
+			content: "",
+			wantErr: "unsupported config file extension",
+		},
+		{
+			name:    "validated after every source",
+			file:    "config.yaml",
+			content: "cache:\n  size_mb: 20\n",
+			args:    []string{"-cache-size-mb", "0"},
+			wantErr: "cache.size_mb must be positive",
+		},
+		{
+			name:    "negative drain delay",
+			env:     map[string]string{"LOAN_SERVER_DRAIN_DELAY": "-1s"},
+			wantErr: "server.drain_delay must not be negative",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv(envConfigFile, "")
+			t.Setenv("LOAN_AUTH_KEYS", testKeys)
+			for key, value := range tt.env {
+				if key == envConfigFile {
+					value = filepath.Join(dir, value)
+				}
+				t.Setenv(key, value)
+			}
+
+			args := tt.args
+			if tt.file != "" {
+				path := filepath.Join(dir, tt.file)
+				if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				if _, ok := tt.env[envConfigFile]; !ok {
+					args = append([]string{"-config", path}, args...)
+				}
+			}
+
+			cfg, err := Load(args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !tt.want(cfg) {
+				t.Errorf("Load() = %+v, not the expected values", cfg)
+			}
+		})
+	}
+}
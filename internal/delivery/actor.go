@@ -0,0 +1,35 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+)
+
+var (
+	errUnauthenticated = errors.New("request is not authenticated")
+	errActorMismatch   = errors.New("actor in the request body does not match the bearer token")
+)
+
+// actorID returns the actor identified by the bearer token. Payloads may still
+// carry the legacy actor ID fields, but only when they name the same actor.
+func actorID(r *http.Request, claimed int64) (int64, error) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		return 0, errUnauthenticated
+	}
+	if claimed != 0 && claimed != principal.ActorID {
+		return 0, errActorMismatch
+	}
+	return principal.ActorID, nil
+}
+
+// writeActorError responds to a failed actorID lookup.
+func writeActorError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errUnauthenticated) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+}
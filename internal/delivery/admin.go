@@ -0,0 +1,114 @@
+package delivery
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+type forceTransitionRequest struct {
+	State  *model.StateEnum `json:"state"` // Required, the zero value is a state
+	Reason string           `json:"reason"`
+}
+
+type republishRequest struct {
+	Channel string `json:"channel"`
+}
+
+func (d Delivery) AdminForceTransition(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is POST
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract the loan ID from the URL path
+	loanID, err := strconv.ParseInt(r.PathValue("loan_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse request body
+	var request forceTransitionRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Failed to parse request body", http.StatusBadRequest)
+		return
+	}
+	if request.State == nil {
+		http.Error(w, "Missing state field", http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's AdminForceTransition method
+	err = d.UsecaseInterface.AdminForceTransition(r.Context(), loanID, *request.State, request.Reason)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to force loan state", "loan_id", loanID, "error", err)
+		http.Error(w, "Failed to force loan state", statusFromError(err))
+		return
+	}
+
+	// Respond with success
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Loan state forced successfully"))
+}
+
+func (d Delivery) AdminRepublish(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is POST
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract the loan ID from the URL path
+	loanID, err := strconv.ParseInt(r.PathValue("loan_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse request body
+	var request republishRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Failed to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's AdminRepublish method
+	sent, err := d.UsecaseInterface.AdminRepublish(r.Context(), loanID, request.Channel)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to republish messages", "loan_id", loanID, "channel", request.Channel, "error", err)
+		http.Error(w, "Failed to republish messages", statusFromError(err))
+		return
+	}
+
+	// Respond with success
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("Republished %d messages", sent)))
+}
+
+func (d Delivery) AdminExpireLoans(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is POST
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Call the usecase's AdminExpireLoans method
+	report, err := d.UsecaseInterface.AdminExpireLoans(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to expire loans", "error", err)
+		http.Error(w, "Failed to expire loans", statusFromError(err))
+		return
+	}
+
+	// Send the report in the response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,72 @@
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/timotiusas11/amartha-assignment/internal/archive"
+	"github.com/timotiusas11/amartha-assignment/internal/usecase"
+)
+
+func (d Delivery) AdminExport(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is GET
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Call the usecase's AdminExport method
+	snapshot, err := d.UsecaseInterface.AdminExport(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to export loan book", "error", err)
+		http.Error(w, "Failed to export loan book", statusFromError(err))
+		return
+	}
+
+	// Stream the archive as a download
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="loanbook-%s.jsonl"`, snapshot.CreatedAt.Format("20060102T150405Z")))
+	err = archive.Write(w, snapshot)
+	if err != nil {
+		// Headers are gone, the missing trailer tells the client the archive is incomplete
+		slog.ErrorContext(r.Context(), "failed to write archive", "error", err)
+	}
+}
+
+func (d Delivery) AdminRestore(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is POST
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Decode and verify the archive
+	snapshot, err := archive.Read(r.Body)
+	if errors.Is(err, archive.ErrInvalidArchive) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's AdminRestore method
+	report, err := d.UsecaseInterface.AdminRestore(r.Context(), snapshot)
+	if errors.Is(err, usecase.ErrStoreNotEmpty) {
+		http.Error(w, "Store is not empty, archives can only be restored into an empty store or resumed with the archive whose restore failed", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to restore loan book", "error", err)
+		http.Error(w, "Failed to restore loan book, retry with the same archive to resume", statusFromError(err))
+		return
+	}
+
+	// Send the report in the response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,263 @@
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+// BorrowerRequest is the payload of a borrower registration, admins may name
+// the borrower, borrowers register themselves.
+type BorrowerRequest struct {
+	BorrowerID int64 `json:"borrower_id"`
+	model.BorrowerProfile
+}
+
+// KYCReviewRequest is the payload of a KYC review.
+type KYCReviewRequest struct {
+	Status model.KYCStatus `json:"status"`
+	Reason string          `json:"reason"`
+}
+
+func (d Delivery) RegisterBorrower(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is POST
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Decode the request body into BorrowerRequest struct
+	var request BorrowerRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's RegisterBorrower method
+	borrower, err := d.UsecaseInterface.RegisterBorrower(r.Context(), request.BorrowerID, request.BorrowerProfile)
+	if errors.Is(err, model.ErrBorrowerExists) {
+		http.Error(w, err.Error(), statusFromError(err))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to register borrower", "borrower_id", request.BorrowerID, "error", err)
+		http.Error(w, "Failed to register borrower", statusFromError(err))
+		return
+	}
+
+	// Send the borrower, pointing at it
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/borrowers/"+strconv.FormatInt(borrower.BorrowerID, 10))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(borrower)
+}
+
+func (d Delivery) GetBorrowers(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is GET
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Call the usecase's GetBorrowers method
+	borrowers, err := d.UsecaseInterface.GetBorrowers(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get borrowers", "error", err)
+		http.Error(w, "Failed to get borrowers", statusFromError(err))
+		return
+	}
+
+	// Send the borrowers in the response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(borrowers)
+}
+
+func (d Delivery) Borrower(w http.ResponseWriter, r *http.Request) {
+	// Extract the borrower ID from the URL path
+	borrowerID, err := strconv.ParseInt(r.PathValue("borrower_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid borrower ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		// Call the usecase's GetBorrower method
+		borrower, err := d.UsecaseInterface.GetBorrower(r.Context(), borrowerID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get borrower", "borrower_id", borrowerID, "error", err)
+			http.Error(w, "Failed to get borrower", statusFromError(err))
+			return
+		}
+
+		// Send the borrower in the response
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(borrower)
+	case http.MethodPut:
+		// Decode the request body into BorrowerProfile struct
+		var profile model.BorrowerProfile
+		err := json.NewDecoder(r.Body).Decode(&profile)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		// Call the usecase's UpdateBorrower method
+		borrower, err := d.UsecaseInterface.UpdateBorrower(r.Context(), borrowerID, profile)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to update borrower", "borrower_id", borrowerID, "error", err)
+			http.Error(w, "Failed to update borrower", statusFromError(err))
+			return
+		}
+
+		// Send the updated borrower, its KYC status may have been reset
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(borrower)
+	case http.MethodDelete:
+		// Call the usecase's DeleteBorrower method
+		err := d.UsecaseInterface.DeleteBorrower(r.Context(), borrowerID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to delete borrower", "borrower_id", borrowerID, "error", err)
+			http.Error(w, "Failed to delete borrower", statusFromError(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+func (d Delivery) ReviewKYC(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is POST
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract the borrower ID from the URL path
+	borrowerID, err := strconv.ParseInt(r.PathValue("borrower_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid borrower ID", http.StatusBadRequest)
+		return
+	}
+
+	// Decode the request body into KYCReviewRequest struct
+	var request KYCReviewRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's ReviewKYC method
+	borrower, err := d.UsecaseInterface.ReviewKYC(r.Context(), borrowerID, request.Status, request.Reason)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to review borrower", "borrower_id", borrowerID, "status", request.Status, "error", err)
+		http.Error(w, "Failed to review borrower", statusFromError(err))
+		return
+	}
+
+	// Send the reviewed borrower
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(borrower)
+}
+
+func (d Delivery) UploadBorrowerDocument(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is POST
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract the borrower ID from the URL path
+	borrowerID, err := strconv.ParseInt(r.PathValue("borrower_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid borrower ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse the multipart form, with the document in the "file" field
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	err = r.ParseMultipartForm(maxUploadMemory)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing file field", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	// Call the usecase's UploadBorrowerDocument method
+	kind := model.DocumentKind(r.FormValue("kind"))
+	document, err := d.UsecaseInterface.UploadBorrowerDocument(r.Context(), borrowerID, kind, header.Filename, file)
+	if errors.Is(err, model.ErrDocumentTooLarge) || errors.Is(err, model.ErrUnsupportedDocumentType) {
+		http.Error(w, err.Error(), statusFromError(err))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to upload borrower document", "borrower_id", borrowerID, "kind", kind, "error", err)
+		http.Error(w, "Failed to upload borrower document", statusFromError(err))
+		return
+	}
+
+	// Send the document
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", document.URL)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(document)
+}
+
+func (d Delivery) GetBorrowerDocument(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is GET
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract the borrower ID and the document SHA-256 from the URL path
+	borrowerID, err := strconv.ParseInt(r.PathValue("borrower_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid borrower ID", http.StatusBadRequest)
+		return
+	}
+	sha256 := r.PathValue("sha256")
+	if !model.IsSHA256(sha256) {
+		http.Error(w, "Invalid document URL", http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's OpenBorrowerDocument method
+	document, content, err := d.UsecaseInterface.OpenBorrowerDocument(r.Context(), borrowerID, sha256)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get borrower document", "borrower_id", borrowerID, "sha256", sha256, "error", err)
+		http.Error(w, "Failed to get borrower document", statusFromError(err))
+		return
+	}
+	defer content.Close()
+
+	// Send the content as uploaded, the hash doubles as a strong ETag
+	w.Header().Set("Content-Type", document.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(document.Size, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+document.SHA256+`"`)
+	if _, err := io.Copy(w, content); err != nil {
+		slog.ErrorContext(r.Context(), "failed to send borrower document", "borrower_id", borrowerID, "sha256", sha256, "error", err)
+	}
+}
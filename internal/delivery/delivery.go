@@ -2,6 +2,8 @@ package delivery
 
 import (
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
 
@@ -44,23 +46,48 @@ func (d Delivery) createLoan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The borrower is the authenticated caller
+	borrowerID, err := actorID(r, loan.BorrowerID)
+	if err != nil {
+		writeActorError(w, err)
+		return
+	}
+
 	// Call the usecase's CreateLoan method
-	err = d.UsecaseInterface.CreateLoan(loan.BorrowerID, loan.PrincipalAmount, loan.Rate, loan.ROI)
+	loanID, err := d.UsecaseInterface.CreateLoan(r.Context(), borrowerID, loan.PrincipalAmount, loan.Rate, loan.ROI, loan.Product)
+	if errors.Is(err, model.ErrBorrowerNotEligible) {
+		http.Error(w, err.Error(), statusFromError(err))
+		return
+	}
 	if err != nil {
-		http.Error(w, "Failed to create loan", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "failed to create loan", "actor_id", borrowerID, "error", err)
+		http.Error(w, "Failed to create loan", statusFromError(err))
 		return
 	}
 
-	// Send a success response
+	// Send a success response, pointing at the new loan
+	w.Header().Set("Location", "/loans/"+strconv.FormatInt(loanID, 10))
 	w.WriteHeader(http.StatusCreated)
 	w.Write([]byte("Loan created successfully"))
 }
 
-func (d Delivery) getLoans(w http.ResponseWriter, _ *http.Request) {
+func (d Delivery) getLoans(w http.ResponseWriter, r *http.Request) {
+	// Parse filters, sort and pagination from the query string
+	query, err := parseLoanQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Call the usecase's GetLoans method
-	loans, err := d.UsecaseInterface.GetLoans()
+	loans, err := d.UsecaseInterface.GetLoans(r.Context(), query)
+	if errors.Is(err, model.ErrInvalidQuery) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Failed to get loans", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "failed to get loans", "error", err)
+		http.Error(w, "Failed to get loans", statusFromError(err))
 		return
 	}
 
@@ -94,9 +121,10 @@ func (d Delivery) GetLoan(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Call the usecase's GetLoan method
-	loan, err := d.UsecaseInterface.GetLoan(loanID)
+	loan, err := d.UsecaseInterface.GetLoan(r.Context(), loanID)
 	if err != nil {
-		http.Error(w, "Failed to get loan", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "failed to get loan", "loan_id", loanID, "error", err)
+		http.Error(w, "Failed to get loan", statusFromError(err))
 		return
 	}
 
@@ -129,10 +157,18 @@ func (d Delivery) Approve(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The field validator is the authenticated caller
+	fieldValidatorID, err := actorID(r, approval.FieldValidatorID)
+	if err != nil {
+		writeActorError(w, err)
+		return
+	}
+
 	// Call the usecase's Approve method
-	err = d.UsecaseInterface.Approve(loanID, approval.PictureProofURL, approval.FieldValidatorID)
+	err = d.UsecaseInterface.Approve(r.Context(), loanID, approval.PictureProofURL, fieldValidatorID)
 	if err != nil {
-		http.Error(w, "Failed to approve loan", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "failed to approve loan", "loan_id", loanID, "actor_id", fieldValidatorID, "error", err)
+		http.Error(w, "Failed to approve loan", statusFromError(err))
 		return
 	}
 
@@ -165,10 +201,22 @@ func (d Delivery) Invest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The investor is the authenticated caller
+	invest.InvestorID, err = actorID(r, invest.InvestorID)
+	if err != nil {
+		writeActorError(w, err)
+		return
+	}
+
 	// Call the usecase's Invest method
-	err = d.UsecaseInterface.Invest(loanID, invest)
+	err = d.UsecaseInterface.Invest(r.Context(), loanID, invest)
+	if errors.Is(err, model.ErrInsufficientFunds) {
+		http.Error(w, err.Error(), statusFromError(err))
+		return
+	}
 	if err != nil {
-		http.Error(w, "Failed to invest", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "failed to invest", "loan_id", loanID, "actor_id", invest.InvestorID, "error", err)
+		http.Error(w, "Failed to invest", statusFromError(err))
 		return
 	}
 
@@ -200,10 +248,18 @@ func (d Delivery) Disburse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The field officer is the authenticated caller
+	fieldOfficerID, err := actorID(r, disbursementInfo.FieldOfficerID)
+	if err != nil {
+		writeActorError(w, err)
+		return
+	}
+
 	// Call usecase.Disburse
-	err = d.UsecaseInterface.Disburse(loanID, disbursementInfo.SignedAgreementLetterURL, disbursementInfo.FieldOfficerID)
+	err = d.UsecaseInterface.Disburse(r.Context(), loanID, disbursementInfo.SignedAgreementLetterURL, fieldOfficerID)
 	if err != nil {
-		http.Error(w, "Failed to disburse loan", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "failed to disburse loan", "loan_id", loanID, "actor_id", fieldOfficerID, "error", err)
+		http.Error(w, "Failed to disburse loan", statusFromError(err))
 		return
 	}
 
@@ -212,6 +268,42 @@ func (d Delivery) Disburse(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Loan disbursed successfully"))
 }
 
+func (d Delivery) Cancel(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is POST
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract the loan ID from the URL path
+	loanIDString := r.PathValue("loan_id")
+	loanID, err := strconv.ParseInt(loanIDString, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse request body
+	var cancellationInfo model.CancellationInfo
+	err = json.NewDecoder(r.Body).Decode(&cancellationInfo)
+	if err != nil {
+		http.Error(w, "Failed to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	// Call usecase.Cancel, the caller is taken from the bearer token
+	err = d.UsecaseInterface.Cancel(r.Context(), loanID, cancellationInfo.Reason)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to cancel loan", "loan_id", loanID, "error", err)
+		http.Error(w, "Failed to cancel loan", statusFromError(err))
+		return
+	}
+
+	// Respond with success
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Loan cancelled successfully"))
+}
+
 func (d Delivery) AdminViewLoans(w http.ResponseWriter, r *http.Request) {
 	// Check if the method is GET
 	if r.Method != http.MethodGet {
@@ -220,9 +312,10 @@ func (d Delivery) AdminViewLoans(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Call the usecase's GetLoans method
-	loans, err := d.UsecaseInterface.AdminViewLoans()
+	loans, err := d.UsecaseInterface.AdminViewLoans(r.Context())
 	if err != nil {
-		http.Error(w, "Failed to get loans", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "failed to get loans", "error", err)
+		http.Error(w, "Failed to get loans", statusFromError(err))
 		return
 	}
 
@@ -238,3 +331,24 @@ func (d Delivery) AdminViewLoans(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(loansJSON)
 }
+
+func (d Delivery) AdminCacheStats(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is GET
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Call the usecase's AdminCacheStats method
+	stats, err := d.UsecaseInterface.AdminCacheStats(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get cache stats", "error", err)
+		http.Error(w, "Failed to get cache stats", statusFromError(err))
+		return
+	}
+
+	// Set response headers and write JSON response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,137 @@
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+const (
+	// maxUploadBytes bounds the whole multipart request, 1 MiB above the
+	// largest document size the config accepts, the usecase enforces the
+	// configured document size on top of it
+	maxUploadBytes = 65 << 20
+	// maxUploadMemory is the part of an upload kept in memory, the rest is
+	// spooled to a temporary file
+	maxUploadMemory = 1 << 20
+)
+
+func (d Delivery) UploadDocument(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is POST
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract the loan ID from the URL path
+	loanID, err := strconv.ParseInt(r.PathValue("loan_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse the multipart form, with the document in the "file" field
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	err = r.ParseMultipartForm(maxUploadMemory)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing file field", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	// Call the usecase's UploadDocument method
+	kind := model.DocumentKind(r.FormValue("kind"))
+	document, err := d.UsecaseInterface.UploadDocument(r.Context(), loanID, kind, header.Filename, file)
+	if errors.Is(err, model.ErrDocumentTooLarge) || errors.Is(err, model.ErrUnsupportedDocumentType) {
+		http.Error(w, err.Error(), statusFromError(err))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to upload document", "loan_id", loanID, "kind", kind, "error", err)
+		http.Error(w, "Failed to upload document", statusFromError(err))
+		return
+	}
+
+	// Send the document, its URL is what approve and disburse take
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", document.URL)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(document)
+}
+
+func (d Delivery) GetLoanDocuments(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is GET
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract the loan ID from the URL path
+	loanID, err := strconv.ParseInt(r.PathValue("loan_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's GetLoanDocuments method
+	documents, err := d.UsecaseInterface.GetLoanDocuments(r.Context(), loanID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get loan documents", "loan_id", loanID, "error", err)
+		http.Error(w, "Failed to get loan documents", statusFromError(err))
+		return
+	}
+
+	// Send the documents in the response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(documents)
+}
+
+func (d Delivery) GetLoanDocument(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is GET
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract the loan ID and the document SHA-256 from the URL path
+	loanID, sha256, ok := model.ParseDocumentURL(r.URL.Path)
+	if !ok {
+		http.Error(w, "Invalid document URL", http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's OpenLoanDocument method
+	document, content, err := d.UsecaseInterface.OpenLoanDocument(r.Context(), loanID, sha256)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get loan document", "loan_id", loanID, "sha256", sha256, "error", err)
+		http.Error(w, "Failed to get loan document", statusFromError(err))
+		return
+	}
+	defer content.Close()
+
+	// Send the content as uploaded, the hash doubles as a strong ETag
+	w.Header().Set("Content-Type", document.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(document.Size, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+document.SHA256+`"`)
+	if _, err := io.Copy(w, content); err != nil {
+		slog.ErrorContext(r.Context(), "failed to send loan document", "loan_id", loanID, "sha256", sha256, "error", err)
+	}
+}
@@ -0,0 +1,41 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+	"github.com/timotiusas11/amartha-assignment/internal/usecase"
+)
+
+// StatusClientClosedRequest is the non-standard status popularised by nginx for
+// requests abandoned by the client.
+const StatusClientClosedRequest = 499
+
+// statusFromError maps usecase errors to HTTP status codes.
+func statusFromError(err error) int {
+	switch {
+	case errors.Is(err, model.ErrInvalidQuery):
+		return http.StatusBadRequest
+	case errors.Is(err, model.ErrDocumentTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, model.ErrUnsupportedDocumentType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, model.ErrInsufficientFunds):
+		return http.StatusPaymentRequired
+	case errors.Is(err, model.ErrLoanNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, model.ErrBorrowerExists), errors.Is(err, model.ErrInvalidState):
+		return http.StatusConflict
+	case errors.Is(err, usecase.ErrForbidden), errors.Is(err, model.ErrBorrowerNotEligible):
+		return http.StatusForbidden
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		// The client is gone, nobody reads this but it keeps the access log honest
+		return StatusClientClosedRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
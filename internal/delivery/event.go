@@ -0,0 +1,69 @@
+package delivery
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+func (d Delivery) GetLoanEvents(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is GET
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract the loan ID from the URL path
+	loanID, err := strconv.ParseInt(r.PathValue("loan_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse filters and pagination from the query string
+	query, err := parseEventQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's GetLoanEvents method
+	events, err := d.UsecaseInterface.GetLoanEvents(r.Context(), loanID, query)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get loan events", "loan_id", loanID, "error", err)
+		http.Error(w, "Failed to get loan events", statusFromError(err))
+		return
+	}
+
+	// Send the events in the response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+func (d Delivery) AdminEvents(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is GET
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse filters and pagination from the query string
+	query, err := parseEventQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's AdminEvents method
+	events, err := d.UsecaseInterface.AdminEvents(r.Context(), query)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get events", "error", err)
+		http.Error(w, "Failed to get events", statusFromError(err))
+		return
+	}
+
+	// Send the events in the response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
@@ -0,0 +1,141 @@
+package delivery
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/timotiusas11/amartha-assignment/internal/ledger"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+func (d Delivery) AdminTrialBalance(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is GET
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Call the usecase's TrialBalance method
+	trialBalance, err := d.UsecaseInterface.TrialBalance(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get trial balance", "error", err)
+		http.Error(w, "Failed to get trial balance", statusFromError(err))
+		return
+	}
+
+	// Send the trial balance in the response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trialBalance)
+}
+
+func (d Delivery) AdminJournal(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is GET
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse filters and pagination from the query string
+	query, err := parseJournalQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's GetJournalEntries method
+	entries, err := d.UsecaseInterface.GetJournalEntries(r.Context(), query)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get journal entries", "error", err)
+		http.Error(w, "Failed to get journal entries", statusFromError(err))
+		return
+	}
+
+	// Send the entries in the response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+func parseJournalQuery(values url.Values) (ledger.EntryQuery, error) {
+	query := ledger.EntryQuery{Account: ledger.Account(values.Get("account"))}
+
+	ids := map[string]*int64{
+		"loan_id": &query.LoanID,
+		"after":   &query.AfterID,
+	}
+	for key, target := range ids {
+		raw := values.Get(key)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return ledger.EntryQuery{}, fmt.Errorf("invalid %s %q", key, raw)
+		}
+		*target = v
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return ledger.EntryQuery{}, fmt.Errorf("invalid limit %q", raw)
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
+
+func (d Delivery) AdminRevenue(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is GET
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse the grouping and filters from the query string
+	query, err := parseRevenueQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's AdminRevenue method
+	report, err := d.UsecaseInterface.AdminRevenue(r.Context(), query)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get revenue", "error", err)
+		http.Error(w, "Failed to get revenue", statusFromError(err))
+		return
+	}
+
+	// Send the report in the response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func parseRevenueQuery(values url.Values) (model.RevenueQuery, error) {
+	query := model.RevenueQuery{Product: values.Get("product")}
+
+	if raw := values.Get("period"); raw != "" {
+		period, err := model.ParseRevenuePeriod(raw)
+		if err != nil {
+			return model.RevenueQuery{}, err
+		}
+		query.Period = period
+	}
+
+	var err error
+	query.From, err = parseTime(values, "from")
+	if err != nil {
+		return model.RevenueQuery{}, err
+	}
+	query.To, err = parseTime(values, "to")
+	if err != nil {
+		return model.RevenueQuery{}, err
+	}
+
+	return query, nil
+}
@@ -0,0 +1,35 @@
+package delivery
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+func (d Delivery) GetPortfolio(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is GET
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract the investor ID from the URL path
+	investorID, err := strconv.ParseInt(r.PathValue("investor_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid investor ID", http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's GetPortfolio method
+	portfolio, err := d.UsecaseInterface.GetPortfolio(r.Context(), investorID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get portfolio", "investor_id", investorID, "error", err)
+		http.Error(w, "Failed to get portfolio", statusFromError(err))
+		return
+	}
+
+	// Send the portfolio in the response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(portfolio)
+}
@@ -0,0 +1,69 @@
+package delivery
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+func (d Delivery) AdminLoanAsOf(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is GET
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract the loan ID from the URL path
+	loanID, err := strconv.ParseInt(r.PathValue("loan_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	// The loan as it was at as_of, or as it is now when omitted
+	asOf, err := parseTime(r.URL.Query(), "as_of")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's AdminLoanAsOf method
+	loan, err := d.UsecaseInterface.AdminLoanAsOf(r.Context(), loanID, asOf)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to replay loan", "loan_id", loanID, "error", err)
+		http.Error(w, "Failed to replay loan", statusFromError(err))
+		return
+	}
+
+	// Send the loan in the response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(loan)
+}
+
+func (d Delivery) AdminRebuildProjections(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is POST
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// A dry run only reports how the projections differ from the log
+	dryRun, err := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	if err != nil && r.URL.Query().Has("dry_run") {
+		http.Error(w, "Invalid dry_run", http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's AdminRebuildProjections method
+	report, err := d.UsecaseInterface.AdminRebuildProjections(r.Context(), dryRun)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to rebuild projections", "error", err)
+		http.Error(w, "Failed to rebuild projections", statusFromError(err))
+		return
+	}
+
+	// Send the report in the response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,154 @@
+package delivery
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+// parseLoanQuery reads the GET /loans filters, sort and pagination parameters.
+//
+//	state=approved,invested  borrower_id=1
+//	min_principal_amount / max_principal_amount, min_rate / max_rate,
+//	min_roi / max_roi, min_remaining_amount / max_remaining_amount
+//	created_from / created_to (RFC 3339 or YYYY-MM-DD)
+//	sort=-principal_amount (leading "-" for descending)  cursor=...  limit=20
+func parseLoanQuery(values url.Values) (model.LoanQuery, error) {
+	var query model.LoanQuery
+
+	if raw := values.Get("state"); raw != "" {
+		for _, name := range strings.Split(raw, ",") {
+			state, err := model.ParseStateEnum(strings.TrimSpace(name))
+			if err != nil {
+				return model.LoanQuery{}, err
+			}
+			query.States = append(query.States, state)
+		}
+	}
+
+	if raw := values.Get("borrower_id"); raw != "" {
+		borrowerID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return model.LoanQuery{}, fmt.Errorf("invalid borrower_id %q", raw)
+		}
+		query.BorrowerID = borrowerID
+	}
+
+	ranges := map[string]*model.Range{
+		"principal_amount": &query.PrincipalAmount,
+		"rate":             &query.Rate,
+		"roi":              &query.ROI,
+		"remaining_amount": &query.RemainingAmount,
+	}
+	for name, r := range ranges {
+		var err error
+		if r.Min, err = parseBound(values, "min_"+name); err != nil {
+			return model.LoanQuery{}, err
+		}
+		if r.Max, err = parseBound(values, "max_"+name); err != nil {
+			return model.LoanQuery{}, err
+		}
+	}
+
+	var err error
+	if query.CreatedFrom, err = parseTime(values, "created_from"); err != nil {
+		return model.LoanQuery{}, err
+	}
+	if query.CreatedTo, err = parseTime(values, "created_to"); err != nil {
+		return model.LoanQuery{}, err
+	}
+
+	if raw := values.Get("sort"); raw != "" {
+		query.Descending = strings.HasPrefix(raw, "-")
+		query.SortBy = model.LoanSortField(strings.TrimPrefix(raw, "-"))
+	}
+
+	query.Cursor = values.Get("cursor")
+
+	if raw := values.Get("limit"); raw != "" {
+		query.Limit, err = strconv.Atoi(raw)
+		if err != nil || query.Limit <= 0 {
+			return model.LoanQuery{}, fmt.Errorf("invalid limit %q", raw)
+		}
+	}
+
+	return query, nil
+}
+
+func parseBound(values url.Values, key string) (*float64, error) {
+	raw := values.Get(key)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", key, raw)
+	}
+	return &v, nil
+}
+
+func parseTime(values url.Values, key string) (time.Time, error) {
+	raw := values.Get(key)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, raw); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid %s %q, expected RFC 3339 or YYYY-MM-DD", key, raw)
+}
+
+// parseEventQuery reads the event log filters and pagination parameters.
+//
+//	type=LoanApproved,CommandRejected  actor_id=2  loan_id=1 (admin log only)
+//	since / until (RFC 3339 or YYYY-MM-DD)  after=42  limit=100
+func parseEventQuery(values url.Values) (model.EventQuery, error) {
+	var query model.EventQuery
+
+	if raw := values.Get("type"); raw != "" {
+		for _, name := range strings.Split(raw, ",") {
+			query.Types = append(query.Types, model.EventType(strings.TrimSpace(name)))
+		}
+	}
+
+	ids := map[string]*int64{
+		"loan_id":  &query.LoanID,
+		"actor_id": &query.ActorID,
+		"after":    &query.AfterID,
+	}
+	for key, target := range ids {
+		raw := values.Get(key)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return model.EventQuery{}, fmt.Errorf("invalid %s %q", key, raw)
+		}
+		*target = v
+	}
+
+	var err error
+	if query.Since, err = parseTime(values, "since"); err != nil {
+		return model.EventQuery{}, err
+	}
+	if query.Until, err = parseTime(values, "until"); err != nil {
+		return model.EventQuery{}, err
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		query.Limit, err = strconv.Atoi(raw)
+		if err != nil || query.Limit <= 0 {
+			return model.EventQuery{}, fmt.Errorf("invalid limit %q", raw)
+		}
+	}
+
+	return query, nil
+}
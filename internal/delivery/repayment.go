@@ -0,0 +1,51 @@
+package delivery
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// RepaymentRequest is the payload of a repayment. The late fee is paid on top
+// of the amount.
+type RepaymentRequest struct {
+	Amount  float64 `json:"amount"`
+	LateFee float64 `json:"late_fee"`
+}
+
+func (d Delivery) Repay(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is POST
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract the loan ID from the URL path
+	loanID, err := strconv.ParseInt(r.PathValue("loan_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	// Decode the request body into RepaymentRequest struct
+	var request RepaymentRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's Repay method
+	repayment, err := d.UsecaseInterface.Repay(r.Context(), loanID, request.Amount, request.LateFee)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to repay loan", "loan_id", loanID, "error", err)
+		http.Error(w, "Failed to repay loan", statusFromError(err))
+		return
+	}
+
+	// Send the repayment with the payout of every investor
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(repayment)
+}
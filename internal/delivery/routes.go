@@ -0,0 +1,55 @@
+package delivery
+
+import (
+	"net/http"
+
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+)
+
+// Authorizer wraps a handler so that it only runs for callers holding permission.
+type Authorizer func(permission auth.Permission, handler http.HandlerFunc) http.Handler
+
+// Register adds the loan API routes to mux. The service and the in-process
+// server of loadgen share it, so both expose the same API.
+func (d Delivery) Register(mux *http.ServeMux, authorized Authorizer) {
+	mux.Handle("GET /loans", authorized(auth.PermissionReadLoan, d.Loan))
+	mux.Handle("POST /loans", authorized(auth.PermissionCreateLoan, d.Loan))
+	mux.Handle("/loans/{loan_id}", authorized(auth.PermissionReadLoan, d.GetLoan))
+	mux.Handle("/loans/{loan_id}/approve", authorized(auth.PermissionApproveLoan, d.Approve))
+	mux.Handle("/loans/{loan_id}/invest", authorized(auth.PermissionInvestLoan, d.Invest))
+	mux.Handle("/loans/{loan_id}/disburse", authorized(auth.PermissionDisburseLoan, d.Disburse))
+	mux.Handle("/loans/{loan_id}/cancel", authorized(auth.PermissionCancelLoan, d.Cancel))
+	mux.Handle("/loans/{loan_id}/events", authorized(auth.PermissionReadEvents, d.GetLoanEvents))
+	mux.Handle("POST /loans/{loan_id}/documents", authorized(auth.PermissionUploadDocument, d.UploadDocument))
+	mux.Handle("GET /loans/{loan_id}/documents", authorized(auth.PermissionReadDocuments, d.GetLoanDocuments))
+	mux.Handle("/loans/{loan_id}/documents/{sha256}", authorized(auth.PermissionReadDocuments, d.GetLoanDocument))
+	mux.Handle("POST /loans/{loan_id}/repayments", authorized(auth.PermissionRepayLoan, d.Repay))
+	mux.Handle("POST /borrowers", authorized(auth.PermissionWriteBorrower, d.RegisterBorrower))
+	mux.Handle("GET /borrowers", authorized(auth.PermissionReadBorrower, d.GetBorrowers))
+	mux.Handle("GET /borrowers/{borrower_id}", authorized(auth.PermissionReadBorrower, d.Borrower))
+	mux.Handle("PUT /borrowers/{borrower_id}", authorized(auth.PermissionWriteBorrower, d.Borrower))
+	mux.Handle("DELETE /borrowers/{borrower_id}", authorized(auth.PermissionAdmin, d.Borrower))
+	mux.Handle("/borrowers/{borrower_id}/kyc", authorized(auth.PermissionReviewKYC, d.ReviewKYC))
+	mux.Handle("POST /borrowers/{borrower_id}/documents", authorized(auth.PermissionUploadDocument, d.UploadBorrowerDocument))
+	mux.Handle("/borrowers/{borrower_id}/documents/{sha256}", authorized(auth.PermissionReadBorrower, d.GetBorrowerDocument))
+	mux.Handle("POST /investors/{investor_id}/wallet/deposits", authorized(auth.PermissionDepositWallet, d.Deposit))
+	mux.Handle("GET /investors/{investor_id}/wallet", authorized(auth.PermissionReadWallet, d.GetWallet))
+	mux.Handle("GET /investors/{investor_id}/wallet/entries", authorized(auth.PermissionReadWallet, d.GetWalletEntries))
+	mux.Handle("GET /investors/{investor_id}/portfolio", authorized(auth.PermissionReadWallet, d.GetPortfolio))
+
+	// For admin only
+	mux.Handle("/admin/view/loans", authorized(auth.PermissionAdmin, d.AdminViewLoans))
+	mux.Handle("/admin/cache/stats", authorized(auth.PermissionAdmin, d.AdminCacheStats))
+	mux.Handle("/admin/events", authorized(auth.PermissionAdmin, d.AdminEvents))
+	mux.Handle("/admin/loans/expire", authorized(auth.PermissionAdmin, d.AdminExpireLoans))
+	mux.Handle("/admin/loans/{loan_id}", authorized(auth.PermissionAdmin, d.AdminLoanAsOf))
+	mux.Handle("/admin/loans/{loan_id}/transition", authorized(auth.PermissionAdmin, d.AdminForceTransition))
+	mux.Handle("/admin/loans/{loan_id}/republish", authorized(auth.PermissionAdmin, d.AdminRepublish))
+	mux.Handle("/admin/projections/rebuild", authorized(auth.PermissionAdmin, d.AdminRebuildProjections))
+	mux.Handle("/admin/wallets", authorized(auth.PermissionAdmin, d.AdminWallets))
+	mux.Handle("/admin/ledger/trial-balance", authorized(auth.PermissionAdmin, d.AdminTrialBalance))
+	mux.Handle("/admin/ledger/entries", authorized(auth.PermissionAdmin, d.AdminJournal))
+	mux.Handle("/admin/revenue", authorized(auth.PermissionAdmin, d.AdminRevenue))
+	mux.Handle("/admin/export", authorized(auth.PermissionAdmin, d.AdminExport))
+	mux.Handle("/admin/restore", authorized(auth.PermissionAdmin, d.AdminRestore))
+}
@@ -0,0 +1,163 @@
+package delivery
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+// AmountRequest is the payload of a deposit.
+type AmountRequest struct {
+	Amount float64 `json:"amount"`
+}
+
+func (d Delivery) Deposit(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is POST
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract the investor ID from the URL path
+	investorID, err := strconv.ParseInt(r.PathValue("investor_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid investor ID", http.StatusBadRequest)
+		return
+	}
+
+	// Decode the request body into AmountRequest struct
+	var request AmountRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's Deposit method
+	wallet, err := d.UsecaseInterface.Deposit(r.Context(), investorID, request.Amount)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to deposit", "investor_id", investorID, "error", err)
+		http.Error(w, "Failed to deposit", statusFromError(err))
+		return
+	}
+
+	// Send the wallet with its new balance
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wallet)
+}
+
+func (d Delivery) GetWallet(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is GET
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract the investor ID from the URL path
+	investorID, err := strconv.ParseInt(r.PathValue("investor_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid investor ID", http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's GetWallet method
+	wallet, err := d.UsecaseInterface.GetWallet(r.Context(), investorID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get wallet", "investor_id", investorID, "error", err)
+		http.Error(w, "Failed to get wallet", statusFromError(err))
+		return
+	}
+
+	// Send the wallet in the response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wallet)
+}
+
+func (d Delivery) GetWalletEntries(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is GET
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract the investor ID from the URL path
+	investorID, err := strconv.ParseInt(r.PathValue("investor_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid investor ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse filters and pagination from the query string
+	query, err := parseWalletEntryQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Call the usecase's GetWalletEntries method
+	entries, err := d.UsecaseInterface.GetWalletEntries(r.Context(), investorID, query)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get wallet entries", "investor_id", investorID, "error", err)
+		http.Error(w, "Failed to get wallet entries", statusFromError(err))
+		return
+	}
+
+	// Send the entries in the response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+func (d Delivery) AdminWallets(w http.ResponseWriter, r *http.Request) {
+	// Check if the method is GET
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Call the usecase's AdminWallets method
+	wallets, err := d.UsecaseInterface.AdminWallets(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get wallets", "error", err)
+		http.Error(w, "Failed to get wallets", statusFromError(err))
+		return
+	}
+
+	// Send the wallets in the response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wallets)
+}
+
+func parseWalletEntryQuery(values url.Values) (model.WalletEntryQuery, error) {
+	var query model.WalletEntryQuery
+
+	ids := map[string]*int64{
+		"loan_id": &query.LoanID,
+		"after":   &query.AfterID,
+	}
+	for key, target := range ids {
+		raw := values.Get(key)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return model.WalletEntryQuery{}, fmt.Errorf("invalid %s %q", key, raw)
+		}
+		*target = v
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return model.WalletEntryQuery{}, fmt.Errorf("invalid limit %q", raw)
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
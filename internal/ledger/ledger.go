@@ -0,0 +1,380 @@
+// Package ledger keeps the books of the platform in double entry.
+//
+// Every money movement is a journal entry whose postings debit some accounts
+// and credit others by the same total, so the balances of all accounts, debits
+// positive and credits negative, always sum to zero. The accounts are:
+//
+//	cash                        the platform's bank account
+//	investor_wallet:{id}        what the platform owes an investor, available, reserved or lent
+//	disbursement_clearing:{id}  money held for a loan, invested but not disbursed, or repaid but not paid out
+//	borrower_receivable:{id}    principal the borrower of a loan still owes
+//	platform_fees               what the platform earned
+//
+// A loan moves money through them as:
+//
+//	deposit          Dr cash                   Cr investor_wallet
+//	invest           Dr disbursement_clearing  Cr cash
+//	release          Dr cash                   Cr disbursement_clearing
+//	disburse         Dr borrower_receivable    Cr disbursement_clearing
+//	origination_fee  Dr cash                   Cr platform_fees
+//	repayment        Dr cash                   Cr disbursement_clearing
+//	payout           Dr disbursement_clearing  Cr borrower_receivable, investor_wallet, platform_fees
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnbalanced is returned for a journal entry whose debits and credits
+// differ, or that has no postings.
+var ErrUnbalanced = errors.New("unbalanced journal entry")
+
+// ErrEntriesMissing is returned when entries that should be in the journal
+// were evicted.
+var ErrEntriesMissing = errors.New("entries are missing from the journal")
+
+// Account is the name of a ledger account, with the ID of the investor or loan
+// it belongs to after a colon.
+type Account string
+
+const (
+	AccountCash         Account = "cash"
+	AccountPlatformFees Account = "platform_fees"
+)
+
+// Kinds of the accounts kept per investor or per loan.
+const (
+	KindInvestorWallet       = "investor_wallet"
+	KindDisbursementClearing = "disbursement_clearing"
+	KindBorrowerReceivable   = "borrower_receivable"
+)
+
+func InvestorWallet(investorID int64) Account {
+	return account(KindInvestorWallet, investorID)
+}
+
+func DisbursementClearing(loanID int64) Account {
+	return account(KindDisbursementClearing, loanID)
+}
+
+func BorrowerReceivable(loanID int64) Account {
+	return account(KindBorrowerReceivable, loanID)
+}
+
+func account(kind string, id int64) Account {
+	return Account(kind + ":" + strconv.FormatInt(id, 10))
+}
+
+// Kind returns the account name without its ID, e.g. investor_wallet.
+func (a Account) Kind() string {
+	kind, _, _ := strings.Cut(string(a), ":")
+	return kind
+}
+
+type EntryType string
+
+const (
+	EntryDeposit        EntryType = "deposit"         // An investor topped up their wallet
+	EntryInvest         EntryType = "invest"          // An investor put money in a loan
+	EntryRelease        EntryType = "release"         // A loan was cancelled before disbursement
+	EntryDisburse       EntryType = "disburse"        // A loan was paid to its borrower
+	EntryOriginationFee EntryType = "origination_fee" // Part of a disbursement was withheld by the platform
+	EntryRepayment      EntryType = "repayment"       // The borrower paid money back
+	EntryPayout         EntryType = "payout"          // A repayment was shared among the investors and the platform
+)
+
+// Posting debits or credits an account. Exactly one of Debit and Credit is set.
+type Posting struct {
+	Account Account `json:"account"`
+	Debit   float64 `json:"debit,omitempty"`
+	Credit  float64 `json:"credit,omitempty"`
+}
+
+// Entry is a journal entry. Entries are never updated or removed, a mistake is
+// corrected by posting the reversal.
+type Entry struct {
+	EntryID    int64     `json:"entry_id"` // Position in the journal, starting at 1
+	Type       EntryType `json:"type"`
+	LoanID     int64     `json:"loan_id,omitempty"`
+	Postings   []Posting `json:"postings"`
+	Reverses   int64     `json:"reverses,omitempty"` // ID of the entry this one takes back, its debits and credits swapped
+	ActorID    int64     `json:"actor_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	RequestID  string    `json:"request_id,omitempty"`
+}
+
+// Validate checks that the entry has postings of whole cents, each on one
+// side only, and that its debits and credits are equal.
+func (e Entry) Validate() error {
+	if len(e.Postings) < 2 {
+		return fmt.Errorf("%w: %s entry needs at least two postings", ErrUnbalanced, e.Type)
+	}
+	var debits, credits float64
+	for _, posting := range e.Postings {
+		if posting.Account == "" {
+			return fmt.Errorf("%s entry has a posting without account", e.Type)
+		}
+		if posting.Debit < 0 || posting.Credit < 0 || (posting.Debit == 0) == (posting.Credit == 0) {
+			return fmt.Errorf("posting to %s must either debit or credit a positive amount", posting.Account)
+		}
+		if posting.Debit != round(posting.Debit) || posting.Credit != round(posting.Credit) {
+			return fmt.Errorf("posting to %s is not in whole cents", posting.Account)
+		}
+		debits += posting.Debit
+		credits += posting.Credit
+	}
+	if round(debits) != round(credits) {
+		return fmt.Errorf("%w: %s entry debits %v and credits %v", ErrUnbalanced, e.Type, round(debits), round(credits))
+	}
+	return nil
+}
+
+// Reversal returns the entry taking back e, which must have been posted: every
+// debit of e becomes a credit and every credit a debit.
+func (e Entry) Reversal() Entry {
+	postings := make([]Posting, len(e.Postings))
+	for i, posting := range e.Postings {
+		postings[i] = Posting{Account: posting.Account, Debit: posting.Credit, Credit: posting.Debit}
+	}
+	return Entry{
+		Type:     e.Type,
+		LoanID:   e.LoanID,
+		Postings: postings,
+		Reverses: e.EntryID,
+	}
+}
+
+// Deposit is money sent in by an investor.
+func Deposit(investorID int64, amount float64) Entry {
+	return transfer(EntryDeposit, 0, AccountCash, InvestorWallet(investorID), amount)
+}
+
+// Invest sets money aside for a loan. The investor's wallet still holds it,
+// only the cash backing it moves.
+func Invest(loanID int64, amount float64) Entry {
+	return transfer(EntryInvest, loanID, DisbursementClearing(loanID), AccountCash, amount)
+}
+
+// Release gives back the money set aside for a cancelled loan.
+func Release(loanID int64, amount float64) Entry {
+	return transfer(EntryRelease, loanID, AccountCash, DisbursementClearing(loanID), amount)
+}
+
+// Disburse pays the money set aside for a loan to its borrower, who then owes
+// it.
+func Disburse(loanID int64, amount float64) Entry {
+	return transfer(EntryDisburse, loanID, BorrowerReceivable(loanID), DisbursementClearing(loanID), amount)
+}
+
+// OriginationFee is the part of a disbursement the platform withholds: the
+// borrower owes the whole amount, but that part stays in cash as revenue.
+func OriginationFee(loanID int64, amount float64) Entry {
+	return transfer(EntryOriginationFee, loanID, AccountCash, AccountPlatformFees, amount)
+}
+
+// Repayment is money received from the borrower of a loan, held until it is
+// paid out.
+func Repayment(loanID int64, amount float64) Entry {
+	return transfer(EntryRepayment, loanID, AccountCash, DisbursementClearing(loanID), amount)
+}
+
+// Share is what an investor gets of a repayment.
+type Share struct {
+	InvestorID int64
+	Principal  float64
+	Return     float64
+}
+
+// Payout shares a repayment: the principal paid back settles the borrower's
+// receivable, the returns are credited to the investors' wallets and the rest
+// is the platform's fee. A negative fee is a cost of the platform, when it
+// promised investors more than the borrower pays.
+func Payout(loanID int64, shares []Share, fee float64) Entry {
+	var principal, total float64
+	var credits []Posting
+	for _, share := range shares {
+		principal += share.Principal
+		total += share.Principal + share.Return
+		credits = appendCredit(credits, InvestorWallet(share.InvestorID), share.Return)
+	}
+	credits = appendCredit(credits, BorrowerReceivable(loanID), principal)
+	credits = appendCredit(credits, AccountPlatformFees, fee)
+	total += fee
+
+	// The whole repayment leaves the clearing account
+	return Entry{
+		Type:     EntryPayout,
+		LoanID:   loanID,
+		Postings: append(appendCredit(nil, DisbursementClearing(loanID), -total), credits...),
+	}
+}
+
+func transfer(entryType EntryType, loanID int64, debit, credit Account, amount float64) Entry {
+	return Entry{
+		Type:   entryType,
+		LoanID: loanID,
+		Postings: []Posting{
+			{Account: debit, Debit: round(amount)},
+			{Account: credit, Credit: round(amount)},
+		},
+	}
+}
+
+// appendCredit credits amount to the account, or debits it when negative.
+// Zero amounts are left out.
+func appendCredit(postings []Posting, account Account, amount float64) []Posting {
+	switch amount = round(amount); {
+	case amount > 0:
+		return append(postings, Posting{Account: account, Credit: amount})
+	case amount < 0:
+		return append(postings, Posting{Account: account, Debit: -amount})
+	default:
+		return postings
+	}
+}
+
+// Balance is the balance of an account, debits positive.
+type Balance struct {
+	Account Account `json:"account"`
+	Debit   float64 `json:"debit"`   // Sum of the debits posted
+	Credit  float64 `json:"credit"`  // Sum of the credits posted
+	Balance float64 `json:"balance"` // Debit minus credit
+}
+
+// Apply adds the postings of the entry to the balances, keyed by account.
+func Apply(balances map[Account]Balance, entry Entry) {
+	for _, posting := range entry.Postings {
+		balance := balances[posting.Account]
+		balance.Account = posting.Account
+		balance.Debit = round(balance.Debit + posting.Debit)
+		balance.Credit = round(balance.Credit + posting.Credit)
+		balance.Balance = round(balance.Debit - balance.Credit)
+		balances[posting.Account] = balance
+	}
+}
+
+// TrialBalance lists the balance of every account. The books are balanced
+// when the debits equal the credits, that is when the balances sum to zero.
+type TrialBalance struct {
+	Accounts []Balance         `json:"accounts"`
+	Kinds    map[string]Summed `json:"kinds"` // Balances summed per account kind
+	Debit    float64           `json:"debit"`
+	Credit   float64           `json:"credit"`
+	Balance  float64           `json:"balance"`
+	Balanced bool              `json:"balanced"`
+
+	// Differences lists the investor wallet accounts that do not owe what the
+	// wallet of the investor holds, Reconciled is true when there are none
+	Differences []Difference `json:"differences"`
+	Reconciled  bool         `json:"reconciled"`
+}
+
+type Summed struct {
+	Accounts int     `json:"accounts"`
+	Balance  float64 `json:"balance"`
+}
+
+// Difference is an account whose balance disagrees with the books it is
+// reconciled against.
+type Difference struct {
+	Account Account `json:"account"`
+	Owed    float64 `json:"owed"` // Credit balance of the account
+	Held    float64 `json:"held"` // What the other books hold
+}
+
+// NewTrialBalance totals the balances of the accounts the entries post to,
+// sorted by account.
+func NewTrialBalance(entries []Entry) TrialBalance {
+	balances := make(map[Account]Balance)
+	for _, entry := range entries {
+		Apply(balances, entry)
+	}
+
+	tb := TrialBalance{
+		Accounts:    make([]Balance, 0, len(balances)),
+		Kinds:       make(map[string]Summed),
+		Differences: make([]Difference, 0),
+		Reconciled:  true,
+	}
+	for _, balance := range balances {
+		tb.Accounts = append(tb.Accounts, balance)
+	}
+	slices.SortFunc(tb.Accounts, func(a, b Balance) int { return strings.Compare(string(a.Account), string(b.Account)) })
+
+	for _, balance := range tb.Accounts {
+		tb.Debit += balance.Debit
+		tb.Credit += balance.Credit
+
+		kind := tb.Kinds[balance.Account.Kind()]
+		kind.Accounts++
+		kind.Balance = round(kind.Balance + balance.Balance)
+		tb.Kinds[balance.Account.Kind()] = kind
+	}
+	tb.Debit, tb.Credit = round(tb.Debit), round(tb.Credit)
+	tb.Balance = round(tb.Debit - tb.Credit)
+	tb.Balanced = tb.Balance == 0
+	return tb
+}
+
+// Reconcile compares the accounts of a kind with what other books hold for
+// them, keyed by account. An account missing on either side counts as zero.
+func (tb *TrialBalance) Reconcile(kind string, held map[Account]float64) {
+	owed := make(map[Account]float64)
+	for _, balance := range tb.Accounts {
+		if balance.Account.Kind() == kind {
+			owed[balance.Account] = -balance.Balance
+		}
+	}
+	for account := range held {
+		if _, ok := owed[account]; !ok && account.Kind() == kind {
+			owed[account] = 0
+		}
+	}
+
+	for account, amount := range owed {
+		if round(held[account]) != amount {
+			tb.Differences = append(tb.Differences, Difference{Account: account, Owed: amount, Held: round(held[account])})
+		}
+	}
+	slices.SortFunc(tb.Differences, func(a, b Difference) int { return strings.Compare(string(a.Account), string(b.Account)) })
+	tb.Reconciled = len(tb.Differences) == 0
+}
+
+// EntryQuery filters the journal. Zero values mean "no filter".
+type EntryQuery struct {
+	LoanID  int64
+	Account Account
+	AfterID int64 // Only entries after this one, for pagination
+	Limit   int
+
+	// Complete fails the query with ErrEntriesMissing when one of the entries
+	// it covers is gone, instead of leaving it out. Balances need every entry.
+	Complete bool
+}
+
+// Matches reports whether the entry passes the loan and account filters.
+func (q EntryQuery) Matches(entry Entry) bool {
+	if q.LoanID != 0 && entry.LoanID != q.LoanID {
+		return false
+	}
+	if q.Account != "" && !slices.ContainsFunc(entry.Postings, func(p Posting) bool { return p.Account == q.Account }) {
+		return false
+	}
+	return true
+}
+
+type EntryPage struct {
+	Entries   []Entry `json:"entries"`
+	NextAfter int64   `json:"next_after,omitempty"` // Pass as after to get the next page
+}
+
+// round keeps amounts in cents, like model.RoundAmount.
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,228 @@
+package ledger
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEntryValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		postings   []Posting
+		wantErr    bool
+		unbalanced bool // The error wraps ErrUnbalanced
+	}{
+		{
+			name:     "balanced",
+			postings: []Posting{{Account: AccountCash, Debit: 100}, {Account: InvestorWallet(7), Credit: 100}},
+		},
+		{
+			name:     "split credit",
+			postings: []Posting{{Account: AccountCash, Debit: 0.3}, {Account: InvestorWallet(7), Credit: 0.1}, {Account: InvestorWallet(8), Credit: 0.2}},
+		},
+		{
+			name:       "no postings",
+			wantErr:    true,
+			unbalanced: true,
+		},
+		{
+			name:       "single posting",
+			postings:   []Posting{{Account: AccountCash, Debit: 100}},
+			wantErr:    true,
+			unbalanced: true,
+		},
+		{
+			name:       "debits and credits differ",
+			postings:   []Posting{{Account: AccountCash, Debit: 100}, {Account: InvestorWallet(7), Credit: 90}},
+			wantErr:    true,
+			unbalanced: true,
+		},
+		{
+			name:     "posting without account",
+			postings: []Posting{{Account: AccountCash, Debit: 100}, {Credit: 100}},
+			wantErr:  true,
+		},
+		{
+			name:     "posting on both sides",
+			postings: []Posting{{Account: AccountCash, Debit: 100, Credit: 100}, {Account: InvestorWallet(7), Debit: 100, Credit: 100}},
+			wantErr:  true,
+		},
+		{
+			name:     "empty posting",
+			postings: []Posting{{Account: AccountCash, Debit: 100}, {Account: AccountPlatformFees}, {Account: InvestorWallet(7), Credit: 100}},
+			wantErr:  true,
+		},
+		{
+			name:     "negative amount",
+			postings: []Posting{{Account: AccountCash, Debit: -100}, {Account: InvestorWallet(7), Credit: -100}},
+			wantErr:  true,
+		},
+		{
+			name:     "fraction of a cent",
+			postings: []Posting{{Account: AccountCash, Debit: 100.005}, {Account: InvestorWallet(7), Credit: 100.005}},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Entry{Type: EntryDeposit, Postings: tt.postings}.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := errors.Is(err, ErrUnbalanced); got != tt.unbalanced {
+				t.Errorf("Validate() error = %v, wraps ErrUnbalanced %v, want %v", err, got, tt.unbalanced)
+			}
+		})
+	}
+}
+
+func TestPayout(t *testing.T) {
+	shares := []Share{
+		{InvestorID: 7, Principal: 200, Return: 16},
+		{InvestorID: 8, Principal: 300, Return: 24},
+		{InvestorID: 9, Principal: 0.01},
+	}
+
+	tests := []struct {
+		name string
+		fee  float64
+		want []Posting
+	}{
+		{
+			name: "fee",
+			fee:  9.99,
+			want: []Posting{
+				{Account: DisbursementClearing(1), Debit: 550},
+				{Account: InvestorWallet(7), Credit: 16},
+				{Account: InvestorWallet(8), Credit: 24},
+				{Account: BorrowerReceivable(1), Credit: 500.01},
+				{Account: AccountPlatformFees, Credit: 9.99},
+			},
+		},
+		{
+			name: "no fee",
+			want: []Posting{
+				{Account: DisbursementClearing(1), Debit: 540.01},
+				{Account: InvestorWallet(7), Credit: 16},
+				{Account: InvestorWallet(8), Credit: 24},
+				{Account: BorrowerReceivable(1), Credit: 500.01},
+			},
+		},
+		{
+			name: "negative fee",
+			fee:  -10.01,
+			want: []Posting{
+				{Account: DisbursementClearing(1), Debit: 530},
+				{Account: InvestorWallet(7), Credit: 16},
+				{Account: InvestorWallet(8), Credit: 24},
+				{Account: BorrowerReceivable(1), Credit: 500.01},
+				{Account: AccountPlatformFees, Debit: 10.01},
+			},
+		},
+		{
+			name: "fee takes the whole repayment",
+			fee:  -540.01,
+			want: []Posting{
+				{Account: InvestorWallet(7), Credit: 16},
+				{Account: InvestorWallet(8), Credit: 24},
+				{Account: BorrowerReceivable(1), Credit: 500.01},
+				{Account: AccountPlatformFees, Debit: 540.01},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := Payout(1, shares, tt.fee)
+			if entry.Type != EntryPayout || entry.LoanID != 1 {
+				t.Errorf("Payout() = %s entry of loan %d, want payout entry of loan 1", entry.Type, entry.LoanID)
+			}
+			if !reflect.DeepEqual(entry.Postings, tt.want) {
+				t.Errorf("Payout() postings = %+v, want %+v", entry.Postings, tt.want)
+			}
+			if err := entry.Validate(); err != nil {
+				t.Errorf("Payout() entry is invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestEntryReversal(t *testing.T) {
+	entry := Payout(1, []Share{{InvestorID: 7, Principal: 100, Return: 8}}, -2)
+	entry.EntryID = 5
+
+	reversal := entry.Reversal()
+	if reversal.Reverses != 5 {
+		t.Errorf("Reversal().Reverses = %d, want 5", reversal.Reverses)
+	}
+	if err := reversal.Validate(); err != nil {
+		t.Fatalf("Reversal() entry is invalid: %v", err)
+	}
+
+	balances := make(map[Account]Balance)
+	Apply(balances, entry)
+	Apply(balances, reversal)
+	for account, balance := range balances {
+		if balance.Balance != 0 {
+			t.Errorf("%s balance = %v after the reversal, want 0", account, balance.Balance)
+		}
+	}
+}
+
+func TestTrialBalance(t *testing.T) {
+	entries := []Entry{
+		Deposit(7, 1000),
+		Deposit(8, 500),
+		Invest(1, 600),
+		Disburse(1, 600),
+		Repayment(1, 330),
+		Payout(1, []Share{{InvestorID: 7, Principal: 300, Return: 24}}, 6),
+	}
+
+	tb := NewTrialBalance(entries)
+	if !tb.Balanced || tb.Balance != 0 || tb.Debit != tb.Credit {
+		t.Errorf("NewTrialBalance() debits %v and credits %v, balanced %v, want balanced", tb.Debit, tb.Credit, tb.Balanced)
+	}
+	if got := tb.Kinds[KindInvestorWallet]; got.Accounts != 2 || got.Balance != -1524 {
+		t.Errorf("NewTrialBalance() investor wallets = %+v, want 2 accounts with balance -1524", got)
+	}
+
+	tests := []struct {
+		name string
+		held map[Account]float64
+		want []Difference
+	}{
+		{
+			name: "reconciled",
+			held: map[Account]float64{InvestorWallet(7): 1024, InvestorWallet(8): 500},
+			want: []Difference{},
+		},
+		{
+			name: "wallet holds less",
+			held: map[Account]float64{InvestorWallet(7): 1000, InvestorWallet(8): 500},
+			want: []Difference{{Account: InvestorWallet(7), Owed: 1024, Held: 1000}},
+		},
+		{
+			name: "wallet missing",
+			held: map[Account]float64{InvestorWallet(7): 1024},
+			want: []Difference{{Account: InvestorWallet(8), Owed: 500}},
+		},
+		{
+			name: "account missing",
+			held: map[Account]float64{InvestorWallet(7): 1024, InvestorWallet(8): 500, InvestorWallet(9): 50},
+			want: []Difference{{Account: InvestorWallet(9), Held: 50}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := NewTrialBalance(entries)
+			tb.Reconcile(KindInvestorWallet, tt.held)
+			if !reflect.DeepEqual(tb.Differences, tt.want) {
+				t.Errorf("Reconcile() differences = %+v, want %+v", tb.Differences, tt.want)
+			}
+			if tb.Reconciled != (len(tt.want) == 0) {
+				t.Errorf("Reconcile() reconciled = %v, want %v", tb.Reconciled, len(tt.want) == 0)
+			}
+		})
+	}
+}
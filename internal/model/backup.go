@@ -0,0 +1,12 @@
+package model
+
+// RestoreReport describes a restored archive.
+type RestoreReport struct {
+	Loans          int   `json:"loans"`
+	Events         int   `json:"events"`
+	Borrowers      int   `json:"borrowers"`
+	WalletEntries  int   `json:"wallet_entries"`
+	JournalEntries int   `json:"journal_entries"`
+	LastEventID    int64 `json:"last_event_id"`
+	Resumed        bool  `json:"resumed"` // An earlier restore of the same archive failed part way
+}
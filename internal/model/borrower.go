@@ -0,0 +1,68 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+type KYCStatus string
+
+const (
+	KYCStatusPending  KYCStatus = "pending"  // Registered, not reviewed yet or changed since
+	KYCStatusVerified KYCStatus = "verified" // May propose loans
+	KYCStatusRejected KYCStatus = "rejected"
+)
+
+// Document kinds a borrower uploads for KYC.
+const (
+	DocumentIdentityCard DocumentKind = "identity_card"
+	DocumentSelfie       DocumentKind = "selfie"
+)
+
+var (
+	// ErrBorrowerNotEligible is returned when a loan is proposed for a borrower
+	// that is not registered or not KYC verified.
+	ErrBorrowerNotEligible = errors.New("borrower is not eligible for loans")
+	ErrBorrowerExists      = errors.New("borrower is already registered")
+)
+
+// Borrower is the profile behind Loan.BorrowerID, keyed by the same actor ID
+// the borrower authenticates with.
+type Borrower struct {
+	BorrowerID     int64      `json:"borrower_id"`
+	Name           string     `json:"name"`
+	IdentityNumber string     `json:"identity_number"` // National ID number
+	DateOfBirth    string     `json:"date_of_birth"`   // YYYY-MM-DD
+	Contact        Contact    `json:"contact"`
+	KYC            KYC        `json:"kyc"`
+	Documents      []Document `json:"documents"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type Contact struct {
+	Email   string `json:"email"`
+	Phone   string `json:"phone"`
+	Address string `json:"address"`
+}
+
+// KYC is the outcome of the last review of the borrower's identity.
+type KYC struct {
+	Status     KYCStatus `json:"status"`
+	Reason     string    `json:"reason,omitempty"`      // Why it was rejected or reset
+	ReviewedBy int64     `json:"reviewed_by,omitempty"` // Field validator or admin
+	ReviewedAt time.Time `json:"reviewed_at"`
+}
+
+// BorrowerProfile is the part of a borrower its owner may change.
+type BorrowerProfile struct {
+	Name           string  `json:"name"`
+	IdentityNumber string  `json:"identity_number"`
+	DateOfBirth    string  `json:"date_of_birth"`
+	Contact        Contact `json:"contact"`
+}
+
+// IdentityChanged reports whether profile changes what the KYC review verified.
+func (b Borrower) IdentityChanged(profile BorrowerProfile) bool {
+	return b.Name != profile.Name || b.IdentityNumber != profile.IdentityNumber || b.DateOfBirth != profile.DateOfBirth
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+type DocumentKind string
+
+const (
+	DocumentPictureProof          DocumentKind = "picture_proof"           // Taken by the field validator, required to approve
+	DocumentSignedAgreementLetter DocumentKind = "signed_agreement_letter" // Collected by the field officer, required to disburse
+)
+
+var (
+	ErrDocumentTooLarge        = errors.New("document is too large")
+	ErrUnsupportedDocumentType = errors.New("unsupported document content type")
+)
+
+// Document is a file uploaded for a loan or a borrower. The content is kept in
+// the blob store under its SHA-256, the metadata of a loan document in the
+// DocumentUploaded event and of a borrower document in the borrower.
+type Document struct {
+	LoanID      int64        `json:"loan_id,omitempty"`
+	BorrowerID  int64        `json:"borrower_id,omitempty"`
+	Kind        DocumentKind `json:"kind"`
+	Filename    string       `json:"filename"`     // As sent by the uploader, informational only
+	ContentType string       `json:"content_type"` // Detected from the content
+	Size        int64        `json:"size"`         // In bytes
+	SHA256      string       `json:"sha256"`       // Hex encoded, also the document ID
+	URL         string       `json:"url"`          // Internal URL to pass to approve or disburse
+	UploadedBy  int64        `json:"uploaded_by"`
+	UploadedAt  time.Time    `json:"uploaded_at"`
+}
+
+// DocumentURL is the internal URL of a document, served by the loan API.
+func DocumentURL(loanID int64, sha256 string) string {
+	return "/loans/" + strconv.FormatInt(loanID, 10) + "/documents/" + sha256
+}
+
+// BorrowerDocumentURL is the internal URL of a KYC document of a borrower.
+func BorrowerDocumentURL(borrowerID int64, sha256 string) string {
+	return "/borrowers/" + strconv.FormatInt(borrowerID, 10) + "/documents/" + sha256
+}
+
+var documentURLPattern = regexp.MustCompile(`^/loans/([0-9]+)/documents/([0-9a-f]{64})$`)
+
+// IsSHA256 reports whether s is a hex encoded SHA-256, the ID of a document.
+func IsSHA256(s string) bool {
+	return sha256Pattern.MatchString(s)
+}
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// ParseDocumentURL returns the loan and the SHA-256 of an internal document
+// URL. ok is false for any other URL.
+func ParseDocumentURL(url string) (loanID int64, sha256 string, ok bool) {
+	match := documentURLPattern.FindStringSubmatch(url)
+	if match == nil {
+		return 0, "", false
+	}
+	loanID, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return loanID, match[2], true
+}
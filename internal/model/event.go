@@ -0,0 +1,88 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrEventsMissing is returned when events that should be in the log were
+// evicted from it, so replaying it would give the wrong loans.
+var ErrEventsMissing = errors.New("events are missing from the log")
+
+type EventType string
+
+const (
+	EventLoanCreated        EventType = "LoanCreated"
+	EventLoanApproved       EventType = "LoanApproved"
+	EventInvestmentRecorded EventType = "InvestmentRecorded"
+	EventLoanInvested       EventType = "LoanInvested" // The loan is fully funded
+	EventLoanDisbursed      EventType = "LoanDisbursed"
+	EventLoanCancelled      EventType = "LoanCancelled"
+	EventLoanStateForced    EventType = "LoanStateForced" // An admin override of the state machine
+	EventDocumentUploaded   EventType = "DocumentUploaded"
+	EventRepaymentRecorded  EventType = "RepaymentRecorded"
+	EventCommandRejected    EventType = "CommandRejected" // An attempt that changed nothing
+)
+
+// Event is an entry of the append-only audit log. Events are never updated or
+// removed, the loan snapshot is derived from them.
+type Event struct {
+	EventID    int64           `json:"event_id"`             // Position in the log, starting at 1
+	LoanID     int64           `json:"loan_id"`              // 0 when the attempt did not reach a loan
+	Type       EventType       `json:"type"`                 // What happened
+	ActorID    int64           `json:"actor_id"`             // Authenticated caller, 0 when unknown
+	OccurredAt time.Time       `json:"occurred_at"`          // When it happened
+	RequestID  string          `json:"request_id,omitempty"` // Request that caused it
+	Payload    json.RawMessage `json:"payload"`              // Type specific details, see the *Payload types
+}
+
+type LoanCreatedPayload struct {
+	BorrowerID      int64     `json:"borrower_id"`
+	PrincipalAmount float64   `json:"principal_amount"`
+	Rate            float64   `json:"rate"`
+	ROI             float64   `json:"roi"`
+	Product         string    `json:"product,omitempty"`
+	FeeTerms        *FeeTerms `json:"fee_terms,omitempty"`
+}
+
+// LoanApproved carries ApprovalInfo, InvestmentRecorded an Investment,
+// LoanDisbursed DisbursementInfo, LoanCancelled CancellationInfo,
+// DocumentUploaded a Document and RepaymentRecorded a Repayment.
+
+type LoanInvestedPayload struct {
+	AgreementLetterURL string `json:"agreement_letter_url"`
+}
+
+type LoanStateForcedPayload struct {
+	From         StateEnum         `json:"from"`
+	To           StateEnum         `json:"to"`
+	Reason       string            `json:"reason"`
+	Disbursement *DisbursementInfo `json:"disbursement,omitempty"` // Set when reserved funds were paid out to the borrower
+}
+
+type CommandRejectedPayload struct {
+	Command string `json:"command"` // Usecase method, e.g. "Invest"
+	Reason  string `json:"reason"`
+	LoanID  int64  `json:"loan_id,omitempty"` // Loan named by a command on a loan that does not exist
+}
+
+// EventQuery filters the event log. Zero values mean "no filter".
+type EventQuery struct {
+	LoanID  int64
+	Types   []EventType
+	ActorID int64
+	Since   time.Time // Inclusive
+	Until   time.Time // Exclusive
+	AfterID int64     // Only events after this one, for pagination
+	Limit   int
+
+	// Complete fails the query with ErrEventsMissing when one of the events it
+	// covers is gone, instead of leaving it out. Replays need every event.
+	Complete bool
+}
+
+type EventPage struct {
+	Events    []Event `json:"events"`
+	NextAfter int64   `json:"next_after,omitempty"` // Pass as after to get the next page
+}
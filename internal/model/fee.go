@@ -0,0 +1,201 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultProduct is the product of loans proposed without one.
+const DefaultProduct = "standard"
+
+var productPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// ValidProduct reports whether name can be used as a product: lowercase
+// letters, digits, "_" and "-", at most 32 characters.
+func ValidProduct(name string) bool {
+	return productPattern.MatchString(name)
+}
+
+// FeeTerms are the fee rules a loan was proposed under. They are kept on the
+// loan, so changing the configuration does not change the loans already
+// proposed.
+type FeeTerms struct {
+	OriginationPercent     float64 `json:"origination_percent"`      // Of the amount disbursed, withheld from the borrower
+	ServicingSpreadPercent float64 `json:"servicing_spread_percent"` // Of the spread between rate and roi, kept on repayments, the rest goes to the investors
+	LateFeeSharePercent    float64 `json:"late_fee_share_percent"`   // Of late fees, the rest goes to the investors
+}
+
+// Validate checks that every percentage is between 0 and 100.
+func (t FeeTerms) Validate() error {
+	percentages := []struct {
+		name  string
+		value float64
+	}{
+		{"origination_percent", t.OriginationPercent},
+		{"servicing_spread_percent", t.ServicingSpreadPercent},
+		{"late_fee_share_percent", t.LateFeeSharePercent},
+	}
+	for _, p := range percentages {
+		if p.value < 0 || p.value > 100 {
+			return fmt.Errorf("%s must be between 0 and 100", p.name)
+		}
+	}
+	return nil
+}
+
+// ReturnPercent is the return investors get on a loan with these terms: the
+// loan's ROI plus the part of the spread the platform does not keep. A loan
+// paying investors more than its rate leaves them at ROI.
+func (t FeeTerms) ReturnPercent(rate, roi float64) float64 {
+	spread := max(rate-roi, 0)
+	return roi + spread*(100-t.ServicingSpreadPercent)/100
+}
+
+type FeeKind string
+
+const (
+	FeeOrigination FeeKind = "origination" // Withheld at disbursement
+	FeeServicing   FeeKind = "servicing"   // The platform's part of a repayment
+	FeeLate        FeeKind = "late_fee"    // The platform's share of a late fee
+)
+
+// PlatformFee is revenue the platform earned on a loan.
+type PlatformFee struct {
+	Kind      FeeKind   `json:"kind"`
+	Amount    float64   `json:"amount"`
+	ChargedAt time.Time `json:"charged_at"`
+}
+
+// PlatformFees lists the fees charged on the loan so far, recorded with its
+// disbursement and repayments.
+func (l Loan) PlatformFees() []PlatformFee {
+	var fees []PlatformFee
+	if l.DisbursementInfo.OriginationFee != 0 {
+		fees = append(fees, PlatformFee{Kind: FeeOrigination, Amount: l.DisbursementInfo.OriginationFee, ChargedAt: l.DisbursementInfo.DisbursementDate})
+	}
+	for _, repayment := range l.Repayments {
+		if repayment.ServicingFee != 0 {
+			fees = append(fees, PlatformFee{Kind: FeeServicing, Amount: repayment.ServicingFee, ChargedAt: repayment.PaidAt})
+		}
+		if repayment.LateFeeShare != 0 {
+			fees = append(fees, PlatformFee{Kind: FeeLate, Amount: repayment.LateFeeShare, ChargedAt: repayment.PaidAt})
+		}
+	}
+	return fees
+}
+
+// ProductName is the product of the loan, DefaultProduct for loans proposed
+// before products existed.
+func (l Loan) ProductName() string {
+	if l.Product == "" {
+		return DefaultProduct
+	}
+	return l.Product
+}
+
+// RevenuePeriod is the length of the periods revenue is grouped by.
+type RevenuePeriod string
+
+const (
+	RevenueByDay   RevenuePeriod = "day"
+	RevenueByMonth RevenuePeriod = "month"
+	RevenueByYear  RevenuePeriod = "year"
+)
+
+var revenueLayouts = map[RevenuePeriod]string{
+	RevenueByDay:   time.DateOnly,
+	RevenueByMonth: "2006-01",
+	RevenueByYear:  "2006",
+}
+
+func ParseRevenuePeriod(raw string) (RevenuePeriod, error) {
+	period := RevenuePeriod(strings.ToLower(raw))
+	if _, ok := revenueLayouts[period]; !ok {
+		return "", fmt.Errorf("%w: unknown period %q, use day, month or year", ErrInvalidQuery, raw)
+	}
+	return period, nil
+}
+
+// Key names the period t falls in, in UTC, e.g. 2026-01 for a month.
+func (p RevenuePeriod) Key(t time.Time) string {
+	return t.UTC().Format(revenueLayouts[p])
+}
+
+// RevenueQuery selects the fees to report. Zero times mean "no bound".
+type RevenueQuery struct {
+	Period  RevenuePeriod
+	Product string
+	From    time.Time // Inclusive
+	To      time.Time // Exclusive
+}
+
+// RevenueLine is the revenue of a product in a period.
+type RevenueLine struct {
+	Period      string  `json:"period"`
+	Product     string  `json:"product"`
+	Origination float64 `json:"origination"`
+	Servicing   float64 `json:"servicing"`
+	LateFee     float64 `json:"late_fee"`
+	Total       float64 `json:"total"`
+	Loans       int     `json:"loans"` // Loans charged a fee in the period
+}
+
+type RevenueReport struct {
+	Period RevenuePeriod `json:"period"`
+	Lines  []RevenueLine `json:"lines"` // By period, then product
+	Total  float64       `json:"total"`
+}
+
+// NewRevenueReport groups the fees of the loans by period and product.
+func NewRevenueReport(loans []Loan, query RevenueQuery) RevenueReport {
+	type group struct{ period, product string }
+	var (
+		lines   = make(map[group]*RevenueLine)
+		charged = make(map[group]map[int64]bool)
+		report  = RevenueReport{Period: query.Period, Lines: make([]RevenueLine, 0)}
+	)
+	for _, loan := range loans {
+		if query.Product != "" && loan.ProductName() != query.Product {
+			continue
+		}
+		for _, fee := range loan.PlatformFees() {
+			if (!query.From.IsZero() && fee.ChargedAt.Before(query.From)) || (!query.To.IsZero() && !fee.ChargedAt.Before(query.To)) {
+				continue
+			}
+
+			key := group{query.Period.Key(fee.ChargedAt), loan.ProductName()}
+			line, ok := lines[key]
+			if !ok {
+				line = &RevenueLine{Period: key.period, Product: key.product}
+				lines[key] = line
+				charged[key] = make(map[int64]bool)
+			}
+			switch fee.Kind {
+			case FeeOrigination:
+				line.Origination = RoundAmount(line.Origination + fee.Amount)
+			case FeeServicing:
+				line.Servicing = RoundAmount(line.Servicing + fee.Amount)
+			case FeeLate:
+				line.LateFee = RoundAmount(line.LateFee + fee.Amount)
+			}
+			line.Total = RoundAmount(line.Total + fee.Amount)
+			charged[key][loan.LoanID] = true
+			report.Total = RoundAmount(report.Total + fee.Amount)
+		}
+	}
+
+	for key, line := range lines {
+		line.Loans = len(charged[key])
+		report.Lines = append(report.Lines, *line)
+	}
+	slices.SortFunc(report.Lines, func(a, b RevenueLine) int {
+		if c := strings.Compare(a.Period, b.Period); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Product, b.Product)
+	})
+	return report
+}
@@ -1,27 +1,149 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrLoanNotFound = errors.New("loan not found")
+	// ErrInvalidState is returned for commands the loan's current state does
+	// not allow, such as investing in a loan that is not approved.
+	ErrInvalidState = errors.New("invalid loan state")
+)
 
 type StateEnum int16
 
 const (
-	StateEnumProposed = iota
+	StateEnumProposed StateEnum = iota
 	StateEnumApproved
 	StateEnumInvested
 	StateEnumDisbursed
+	StateEnumCancelled
 )
 
+var stateNames = map[StateEnum]string{
+	StateEnumProposed:  "proposed",
+	StateEnumApproved:  "approved",
+	StateEnumInvested:  "invested",
+	StateEnumDisbursed: "disbursed",
+	StateEnumCancelled: "cancelled",
+}
+
+func (s StateEnum) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseStateEnum accepts a state name ("approved") or its numeric value ("2").
+func ParseStateEnum(raw string) (StateEnum, error) {
+	for state, name := range stateNames {
+		if strings.EqualFold(raw, name) {
+			return state, nil
+		}
+	}
+	if n, err := strconv.Atoi(raw); err == nil {
+		if _, ok := stateNames[StateEnum(n)]; ok {
+			return StateEnum(n), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown loan state %q", raw)
+}
+
+// MarshalJSON writes the state name, e.g. "approved".
+func (s StateEnum) MarshalJSON() ([]byte, error) {
+	if _, ok := stateNames[s]; !ok {
+		return nil, fmt.Errorf("unknown loan state %d", s)
+	}
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON accepts the state name as well as the numeric value written by
+// older versions, as a JSON number or a string.
+func (s *StateEnum) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		raw = string(data)
+	}
+	state, err := ParseStateEnum(raw)
+	if err != nil {
+		return err
+	}
+	*s = state
+	return nil
+}
+
 type Loan struct {
 	LoanID             int64            `json:"loan_id"`              // Unique identifier
 	BorrowerID         int64            `json:"borrower_id"`          // Identifier of the borrower
 	PrincipalAmount    float64          `json:"principal_amount"`     // Amount of the loan requested
 	Rate               float64          `json:"rate"`                 // Interest rate for the loan
 	ROI                float64          `json:"roi"`                  // Return on investment for investors
-	State              StateEnum        `json:"state"`                // Current state of the loan: proposed, approved, invested, disbursed
+	State              StateEnum        `json:"state"`                // Current state of the loan: proposed, approved, invested, disbursed, cancelled
 	ApprovalInfo       ApprovalInfo     `json:"approval_info"`        // Details when state is approved
 	Investments        []Investment     `json:"investments"`          // List of investments and their invested amounts when state is invested
 	DisbursementInfo   DisbursementInfo `json:"disbursement_info"`    // Details when state is disbursed
 	AgreementLetterURL string           `json:"agreement_letter_url"` // Generated agreement letter
+	CancellationInfo   CancellationInfo `json:"cancellation_info"`    // Details when state is cancelled
+	Repayments         []Repayment      `json:"repayments,omitempty"` // Money paid back once disbursed
+	Product            string           `json:"product,omitempty"`    // Kind of loan, fees and revenue are per product
+	FeeTerms           *FeeTerms        `json:"fee_terms,omitempty"`  // Fee rules at the time the loan was proposed
+	CreatedAt          time.Time        `json:"created_at"`           // When the loan was proposed
+}
+
+// InvestedAmount is the sum of every investment made in the loan.
+func (l Loan) InvestedAmount() float64 {
+	var total float64
+	for _, inv := range l.Investments {
+		total += inv.InvestedAmount
+	}
+	return total
+}
+
+// RemainingAmount is the part of the principal still open for investment.
+func (l Loan) RemainingAmount() float64 {
+	return l.PrincipalAmount - l.InvestedAmount()
+}
+
+// InvestorCount is the number of distinct investors in the loan.
+func (l Loan) InvestorCount() int {
+	investors := make(map[int64]struct{}, len(l.Investments))
+	for _, inv := range l.Investments {
+		investors[inv.InvestorID] = struct{}{}
+	}
+	return len(investors)
+}
+
+// FundingPercentage is the invested share of the principal, from 0 to 100.
+func (l Loan) FundingPercentage() float64 {
+	if l.PrincipalAmount <= 0 {
+		return 0
+	}
+	return l.InvestedAmount() / l.PrincipalAmount * 100
+}
+
+// Information is the public view of the loan shown to every role.
+func (l Loan) Information() LoanInformation {
+	return LoanInformation{
+		LoanID:             l.LoanID,
+		BorrowerID:         l.BorrowerID,
+		PrincipalAmount:    l.PrincipalAmount,
+		Rate:               l.Rate,
+		ROI:                l.ROI,
+		Product:            l.ProductName(),
+		AgreementLetterURL: l.AgreementLetterURL,
+		State:              l.State,
+		FundedAmount:       l.InvestedAmount(),
+		RemainingAmount:    l.RemainingAmount(),
+		InvestorCount:      l.InvestorCount(),
+		FundingPercentage:  l.FundingPercentage(),
+	}
 }
 
 type ApprovalInfo struct {
@@ -39,13 +161,26 @@ type DisbursementInfo struct {
 	SignedAgreementLetterURL string    `json:"signed_agreement_letter_url"`
 	FieldOfficerID           int64     `json:"field_officer_id"`
 	DisbursementDate         time.Time `json:"disbursement_date"`
+	OriginationFee           float64   `json:"origination_fee,omitempty"` // Withheld from the amount paid to the borrower
+}
+
+type CancellationInfo struct {
+	Reason           string    `json:"reason"`
+	CancelledBy      int64     `json:"cancelled_by"`
+	CancellationDate time.Time `json:"cancellation_date"`
 }
 
 type LoanInformation struct {
-	LoanID             int64   `json:"loan_id"`
-	BorrowerID         int64   `json:"borrower_id"`
-	PrincipalAmount    float64 `json:"principal_amount"`
-	Rate               float64 `json:"rate"`
-	ROI                float64 `json:"roi"`
-	AgreementLetterURL string  `json:"agreement_letter_url"`
+	LoanID             int64     `json:"loan_id"`
+	BorrowerID         int64     `json:"borrower_id"`
+	PrincipalAmount    float64   `json:"principal_amount"`
+	Rate               float64   `json:"rate"`
+	ROI                float64   `json:"roi"`
+	Product            string    `json:"product"`
+	AgreementLetterURL string    `json:"agreement_letter_url"`
+	State              StateEnum `json:"state"`              // Serialized as the state name
+	FundedAmount       float64   `json:"funded_amount"`      // Sum of all investments so far
+	RemainingAmount    float64   `json:"remaining_amount"`   // Principal still open for investment
+	InvestorCount      int       `json:"investor_count"`     // Distinct investors
+	FundingPercentage  float64   `json:"funding_percentage"` // Funded share of the principal, 0 to 100
 }
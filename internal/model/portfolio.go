@@ -0,0 +1,106 @@
+package model
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Position is what an investor put in a loan and got back from it.
+type Position struct {
+	LoanID               int64     `json:"loan_id"`
+	State                StateEnum `json:"state"` // Serialized as the state name
+	Product              string    `json:"product"`
+	ROI                  float64   `json:"roi"`
+	ReturnPercent        float64   `json:"return_percent"`        // ROI plus the part of the spread the platform does not keep
+	Invested             float64   `json:"invested"`              // Sum of the investor's investments in the loan
+	ExpectedReturn       float64   `json:"expected_return"`       // Return on Invested at ReturnPercent, zero once cancelled
+	ReceivedPrincipal    float64   `json:"received_principal"`    // Principal paid out so far
+	ReceivedReturn       float64   `json:"received_return"`       // Return and late fees paid out so far
+	OutstandingPrincipal float64   `json:"outstanding_principal"` // Principal not paid back yet, zero once cancelled
+}
+
+// Portfolio lists the positions of an investor, newest loan first, with their
+// totals.
+type Portfolio struct {
+	InvestorID           int64      `json:"investor_id"`
+	Positions            []Position `json:"positions"`
+	Invested             float64    `json:"invested"` // Cancelled loans aside
+	ExpectedReturn       float64    `json:"expected_return"`
+	ReceivedPrincipal    float64    `json:"received_principal"`
+	ReceivedReturn       float64    `json:"received_return"`
+	OutstandingPrincipal float64    `json:"outstanding_principal"`
+	Yield                float64    `json:"yield"` // Expected return over the amount invested, in percent
+}
+
+// Position returns the investor's position in the loan, whose repayments pay
+// the return of terms. ok is false when the investor has no investment in it.
+func (l Loan) Position(investorID int64, terms FeeTerms) (position Position, ok bool) {
+	for _, inv := range l.Investments {
+		if inv.InvestorID != investorID {
+			continue
+		}
+		position.Invested += inv.InvestedAmount
+		ok = true
+	}
+	if !ok {
+		return Position{}, false
+	}
+
+	position.LoanID = l.LoanID
+	position.State = l.State
+	position.Product = l.ProductName()
+	position.ROI = l.ROI
+	position.ReturnPercent = terms.ReturnPercent(l.Rate, l.ROI)
+	position.Invested = RoundAmount(position.Invested)
+	for _, repayment := range l.Repayments {
+		for _, payout := range repayment.Payouts {
+			if payout.InvestorID == investorID {
+				position.ReceivedPrincipal += payout.Principal
+				position.ReceivedReturn += payout.Return + payout.LateFee
+			}
+		}
+	}
+	position.ReceivedPrincipal = RoundAmount(position.ReceivedPrincipal)
+	position.ReceivedReturn = RoundAmount(position.ReceivedReturn)
+
+	// A cancelled loan gave the money back and will not pay anything
+	if l.State != StateEnumCancelled {
+		position.ExpectedReturn = RoundAmount(position.Invested * position.ReturnPercent / 100)
+		position.OutstandingPrincipal = RoundAmount(position.Invested - position.ReceivedPrincipal)
+	}
+	return position, true
+}
+
+// NewPortfolio builds the portfolio of the investor from the loans they
+// invested in, each under the fee terms returned by terms. Loans without an
+// investment of theirs are left out.
+func NewPortfolio(investorID int64, loans []Loan, terms func(Loan) FeeTerms) Portfolio {
+	portfolio := Portfolio{InvestorID: investorID, Positions: make([]Position, 0, len(loans))}
+	for _, loan := range loans {
+		position, ok := loan.Position(investorID, terms(loan))
+		if !ok {
+			continue
+		}
+		portfolio.Positions = append(portfolio.Positions, position)
+
+		if position.State != StateEnumCancelled {
+			portfolio.Invested += position.Invested
+		}
+		portfolio.ExpectedReturn += position.ExpectedReturn
+		portfolio.ReceivedPrincipal += position.ReceivedPrincipal
+		portfolio.ReceivedReturn += position.ReceivedReturn
+		portfolio.OutstandingPrincipal += position.OutstandingPrincipal
+	}
+	// Loan IDs grow with time
+	slices.SortFunc(portfolio.Positions, func(a, b Position) int { return cmp.Compare(b.LoanID, a.LoanID) })
+
+	portfolio.Invested = RoundAmount(portfolio.Invested)
+	portfolio.ExpectedReturn = RoundAmount(portfolio.ExpectedReturn)
+	portfolio.ReceivedPrincipal = RoundAmount(portfolio.ReceivedPrincipal)
+	portfolio.ReceivedReturn = RoundAmount(portfolio.ReceivedReturn)
+	portfolio.OutstandingPrincipal = RoundAmount(portfolio.OutstandingPrincipal)
+	if portfolio.Invested > 0 {
+		portfolio.Yield = RoundAmount(portfolio.ExpectedReturn / portfolio.Invested * 100)
+	}
+	return portfolio
+}
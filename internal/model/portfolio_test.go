@@ -0,0 +1,51 @@
+package model
+
+import "testing"
+
+func TestLoanPosition(t *testing.T) {
+	loan := testLoan(Investment{InvestorID: 7, InvestedAmount: 400}, Investment{InvestorID: 8, InvestedAmount: 600})
+	loan.Repayments = []Repayment{{Amount: 550, Payouts: []Payout{{InvestorID: 7, Principal: 200, Return: 18, LateFee: 1}}}}
+
+	tests := []struct {
+		name  string
+		state StateEnum
+		terms FeeTerms
+		want  Position
+	}{
+		{
+			name:  "platform keeps the spread",
+			state: StateEnumDisbursed,
+			terms: FeeTerms{ServicingSpreadPercent: 100},
+			want: Position{LoanID: 1, State: StateEnumDisbursed, Product: DefaultProduct, ROI: 8, ReturnPercent: 8, Invested: 400,
+				ExpectedReturn: 32, ReceivedPrincipal: 200, ReceivedReturn: 19, OutstandingPrincipal: 200},
+		},
+		{
+			name:  "investors get half the spread",
+			state: StateEnumDisbursed,
+			terms: FeeTerms{ServicingSpreadPercent: 50},
+			want: Position{LoanID: 1, State: StateEnumDisbursed, Product: DefaultProduct, ROI: 8, ReturnPercent: 9, Invested: 400,
+				ExpectedReturn: 36, ReceivedPrincipal: 200, ReceivedReturn: 19, OutstandingPrincipal: 200},
+		},
+		{
+			name:  "cancelled",
+			state: StateEnumCancelled,
+			terms: FeeTerms{ServicingSpreadPercent: 50},
+			want: Position{LoanID: 1, State: StateEnumCancelled, Product: DefaultProduct, ROI: 8, ReturnPercent: 9, Invested: 400,
+				ReceivedPrincipal: 200, ReceivedReturn: 19},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := loan
+			loan.State = tt.state
+			got, ok := loan.Position(7, tt.terms)
+			if !ok || got != tt.want {
+				t.Errorf("Position() = %+v, %v, want %+v, true", got, ok, tt.want)
+			}
+		})
+	}
+
+	if _, ok := loan.Position(9, FeeTerms{}); ok {
+		t.Errorf("Position() of an investor without investments is ok")
+	}
+}
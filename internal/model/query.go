@@ -0,0 +1,68 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidQuery is returned for malformed filters, sort options or cursors.
+var ErrInvalidQuery = errors.New("invalid query")
+
+type LoanSortField string
+
+const (
+	LoanSortCreatedAt       LoanSortField = "created_at"
+	LoanSortPrincipalAmount LoanSortField = "principal_amount"
+	LoanSortRate            LoanSortField = "rate"
+	LoanSortROI             LoanSortField = "roi"
+	LoanSortRemainingAmount LoanSortField = "remaining_amount"
+)
+
+// LoanSortFields lists every supported sort field.
+var LoanSortFields = []LoanSortField{
+	LoanSortCreatedAt,
+	LoanSortPrincipalAmount,
+	LoanSortRate,
+	LoanSortROI,
+	LoanSortRemainingAmount,
+}
+
+// Range is an inclusive bound on a numeric field. Nil ends are open.
+type Range struct {
+	Min *float64
+	Max *float64
+}
+
+func (r Range) Contains(v float64) bool {
+	return (r.Min == nil || v >= *r.Min) && (r.Max == nil || v <= *r.Max)
+}
+
+// LoanQuery filters, sorts and paginates loans. Zero values mean "no filter".
+type LoanQuery struct {
+	States          []StateEnum
+	BorrowerID      int64
+	PrincipalAmount Range
+	Rate            Range
+	ROI             Range
+	RemainingAmount Range
+	CreatedFrom     time.Time // Inclusive
+	CreatedTo       time.Time // Exclusive
+
+	SortBy     LoanSortField
+	Descending bool
+
+	Cursor string // Opaque cursor returned as NextCursor by the previous page
+	Limit  int
+}
+
+type LoanPage struct {
+	Loans      []Loan
+	NextCursor string
+	TotalCount int
+}
+
+type LoanInformationPage struct {
+	Loans      []LoanInformation `json:"loans"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	TotalCount int               `json:"total_count"`
+}
@@ -0,0 +1,119 @@
+package model
+
+import "time"
+
+// Repayment is money paid back by the borrower of a disbursed loan, shared
+// among the investors in proportion to their investments.
+type Repayment struct {
+	Amount       float64   `json:"amount"`
+	LateFee      float64   `json:"late_fee,omitempty"` // Paid on top of the amount, for paying late
+	PaidBy       int64     `json:"paid_by"`            // Borrower, or the field officer who collected it
+	PaidAt       time.Time `json:"paid_at"`
+	Payouts      []Payout  `json:"payouts"`
+	ServicingFee float64   `json:"servicing_fee"`            // What the platform keeps of the amount
+	LateFeeShare float64   `json:"late_fee_share,omitempty"` // What the platform keeps of the late fee
+}
+
+// Payout is the part of a repayment credited to an investor.
+type Payout struct {
+	InvestorID int64   `json:"investor_id"`
+	Principal  float64 `json:"principal"`
+	Return     float64 `json:"return"`             // At the loan's ROI, plus the spread the platform does not keep
+	LateFee    float64 `json:"late_fee,omitempty"` // The investor's part of the late fee
+}
+
+// AmountDue is what the borrower owes in total, the principal and the interest
+// at Rate.
+func (l Loan) AmountDue() float64 {
+	return RoundAmount(l.PrincipalAmount * (1 + l.Rate/100))
+}
+
+// RepaidAmount is the sum of every repayment made so far.
+func (l Loan) RepaidAmount() float64 {
+	var total float64
+	for _, repayment := range l.Repayments {
+		total += repayment.Amount
+	}
+	return RoundAmount(total)
+}
+
+// OutstandingAmount is what the borrower still owes.
+func (l Loan) OutstandingAmount() float64 {
+	return RoundAmount(l.AmountDue() - l.RepaidAmount())
+}
+
+// PaidOut returns the principal and return credited to the investor so far,
+// late fees aside.
+func (l Loan) PaidOut(investorID int64) (principal, earned float64) {
+	for _, repayment := range l.Repayments {
+		for _, payout := range repayment.Payouts {
+			if payout.InvestorID == investorID {
+				principal += payout.Principal
+				earned += payout.Return
+			}
+		}
+	}
+	return RoundAmount(principal), RoundAmount(earned)
+}
+
+// Payouts splits a repayment of amount among the investors, in proportion to
+// their investments. Each investor is owed their principal and the return at
+// returnPercent, a repayment pays the same share of both. The repayment
+// settling the loan pays whatever is left, so rounding never leaves cents
+// behind.
+func (l Loan) Payouts(amount, returnPercent float64) []Payout {
+	order, invested := l.investedBy()
+
+	settles := RoundAmount(amount) >= l.OutstandingAmount()
+	share := amount / l.AmountDue()
+	payouts := make([]Payout, 0, len(order))
+	for _, investorID := range order {
+		owedPrincipal := RoundAmount(invested[investorID])
+		owedReturn := RoundAmount(invested[investorID] * returnPercent / 100)
+		paidPrincipal, paidReturn := l.PaidOut(investorID)
+
+		payout := Payout{InvestorID: investorID}
+		if settles {
+			payout.Principal = RoundAmount(owedPrincipal - paidPrincipal)
+			payout.Return = RoundAmount(owedReturn - paidReturn)
+		} else {
+			payout.Principal = min(RoundAmount(owedPrincipal*share), RoundAmount(owedPrincipal-paidPrincipal))
+			payout.Return = min(RoundAmount(owedReturn*share), RoundAmount(owedReturn-paidReturn))
+		}
+		payouts = append(payouts, payout)
+	}
+	return payouts
+}
+
+// ShareLateFee adds amount to the payouts, in proportion to the investments of
+// their investors. The last payout gets what rounding leaves.
+func (l Loan) ShareLateFee(payouts []Payout, amount float64) {
+	_, invested := l.investedBy()
+	total := l.InvestedAmount()
+	if len(payouts) == 0 || total <= 0 {
+		return
+	}
+
+	left := RoundAmount(amount)
+	for i := range payouts {
+		part := RoundAmount(amount * invested[payouts[i].InvestorID] / total)
+		if i == len(payouts)-1 {
+			part = left
+		}
+		payouts[i].LateFee = part
+		left = RoundAmount(left - part)
+	}
+}
+
+// investedBy sums the investments per investor, in the order they first
+// invested.
+func (l Loan) investedBy() (order []int64, invested map[int64]float64) {
+	invested = make(map[int64]float64)
+	for _, inv := range l.Investments {
+		if _, ok := invested[inv.InvestorID]; !ok {
+			order = append(order, inv.InvestorID)
+		}
+		invested[inv.InvestorID] += inv.InvestedAmount
+	}
+	return order, invested
+}
@@ -0,0 +1,187 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testLoan(investments ...Investment) Loan {
+	return Loan{LoanID: 1, PrincipalAmount: 1000, Rate: 10, ROI: 8, State: StateEnumDisbursed, Investments: investments}
+}
+
+func TestLoanPayouts(t *testing.T) {
+	loan := testLoan(
+		Investment{InvestorID: 7, InvestedAmount: 300},
+		Investment{InvestorID: 8, InvestedAmount: 600},
+		Investment{InvestorID: 7, InvestedAmount: 100},
+	)
+
+	tests := []struct {
+		name          string
+		repaid        []float64 // Repayments made before
+		amount        float64
+		returnPercent float64
+		want          []Payout
+	}{
+		{
+			name:          "half",
+			amount:        550,
+			returnPercent: 8,
+			want:          []Payout{{InvestorID: 7, Principal: 200, Return: 16}, {InvestorID: 8, Principal: 300, Return: 24}},
+		},
+		{
+			name:          "whole loan",
+			amount:        1100,
+			returnPercent: 8,
+			want:          []Payout{{InvestorID: 7, Principal: 400, Return: 32}, {InvestorID: 8, Principal: 600, Return: 48}},
+		},
+		{
+			name:          "settling the rest",
+			repaid:        []float64{550},
+			amount:        550,
+			returnPercent: 8,
+			want:          []Payout{{InvestorID: 7, Principal: 200, Return: 16}, {InvestorID: 8, Principal: 300, Return: 24}},
+		},
+		{
+			name:          "return above the rate",
+			amount:        1100,
+			returnPercent: 12,
+			want:          []Payout{{InvestorID: 7, Principal: 400, Return: 48}, {InvestorID: 8, Principal: 600, Return: 72}},
+		},
+		{
+			name:   "no return",
+			amount: 110,
+			want:   []Payout{{InvestorID: 7, Principal: 40}, {InvestorID: 8, Principal: 60}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := loan
+			for _, amount := range tt.repaid {
+				loan.Repayments = append(loan.Repayments, Repayment{Amount: amount, Payouts: loan.Payouts(amount, tt.returnPercent)})
+			}
+			if got := loan.Payouts(tt.amount, tt.returnPercent); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Payouts() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Repeated partial repayments round every payout, the one settling the loan
+// pays exactly what is left.
+func TestLoanPayoutsRounding(t *testing.T) {
+	tests := []struct {
+		name        string
+		investments []float64
+		repayments  []float64
+	}{
+		{
+			name:        "thirds",
+			investments: []float64{333.33, 333.33, 333.34},
+			repayments:  []float64{366.67, 366.67, 366.66},
+		},
+		{
+			name:        "many small repayments",
+			investments: []float64{100, 250.5, 649.5},
+			repayments:  []float64{0.01, 99.99, 33.33, 33.33, 33.33, 33.33, 33.33, 33.33, 200, 0.07, 600.95},
+		},
+		{
+			name:        "overpaid",
+			investments: []float64{1, 999},
+			repayments:  []float64{123.45, 1000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := testLoan()
+			for i, amount := range tt.investments {
+				loan.Investments = append(loan.Investments, Investment{InvestorID: int64(i + 1), InvestedAmount: amount})
+			}
+
+			for _, amount := range tt.repayments {
+				payouts := loan.Payouts(amount, loan.ROI)
+				var paidOut float64
+				for _, payout := range payouts {
+					if payout.Principal < 0 || payout.Return < 0 {
+						t.Fatalf("Payouts(%v) = %+v, want no negative payout", amount, payouts)
+					}
+					paidOut += payout.Principal + payout.Return
+				}
+				if RoundAmount(paidOut) > amount {
+					t.Fatalf("Payouts(%v) pay out %v, more than repaid", amount, RoundAmount(paidOut))
+				}
+				loan.Repayments = append(loan.Repayments, Repayment{Amount: amount, Payouts: payouts})
+			}
+
+			for i, amount := range tt.investments {
+				principal, earned := loan.PaidOut(int64(i + 1))
+				if principal != amount || earned != RoundAmount(amount*loan.ROI/100) {
+					t.Errorf("investor %d got %v principal and %v return, want %v and %v",
+						i+1, principal, earned, amount, RoundAmount(amount*loan.ROI/100))
+				}
+			}
+		})
+	}
+}
+
+func TestLoanShareLateFee(t *testing.T) {
+	tests := []struct {
+		name        string
+		investments []Investment
+		amount      float64
+		want        []float64
+	}{
+		{
+			name:        "proportional",
+			investments: []Investment{{InvestorID: 7, InvestedAmount: 400}, {InvestorID: 8, InvestedAmount: 600}},
+			amount:      10,
+			want:        []float64{4, 6},
+		},
+		{
+			name:        "last gets the rounding",
+			investments: []Investment{{InvestorID: 7, InvestedAmount: 100}, {InvestorID: 8, InvestedAmount: 100}, {InvestorID: 9, InvestedAmount: 100}},
+			amount:      10,
+			want:        []float64{3.33, 3.33, 3.34},
+		},
+		{
+			name:        "rounded up parts",
+			investments: []Investment{{InvestorID: 7, InvestedAmount: 100}, {InvestorID: 8, InvestedAmount: 100}, {InvestorID: 9, InvestedAmount: 100}},
+			amount:      0.05,
+			want:        []float64{0.02, 0.02, 0.01},
+		},
+		{
+			name:        "investments of an investor summed",
+			investments: []Investment{{InvestorID: 7, InvestedAmount: 100}, {InvestorID: 8, InvestedAmount: 500}, {InvestorID: 7, InvestedAmount: 400}},
+			amount:      1,
+			want:        []float64{0.5, 0.5},
+		},
+		{
+			name:        "no late fee",
+			investments: []Investment{{InvestorID: 7, InvestedAmount: 400}, {InvestorID: 8, InvestedAmount: 600}},
+			want:        []float64{0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := testLoan(tt.investments...)
+			payouts := loan.Payouts(110, loan.ROI)
+			loan.ShareLateFee(payouts, tt.amount)
+
+			got := make([]float64, len(payouts))
+			var total float64
+			for i, payout := range payouts {
+				got[i] = payout.LateFee
+				total += payout.LateFee
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ShareLateFee() late fees = %v, want %v", got, tt.want)
+			}
+			if RoundAmount(total) != tt.amount {
+				t.Errorf("ShareLateFee() shares %v, want %v", RoundAmount(total), tt.amount)
+			}
+		})
+	}
+
+	// Without payouts there is nobody to share with
+	testLoan().ShareLateFee(nil, 10)
+}
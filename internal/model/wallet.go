@@ -0,0 +1,190 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrInsufficientFunds is returned when a wallet entry would take an account
+// of the wallet below zero.
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// WalletAccount is where the money of an investor sits. Every wallet entry
+// moves an amount from one account to another, so the accounts of a wallet
+// always add up to what came in from outside.
+type WalletAccount string
+
+const (
+	WalletAccountExternal  WalletAccount = "external"  // Outside the platform: bank transfers, borrower interest
+	WalletAccountAvailable WalletAccount = "available" // Free to invest
+	WalletAccountReserved  WalletAccount = "reserved"  // Held for loans that are not disbursed yet
+	WalletAccountInvested  WalletAccount = "invested"  // Lent to borrowers, not repaid yet
+)
+
+type WalletEntryType string
+
+const (
+	WalletEntryDeposit WalletEntryType = "deposit" // Money sent in by the investor
+	WalletEntryReserve WalletEntryType = "reserve" // Investment in a loan
+	WalletEntryRelease WalletEntryType = "release" // The loan was cancelled before disbursement
+	WalletEntryCapture WalletEntryType = "capture" // The loan was disbursed to the borrower
+	WalletEntryPayout  WalletEntryType = "payout"  // Principal repaid by the borrower
+	WalletEntryReturn  WalletEntryType = "return"  // Return earned on a repayment, at the loan's ROI
+)
+
+// walletEntryAccounts are the accounts each entry type moves money from and to.
+var walletEntryAccounts = map[WalletEntryType][2]WalletAccount{
+	WalletEntryDeposit: {WalletAccountExternal, WalletAccountAvailable},
+	WalletEntryReserve: {WalletAccountAvailable, WalletAccountReserved},
+	WalletEntryRelease: {WalletAccountReserved, WalletAccountAvailable},
+	WalletEntryCapture: {WalletAccountReserved, WalletAccountInvested},
+	WalletEntryPayout:  {WalletAccountInvested, WalletAccountAvailable},
+	WalletEntryReturn:  {WalletAccountExternal, WalletAccountAvailable},
+}
+
+// Accounts returns the accounts the entry type moves money from and to, ok is
+// false for an unknown type.
+func (t WalletEntryType) Accounts() (from, to WalletAccount, ok bool) {
+	accounts, ok := walletEntryAccounts[t]
+	return accounts[0], accounts[1], ok
+}
+
+// WalletEntry is a movement of money in the wallet of an investor. Entries are
+// never updated or removed, the wallet balances are derived from them. A
+// mistake is corrected by posting the reversal of the entry.
+type WalletEntry struct {
+	EntryID    int64           `json:"entry_id"` // Position in the wallet ledger, starting at 1
+	InvestorID int64           `json:"investor_id"`
+	LoanID     int64           `json:"loan_id,omitempty"` // 0 for deposits
+	Type       WalletEntryType `json:"type"`
+	From       WalletAccount   `json:"from"`
+	To         WalletAccount   `json:"to"`
+	Amount     float64         `json:"amount"`             // Always positive
+	Reverses   int64           `json:"reverses,omitempty"` // ID of the entry this one takes back, its accounts swapped
+	ActorID    int64           `json:"actor_id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	RequestID  string          `json:"request_id,omitempty"`
+}
+
+// NewWalletEntry returns an entry of type moving amount, with its accounts
+// filled in.
+func NewWalletEntry(investorID, loanID int64, entryType WalletEntryType, amount float64) WalletEntry {
+	from, to, _ := entryType.Accounts()
+	return WalletEntry{
+		InvestorID: investorID,
+		LoanID:     loanID,
+		Type:       entryType,
+		From:       from,
+		To:         to,
+		Amount:     RoundAmount(amount),
+	}
+}
+
+// Reversal returns the entry taking back e, which must have been posted: the
+// same amount moves from the account e moved it to back to the one it came
+// from.
+func (e WalletEntry) Reversal() WalletEntry {
+	return WalletEntry{
+		InvestorID: e.InvestorID,
+		LoanID:     e.LoanID,
+		Type:       e.Type,
+		From:       e.To,
+		To:         e.From,
+		Amount:     e.Amount,
+		Reverses:   e.EntryID,
+	}
+}
+
+// Wallet is the balance of an investor, per account.
+type Wallet struct {
+	InvestorID int64     `json:"investor_id"`
+	Available  float64   `json:"available"`
+	Reserved   float64   `json:"reserved"`
+	Invested   float64   `json:"invested"`
+	Deposited  float64   `json:"deposited"` // Sum of deposits
+	Earned     float64   `json:"earned"`    // Sum of returns
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Apply moves the amount of the entry between the accounts of the wallet. The
+// wallet is left unchanged when the entry is invalid or funds are insufficient.
+func (w *Wallet) Apply(entry WalletEntry) error {
+	from, to, ok := entry.Type.Accounts()
+	if !ok {
+		return fmt.Errorf("unknown wallet entry type %q", entry.Type)
+	}
+	if entry.Reverses != 0 {
+		from, to = to, from
+	}
+	if entry.From != from || entry.To != to {
+		return fmt.Errorf("%s entries move money from %s to %s, not from %s to %s", entry.Type, from, to, entry.From, entry.To)
+	}
+	if entry.Amount <= 0 {
+		return fmt.Errorf("wallet entry amount must be positive, got %v", entry.Amount)
+	}
+
+	if balance := w.balance(from); balance != nil {
+		if *balance < entry.Amount {
+			return fmt.Errorf("%w: %s balance is %v, %v needed", ErrInsufficientFunds, from, *balance, entry.Amount)
+		}
+		*balance = RoundAmount(*balance - entry.Amount)
+	}
+	if balance := w.balance(to); balance != nil {
+		*balance = RoundAmount(*balance + entry.Amount)
+	}
+
+	// Money from outside is what the accounts add up to
+	amount := entry.Amount
+	if entry.Reverses != 0 {
+		amount = -amount
+	}
+	switch entry.Type {
+	case WalletEntryDeposit:
+		w.Deposited = RoundAmount(w.Deposited + amount)
+	case WalletEntryReturn:
+		w.Earned = RoundAmount(w.Earned + amount)
+	}
+	w.InvestorID = entry.InvestorID
+	w.UpdatedAt = entry.OccurredAt
+	return nil
+}
+
+// Balanced reports whether the accounts of the wallet add up to the money that
+// came in from outside.
+func (w Wallet) Balanced() bool {
+	return RoundAmount(w.Available+w.Reserved+w.Invested) == RoundAmount(w.Deposited+w.Earned)
+}
+
+func (w *Wallet) balance(account WalletAccount) *float64 {
+	switch account {
+	case WalletAccountAvailable:
+		return &w.Available
+	case WalletAccountReserved:
+		return &w.Reserved
+	case WalletAccountInvested:
+		return &w.Invested
+	default:
+		return nil
+	}
+}
+
+// RoundAmount rounds an amount of money to cents, so that repeated splits do
+// not accumulate floating point noise.
+func RoundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// WalletEntryQuery filters the wallet ledger. Zero values mean "no filter".
+type WalletEntryQuery struct {
+	InvestorID int64
+	LoanID     int64
+	AfterID    int64 // Only entries after this one, for pagination
+	Limit      int
+}
+
+type WalletEntryPage struct {
+	Entries   []WalletEntry `json:"entries"`
+	NextAfter int64         `json:"next_after,omitempty"` // Pass as after to get the next page
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWalletApply(t *testing.T) {
+	funded := Wallet{InvestorID: 7, Available: 100, Reserved: 50, Invested: 30, Deposited: 170, Earned: 10}
+	deposit := NewWalletEntry(7, 0, WalletEntryDeposit, 100)
+	deposit.EntryID = 1
+	reserve := NewWalletEntry(7, 1, WalletEntryReserve, 40)
+	reserve.EntryID = 2
+	large := NewWalletEntry(7, 0, WalletEntryDeposit, 100.01)
+	large.EntryID = 3
+
+	tests := []struct {
+		name         string
+		entry        WalletEntry
+		want         Wallet
+		wantErr      bool
+		insufficient bool // The error wraps ErrInsufficientFunds
+	}{
+		{
+			name:  "deposit",
+			entry: NewWalletEntry(7, 0, WalletEntryDeposit, 25.5),
+			want:  Wallet{InvestorID: 7, Available: 125.5, Reserved: 50, Invested: 30, Deposited: 195.5, Earned: 10},
+		},
+		{
+			name:  "reserve",
+			entry: NewWalletEntry(7, 1, WalletEntryReserve, 100),
+			want:  Wallet{InvestorID: 7, Available: 0, Reserved: 150, Invested: 30, Deposited: 170, Earned: 10},
+		},
+		{
+			name:  "return",
+			entry: NewWalletEntry(7, 1, WalletEntryReturn, 2.4),
+			want:  Wallet{InvestorID: 7, Available: 102.4, Reserved: 50, Invested: 30, Deposited: 170, Earned: 12.4},
+		},
+		{
+			name:         "reserve overdraws available",
+			entry:        NewWalletEntry(7, 1, WalletEntryReserve, 100.01),
+			wantErr:      true,
+			insufficient: true,
+		},
+		{
+			name:         "capture overdraws reserved",
+			entry:        NewWalletEntry(7, 1, WalletEntryCapture, 60),
+			wantErr:      true,
+			insufficient: true,
+		},
+		{
+			name:         "payout overdraws invested",
+			entry:        NewWalletEntry(7, 1, WalletEntryPayout, 30.01),
+			wantErr:      true,
+			insufficient: true,
+		},
+		{
+			name:  "deposit reversed",
+			entry: deposit.Reversal(),
+			want:  Wallet{InvestorID: 7, Available: 0, Reserved: 50, Invested: 30, Deposited: 70, Earned: 10},
+		},
+		{
+			name:  "reserve reversed",
+			entry: reserve.Reversal(),
+			want:  Wallet{InvestorID: 7, Available: 140, Reserved: 10, Invested: 30, Deposited: 170, Earned: 10},
+		},
+		{
+			name:         "reversal overdraws",
+			entry:        large.Reversal(),
+			wantErr:      true,
+			insufficient: true,
+		},
+		{
+			name:    "reversal with the original accounts",
+			entry:   WalletEntry{InvestorID: 7, Type: WalletEntryDeposit, From: WalletAccountExternal, To: WalletAccountAvailable, Amount: 10, Reverses: 1},
+			wantErr: true,
+		},
+		{
+			name:    "wrong accounts",
+			entry:   WalletEntry{InvestorID: 7, Type: WalletEntryReserve, From: WalletAccountAvailable, To: WalletAccountInvested, Amount: 10},
+			wantErr: true,
+		},
+		{
+			name:    "zero amount",
+			entry:   NewWalletEntry(7, 0, WalletEntryDeposit, 0),
+			wantErr: true,
+		},
+		{
+			name:    "unknown type",
+			entry:   WalletEntry{InvestorID: 7, Type: "refund", From: WalletAccountExternal, To: WalletAccountAvailable, Amount: 10},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wallet := funded
+			err := wallet.Apply(tt.entry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Apply() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := errors.Is(err, ErrInsufficientFunds); got != tt.insufficient {
+				t.Errorf("Apply() error = %v, wraps ErrInsufficientFunds %v, want %v", err, got, tt.insufficient)
+			}
+
+			// A rejected entry leaves the wallet as it was
+			want := tt.want
+			if tt.wantErr {
+				want = funded
+			}
+			if wallet != want {
+				t.Errorf("Apply() wallet = %+v, want %+v", wallet, want)
+			}
+			if !wallet.Balanced() {
+				t.Errorf("Apply() left the wallet unbalanced: %+v", wallet)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/timotiusas11/amartha-assignment/common/driver/inmemlib"
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+const (
+	CacheKeyBorrowerIDs    = "borrowers:ids"
+	CacheKeyPrefixBorrower = "borrower:"
+)
+
+// borrowerStore keeps each borrower under its own key, so the registry is not
+// bound by the size of a single cache entry, plus the list of borrower IDs.
+type borrowerStore struct {
+	mu        sync.Mutex // Guards the ID list and the existence checks
+	ids       *inmemlib.Cache[[]int64]
+	borrowers *inmemlib.Cache[model.Borrower]
+}
+
+func newBorrowerStore(store inmemlib.InMemLibInterface) *borrowerStore {
+	// Losing the list would hide every borrower from listings
+	store.Watch(CacheKeyBorrowerIDs)
+
+	return &borrowerStore{
+		ids:       inmemlib.NewCache[[]int64](store),
+		borrowers: inmemlib.NewCache[model.Borrower](store, inmemlib.WithPrefix(CacheKeyPrefixBorrower)),
+	}
+}
+
+func (r Repository) InsertBorrower(ctx context.Context, borrower model.Borrower) error {
+	ctx, span := tracing.Start(ctx, "repository.InsertBorrower", tracing.Int64("borrower_id", borrower.BorrowerID))
+	defer span.End()
+
+	r.borrowerStore.mu.Lock()
+	defer r.borrowerStore.mu.Unlock()
+
+	key := strconv.FormatInt(borrower.BorrowerID, 10)
+	_, exists, err := r.borrowerStore.borrowers.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to get borrower from memcache: %w", err)
+	}
+	if exists {
+		return model.ErrBorrowerExists
+	}
+
+	err = r.borrowerStore.borrowers.Set(ctx, key, borrower)
+	if err != nil {
+		return fmt.Errorf("failed to set borrower in memcache: %w", err)
+	}
+
+	ids, _, err := r.borrowerStore.ids.Get(ctx, CacheKeyBorrowerIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get borrower IDs from memcache: %w", err)
+	}
+	err = r.borrowerStore.ids.Set(ctx, CacheKeyBorrowerIDs, append(ids, borrower.BorrowerID))
+	if err != nil {
+		return fmt.Errorf("failed to set borrower IDs in memcache: %w", err)
+	}
+	slog.DebugContext(ctx, "borrower inserted", "borrower_id", borrower.BorrowerID)
+
+	return nil
+}
+
+func (r Repository) GetBorrower(ctx context.Context, borrowerID int64) (model.Borrower, error) {
+	ctx, span := tracing.Start(ctx, "repository.GetBorrower", tracing.Int64("borrower_id", borrowerID))
+	defer span.End()
+
+	borrower, _, err := r.borrowerStore.borrowers.Get(ctx, strconv.FormatInt(borrowerID, 10))
+	if err != nil {
+		return model.Borrower{}, fmt.Errorf("failed to get borrower from memcache: %w", err)
+	}
+
+	return borrower, nil
+}
+
+func (r Repository) GetBorrowers(ctx context.Context) ([]model.Borrower, error) {
+	ctx, span := tracing.Start(ctx, "repository.GetBorrowers")
+	defer span.End()
+
+	ids, _, err := r.borrowerStore.ids.Get(ctx, CacheKeyBorrowerIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get borrower IDs from memcache: %w", err)
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = strconv.FormatInt(id, 10)
+	}
+	found, err := r.borrowerStore.borrowers.GetMany(ctx, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get borrowers from memcache: %w", err)
+	}
+
+	borrowers := make([]model.Borrower, 0, len(found))
+	for _, key := range keys {
+		if borrower, ok := found[key]; ok {
+			borrowers = append(borrowers, borrower)
+		}
+	}
+	return borrowers, nil
+}
+
+func (r Repository) UpdateBorrower(ctx context.Context, borrower model.Borrower) error {
+	ctx, span := tracing.Start(ctx, "repository.UpdateBorrower", tracing.Int64("borrower_id", borrower.BorrowerID))
+	defer span.End()
+
+	err := r.borrowerStore.borrowers.Set(ctx, strconv.FormatInt(borrower.BorrowerID, 10), borrower)
+	if err != nil {
+		return fmt.Errorf("failed to update borrower in memcache: %w", err)
+	}
+	slog.DebugContext(ctx, "borrower updated", "borrower_id", borrower.BorrowerID, "kyc_status", borrower.KYC.Status)
+
+	return nil
+}
+
+func (r Repository) DeleteBorrower(ctx context.Context, borrowerID int64) error {
+	ctx, span := tracing.Start(ctx, "repository.DeleteBorrower", tracing.Int64("borrower_id", borrowerID))
+	defer span.End()
+
+	r.borrowerStore.mu.Lock()
+	defer r.borrowerStore.mu.Unlock()
+
+	_, err := r.borrowerStore.borrowers.Delete(ctx, strconv.FormatInt(borrowerID, 10))
+	if err != nil {
+		return fmt.Errorf("failed to delete borrower from memcache: %w", err)
+	}
+
+	ids, _, err := r.borrowerStore.ids.Get(ctx, CacheKeyBorrowerIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get borrower IDs from memcache: %w", err)
+	}
+	ids = slices.DeleteFunc(ids, func(id int64) bool { return id == borrowerID })
+	err = r.borrowerStore.ids.Set(ctx, CacheKeyBorrowerIDs, ids)
+	if err != nil {
+		return fmt.Errorf("failed to set borrower IDs in memcache: %w", err)
+	}
+	slog.DebugContext(ctx, "borrower deleted", "borrower_id", borrowerID)
+
+	return nil
+}
+
+// RestoreBorrowers writes borrowers into an empty registry, used when restoring
+// a backup.
+func (r Repository) RestoreBorrowers(ctx context.Context, borrowers []model.Borrower) error {
+	ctx, span := tracing.Start(ctx, "repository.RestoreBorrowers", tracing.Int64("count", int64(len(borrowers))))
+	defer span.End()
+
+	r.borrowerStore.mu.Lock()
+	defer r.borrowerStore.mu.Unlock()
+
+	ids := make([]int64, 0, len(borrowers))
+	for _, borrower := range borrowers {
+		err := r.borrowerStore.borrowers.Set(ctx, strconv.FormatInt(borrower.BorrowerID, 10), borrower)
+		if err != nil {
+			return fmt.Errorf("failed to set borrower in memcache: %w", err)
+		}
+		ids = append(ids, borrower.BorrowerID)
+	}
+
+	err := r.borrowerStore.ids.Set(ctx, CacheKeyBorrowerIDs, ids)
+	if err != nil {
+		return fmt.Errorf("failed to set borrower IDs in memcache: %w", err)
+	}
+	slog.InfoContext(ctx, "borrowers restored", "borrowers", len(ids))
+
+	return nil
+}
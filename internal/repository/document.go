@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+// BlobKeyPrefixDocument namespaces documents in the blob store. Content is
+// addressed by its SHA-256, so the same file uploaded twice is stored once.
+const BlobKeyPrefixDocument = "documents/sha256/"
+
+func (r Repository) PutDocument(ctx context.Context, document model.Document, content []byte) error {
+	ctx, span := tracing.Start(ctx, "repository.PutDocument", tracing.Int64("loan_id", document.LoanID), tracing.String("sha256", document.SHA256))
+	defer span.End()
+
+	err := r.blobStore.Put(ctx, BlobKeyPrefixDocument+document.SHA256, bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("failed to put document in blob store: %w", err)
+	}
+	slog.DebugContext(ctx, "document stored", "loan_id", document.LoanID, "sha256", document.SHA256, "size", len(content))
+
+	return nil
+}
+
+func (r Repository) OpenDocument(ctx context.Context, sha256 string) (io.ReadCloser, error) {
+	ctx, span := tracing.Start(ctx, "repository.OpenDocument", tracing.String("sha256", sha256))
+	defer span.End()
+
+	content, err := r.blobStore.Get(ctx, BlobKeyPrefixDocument+sha256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document from blob store: %w", err)
+	}
+
+	return content, nil
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/timotiusas11/amartha-assignment/common/driver/inmemlib"
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+const (
+	CacheKeyLastEventID  = "events:last_id"
+	CacheKeyPrefixEvent  = "event:"
+	CacheKeyPrefixStream = "loan_events:"
+)
+
+// eventLog stores each event under its own key, so the log is never rewritten,
+// plus the list of event IDs of every loan.
+type eventLog struct {
+	mu      sync.Mutex // Serializes appends, event IDs must not be reused
+	lastID  *inmemlib.Cache[int64]
+	events  *inmemlib.Cache[model.Event]
+	streams *inmemlib.Cache[[]int64]
+}
+
+func newEventLog(store inmemlib.InMemLibInterface) *eventLog {
+	// Losing the counter would make new events overwrite old ones
+	store.Watch(CacheKeyLastEventID)
+
+	return &eventLog{
+		lastID:  inmemlib.NewCache[int64](store),
+		events:  inmemlib.NewCache[model.Event](store, inmemlib.WithPrefix(CacheKeyPrefixEvent)),
+		streams: inmemlib.NewCache[[]int64](store, inmemlib.WithPrefix(CacheKeyPrefixStream)),
+	}
+}
+
+func (r Repository) AppendEvents(ctx context.Context, events ...model.Event) ([]model.Event, error) {
+	ctx, span := tracing.Start(ctx, "repository.AppendEvents", tracing.Int64("count", int64(len(events))))
+	defer span.End()
+
+	r.eventLog.mu.Lock()
+	defer r.eventLog.mu.Unlock()
+
+	lastID, _, err := r.eventLog.lastID.Get(ctx, CacheKeyLastEventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last event ID from memcache: %w", err)
+	}
+
+	appended := make([]model.Event, 0, len(events))
+	for _, event := range events {
+		lastID++
+		event.EventID = lastID
+
+		err = r.eventLog.events.Set(ctx, strconv.FormatInt(event.EventID, 10), event)
+		if err != nil {
+			return appended, fmt.Errorf("failed to set event in memcache: %w", err)
+		}
+
+		// Events that did not reach a loan are only found through the global log
+		if event.LoanID != 0 {
+			streamKey := strconv.FormatInt(event.LoanID, 10)
+			stream, _, err := r.eventLog.streams.Get(ctx, streamKey)
+			if err != nil {
+				return appended, fmt.Errorf("failed to get loan events from memcache: %w", err)
+			}
+			err = r.eventLog.streams.Set(ctx, streamKey, append(stream, event.EventID))
+			if err != nil {
+				return appended, fmt.Errorf("failed to set loan events in memcache: %w", err)
+			}
+		}
+
+		err = r.eventLog.lastID.Set(ctx, CacheKeyLastEventID, lastID)
+		if err != nil {
+			return appended, fmt.Errorf("failed to set last event ID in memcache: %w", err)
+		}
+		appended = append(appended, event)
+	}
+	slog.DebugContext(ctx, "events appended", "count", len(appended), "last_event_id", lastID)
+
+	return appended, nil
+}
+
+// RestoreEvents writes events with their original IDs, used when restoring a
+// backup into an empty store. Later appends continue after the highest ID.
+func (r Repository) RestoreEvents(ctx context.Context, events []model.Event) error {
+	ctx, span := tracing.Start(ctx, "repository.RestoreEvents", tracing.Int64("count", int64(len(events))))
+	defer span.End()
+
+	r.eventLog.mu.Lock()
+	defer r.eventLog.mu.Unlock()
+
+	lastID, _, err := r.eventLog.lastID.Get(ctx, CacheKeyLastEventID)
+	if err != nil {
+		return fmt.Errorf("failed to get last event ID from memcache: %w", err)
+	}
+
+	streams := make(map[string][]int64)
+	for _, event := range events {
+		err = r.eventLog.events.Set(ctx, strconv.FormatInt(event.EventID, 10), event)
+		if err != nil {
+			return fmt.Errorf("failed to set event in memcache: %w", err)
+		}
+		if event.LoanID != 0 {
+			streamKey := strconv.FormatInt(event.LoanID, 10)
+			streams[streamKey] = append(streams[streamKey], event.EventID)
+		}
+		lastID = max(lastID, event.EventID)
+	}
+
+	for streamKey, ids := range streams {
+		slices.Sort(ids)
+		err = r.eventLog.streams.Set(ctx, streamKey, ids)
+		if err != nil {
+			return fmt.Errorf("failed to set loan events in memcache: %w", err)
+		}
+	}
+
+	err = r.eventLog.lastID.Set(ctx, CacheKeyLastEventID, lastID)
+	if err != nil {
+		return fmt.Errorf("failed to set last event ID in memcache: %w", err)
+	}
+	slog.InfoContext(ctx, "events restored", "count", len(events), "last_event_id", lastID)
+	return nil
+}
+
+func (r Repository) ListEvents(ctx context.Context, query model.EventQuery) (model.EventPage, error) {
+	ctx, span := tracing.Start(ctx, "repository.ListEvents", tracing.Int64("loan_id", query.LoanID))
+	defer span.End()
+
+	// Candidate event IDs, in log order
+	var ids []int64
+	if query.LoanID != 0 {
+		stream, _, err := r.eventLog.streams.Get(ctx, strconv.FormatInt(query.LoanID, 10))
+		if err != nil {
+			return model.EventPage{}, fmt.Errorf("failed to get loan events from memcache: %w", err)
+		}
+		ids = stream
+	} else {
+		lastID, _, err := r.eventLog.lastID.Get(ctx, CacheKeyLastEventID)
+		if err != nil {
+			return model.EventPage{}, fmt.Errorf("failed to get last event ID from memcache: %w", err)
+		}
+		for id := query.AfterID + 1; id <= lastID; id++ {
+			ids = append(ids, id)
+		}
+	}
+
+	page := model.EventPage{Events: make([]model.Event, 0)}
+	for _, id := range ids {
+		if id <= query.AfterID {
+			continue
+		}
+
+		event, exists, err := r.eventLog.events.Get(ctx, strconv.FormatInt(id, 10))
+		if err != nil {
+			return model.EventPage{}, fmt.Errorf("failed to get event %d from memcache: %w", id, err)
+		}
+		if !exists && query.Complete {
+			return model.EventPage{}, fmt.Errorf("%w: event %d was evicted", model.ErrEventsMissing, id)
+		}
+		if !exists || !matchesEventQuery(event, query) {
+			continue
+		}
+
+		// One more match than requested means there is a next page
+		if query.Limit > 0 && len(page.Events) == query.Limit {
+			page.NextAfter = page.Events[len(page.Events)-1].EventID
+			break
+		}
+		page.Events = append(page.Events, event)
+	}
+
+	return page, nil
+}
+
+func matchesEventQuery(event model.Event, query model.EventQuery) bool {
+	if query.LoanID != 0 && event.LoanID != query.LoanID {
+		return false
+	}
+	if len(query.Types) > 0 && !slices.Contains(query.Types, event.Type) {
+		return false
+	}
+	if query.ActorID != 0 && event.ActorID != query.ActorID {
+		return false
+	}
+	if !query.Since.IsZero() && event.OccurredAt.Before(query.Since) {
+		return false
+	}
+	if !query.Until.IsZero() && !event.OccurredAt.Before(query.Until) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+
+	"github.com/timotiusas11/amartha-assignment/common/driver/inmemlib"
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+const (
+	CacheKeyInvestorIDs         = "investors:ids"
+	CacheKeyPrefixInvestorLoans = "investor_loans:"
+)
+
+// investorIndex keeps the IDs of the loans every investor invested in, so a
+// portfolio reads only its own loans instead of checking every loan. The
+// index is updated before each loan write, under the loan lock, and may list
+// loans the write then did not store. Entries the cache evicts are rebuilt
+// from the loan book when next read.
+type investorIndex struct {
+	ids   *inmemlib.Cache[[]int64]
+	loans *inmemlib.Cache[[]int64]
+}
+
+func newInvestorIndex(store inmemlib.InMemLibInterface) *investorIndex {
+	// Losing the list would leave stale entries behind when the loans are
+	// replaced, losing an entry is repaired by rebuilding the index
+	store.Watch(CacheKeyInvestorIDs)
+	store.WatchPrefix(CacheKeyPrefixInvestorLoans)
+
+	return &investorIndex{
+		ids:   inmemlib.NewCache[[]int64](store),
+		loans: inmemlib.NewCache[[]int64](store, inmemlib.WithPrefix(CacheKeyPrefixInvestorLoans)),
+	}
+}
+
+// GetInvestorLoans returns the loans the investor index lists for the
+// investor that are in the loan book. Callers check the investments, a listed
+// loan may not hold any of the investor's.
+func (r Repository) GetInvestorLoans(ctx context.Context, investorID int64) ([]model.Loan, error) {
+	ctx, span := tracing.Start(ctx, "repository.GetInvestorLoans", tracing.Int64("investor_id", investorID))
+	defer span.End()
+
+	loanIDs, exists, err := r.investorIndex.loans.Get(ctx, strconv.FormatInt(investorID, 10))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get investor loans from memcache: %w", err)
+	}
+
+	// Investors without loans have no entry either, only look further under
+	// the lock
+	if !exists {
+		r.loanStore.mu.Lock()
+		loanIDs, _, err = r.investorLoans(ctx, investorID)
+		r.loanStore.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return r.loanStore.getMany(ctx, loanIDs)
+}
+
+// investorLoans returns the IDs of the loans the index lists for the
+// investor, and whether it has an entry for them. An investor listed without
+// an entry lost it to an eviction, the index is then rebuilt from the loan
+// book first. The caller holds the loan lock.
+func (r Repository) investorLoans(ctx context.Context, investorID int64) (loanIDs []int64, exists bool, err error) {
+	key := strconv.FormatInt(investorID, 10)
+	loanIDs, exists, err = r.investorIndex.loans.Get(ctx, key)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get investor loans from memcache: %w", err)
+	}
+	if exists {
+		return loanIDs, true, nil
+	}
+
+	// Without the list of investors, e.g. before the first investment, the
+	// loan book is the only way to tell
+	ids, listed, err := r.investorIndex.ids.Get(ctx, CacheKeyInvestorIDs)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get investor IDs from memcache: %w", err)
+	}
+	if listed && !slices.Contains(ids, investorID) {
+		return nil, false, nil
+	}
+	if listed {
+		slog.WarnContext(ctx, "investor index entry evicted, rebuilding the index", "investor_id", investorID)
+	}
+
+	loans, err := r.loanStore.all(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	err = r.reindexInvestors(ctx, loans)
+	if err != nil {
+		return nil, false, err
+	}
+
+	loanIDs, exists, err = r.investorIndex.loans.Get(ctx, key)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get investor loans from memcache: %w", err)
+	}
+	return loanIDs, exists, nil
+}
+
+// indexInvestors adds the loan to the index of each of its investors. The
+// caller holds the loan lock.
+func (r Repository) indexInvestors(ctx context.Context, loan model.Loan) error {
+	var added []int64
+	for _, inv := range loan.Investments {
+		loanIDs, exists, err := r.investorLoans(ctx, inv.InvestorID)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(loanIDs, loan.LoanID) {
+			continue
+		}
+
+		err = r.investorIndex.loans.Set(ctx, strconv.FormatInt(inv.InvestorID, 10), append(loanIDs, loan.LoanID))
+		if err != nil {
+			return fmt.Errorf("failed to set investor loans in memcache: %w", err)
+		}
+		if !exists {
+			added = append(added, inv.InvestorID)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	ids, _, err := r.investorIndex.ids.Get(ctx, CacheKeyInvestorIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get investor IDs from memcache: %w", err)
+	}
+	err = r.investorIndex.ids.Set(ctx, CacheKeyInvestorIDs, append(ids, added...))
+	if err != nil {
+		return fmt.Errorf("failed to set investor IDs in memcache: %w", err)
+	}
+	return nil
+}
+
+// reindexInvestors rebuilds the whole index from the loans, clearing the
+// investors who no longer have any. The caller holds the loan lock.
+func (r Repository) reindexInvestors(ctx context.Context, loans []model.Loan) error {
+	index := make(map[int64][]int64)
+	var ids []int64
+	for _, loan := range loans {
+		for _, inv := range loan.Investments {
+			loanIDs, ok := index[inv.InvestorID]
+			if !ok {
+				ids = append(ids, inv.InvestorID)
+			}
+			if !slices.Contains(loanIDs, loan.LoanID) {
+				index[inv.InvestorID] = append(loanIDs, loan.LoanID)
+			}
+		}
+	}
+
+	previous, _, err := r.investorIndex.ids.Get(ctx, CacheKeyInvestorIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get investor IDs from memcache: %w", err)
+	}
+	for _, investorID := range previous {
+		if _, ok := index[investorID]; ok {
+			continue
+		}
+		_, err = r.investorIndex.loans.Delete(ctx, strconv.FormatInt(investorID, 10))
+		if err != nil {
+			return fmt.Errorf("failed to clear investor loans in memcache: %w", err)
+		}
+	}
+
+	for _, investorID := range ids {
+		err = r.investorIndex.loans.Set(ctx, strconv.FormatInt(investorID, 10), index[investorID])
+		if err != nil {
+			return fmt.Errorf("failed to set investor loans in memcache: %w", err)
+		}
+	}
+	err = r.investorIndex.ids.Set(ctx, CacheKeyInvestorIDs, ids)
+	if err != nil {
+		return fmt.Errorf("failed to set investor IDs in memcache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/common/driver/blob"
+	httpdriver "github.com/timotiusas11/amartha-assignment/common/driver/http"
+	"github.com/timotiusas11/amartha-assignment/common/driver/inmemlib"
+	"github.com/timotiusas11/amartha-assignment/common/driver/nsq"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+func TestGetInvestorLoansRebuildsEvictedIndex(t *testing.T) {
+	const investorID = 7
+
+	invested := func(loanID int64, investorIDs ...int64) model.Loan {
+		loan := model.Loan{LoanID: loanID, State: model.StateEnumApproved, PrincipalAmount: 1000}
+		for _, id := range investorIDs {
+			loan.Investments = append(loan.Investments, model.Investment{InvestorID: id, InvestedAmount: 100})
+		}
+		return loan
+	}
+
+	tests := []struct {
+		name    string
+		evict   []string
+		then    []model.Loan // Stored after the eviction
+		want    []int64
+		wantFor int64
+	}{
+		{
+			name: "nothing evicted",
+			want: []int64{1, 2},
+		},
+		{
+			name:  "entry of the investor",
+			evict: []string{CacheKeyPrefixInvestorLoans + "7"},
+			want:  []int64{1, 2},
+		},
+		{
+			name:  "entry and the list of investors",
+			evict: []string{CacheKeyPrefixInvestorLoans + "7", CacheKeyInvestorIDs},
+			want:  []int64{1, 2},
+		},
+		{
+			name:  "entry then another investment",
+			evict: []string{CacheKeyPrefixInvestorLoans + "7"},
+			then:  []model.Loan{invested(3, investorID)},
+			want:  []int64{1, 2, 3},
+		},
+		{
+			name:    "investor without loans",
+			evict:   []string{CacheKeyPrefixInvestorLoans + "7"},
+			wantFor: 9,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := inmemlib.New()
+			blobStore, err := blob.NewLocal(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			nsqClient := nsq.New("", 1)
+			t.Cleanup(func() { nsqClient.Stop(ctx) })
+			repo := NewRepository(store, nsqClient, httpdriver.New("", time.Second), blobStore)
+
+			for _, loan := range []model.Loan{invested(1, investorID, 8), invested(2, investorID), invested(4, 8)} {
+				if err := repo.InsertLoan(ctx, loan); err != nil {
+					t.Fatalf("InsertLoan() error = %v", err)
+				}
+			}
+			for _, key := range tt.evict {
+				if _, err := store.Delete(ctx, key); err != nil {
+					t.Fatalf("Delete(%q) error = %v", key, err)
+				}
+			}
+			for _, loan := range tt.then {
+				if err := repo.UpdateLoan(ctx, loan); err != nil {
+					t.Fatalf("UpdateLoan() error = %v", err)
+				}
+			}
+
+			wantFor := tt.wantFor
+			if wantFor == 0 {
+				wantFor = investorID
+			}
+			loans, err := repo.GetInvestorLoans(ctx, wantFor)
+			if err != nil {
+				t.Fatalf("GetInvestorLoans() error = %v", err)
+			}
+			var got []int64
+			for _, loan := range loans {
+				got = append(got, loan.LoanID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("GetInvestorLoans(%d) = %v, want %v", wantFor, got, tt.want)
+			}
+
+			// Every investor is listed again
+			ids, _, err := repo.investorIndex.ids.Get(ctx, CacheKeyInvestorIDs)
+			if err != nil {
+				t.Fatalf("Get(%q) error = %v", CacheKeyInvestorIDs, err)
+			}
+			slices.Sort(ids)
+			if !slices.Equal(ids, []int64{investorID, 8}) {
+				t.Errorf("investor IDs = %v, want [7 8]", ids)
+			}
+		})
+	}
+}
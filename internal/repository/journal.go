@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+
+	"github.com/timotiusas11/amartha-assignment/common/driver/inmemlib"
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/ledger"
+)
+
+const (
+	CacheKeyLastJournalEntryID = "journal:last_id"
+	CacheKeyPrefixJournalEntry = "journal_entry:"
+)
+
+// journal stores each journal entry under its own key, like the event log.
+// Account balances are not stored, they are summed from the entries.
+type journal struct {
+	mu      sync.Mutex // Serializes postings, entry IDs are read and written together
+	lastID  *inmemlib.Cache[int64]
+	entries *inmemlib.Cache[ledger.Entry]
+}
+
+func newJournal(store inmemlib.InMemLibInterface) *journal {
+	// Losing the counter would make new entries overwrite old ones
+	store.Watch(CacheKeyLastJournalEntryID)
+
+	return &journal{
+		lastID:  inmemlib.NewCache[int64](store),
+		entries: inmemlib.NewCache[ledger.Entry](store, inmemlib.WithPrefix(CacheKeyPrefixJournalEntry)),
+	}
+}
+
+// PostJournalEntries appends the entries to the journal. Unbalanced entries
+// are rejected before anything is written.
+func (r Repository) PostJournalEntries(ctx context.Context, entries ...ledger.Entry) ([]ledger.Entry, error) {
+	ctx, span := tracing.Start(ctx, "repository.PostJournalEntries", tracing.Int64("count", int64(len(entries))))
+	defer span.End()
+
+	for _, entry := range entries {
+		if err := entry.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	r.journal.mu.Lock()
+	defer r.journal.mu.Unlock()
+
+	lastID, _, err := r.journal.lastID.Get(ctx, CacheKeyLastJournalEntryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last journal entry ID from memcache: %w", err)
+	}
+
+	posted := make([]ledger.Entry, 0, len(entries))
+	for _, entry := range entries {
+		lastID++
+		entry.EntryID = lastID
+
+		err = r.journal.entries.Set(ctx, strconv.FormatInt(entry.EntryID, 10), entry)
+		if err != nil {
+			return posted, fmt.Errorf("failed to set journal entry in memcache: %w", err)
+		}
+		err = r.journal.lastID.Set(ctx, CacheKeyLastJournalEntryID, lastID)
+		if err != nil {
+			return posted, fmt.Errorf("failed to set last journal entry ID in memcache: %w", err)
+		}
+		posted = append(posted, entry)
+	}
+
+	slog.DebugContext(ctx, "journal entries posted", "count", len(posted), "last_entry_id", lastID)
+	return posted, nil
+}
+
+// RestoreJournalEntries writes entries with their original IDs into an empty
+// journal, used when restoring a backup. Restoring the same entries again
+// gives the same journal.
+func (r Repository) RestoreJournalEntries(ctx context.Context, entries []ledger.Entry) error {
+	ctx, span := tracing.Start(ctx, "repository.RestoreJournalEntries", tracing.Int64("count", int64(len(entries))))
+	defer span.End()
+
+	r.journal.mu.Lock()
+	defer r.journal.mu.Unlock()
+
+	var lastID int64
+	for _, entry := range entries {
+		if err := entry.Validate(); err != nil {
+			return fmt.Errorf("journal entry %d: %w", entry.EntryID, err)
+		}
+		err := r.journal.entries.Set(ctx, strconv.FormatInt(entry.EntryID, 10), entry)
+		if err != nil {
+			return fmt.Errorf("failed to set journal entry in memcache: %w", err)
+		}
+		lastID = max(lastID, entry.EntryID)
+	}
+
+	err := r.journal.lastID.Set(ctx, CacheKeyLastJournalEntryID, lastID)
+	if err != nil {
+		return fmt.Errorf("failed to set last journal entry ID in memcache: %w", err)
+	}
+	slog.InfoContext(ctx, "journal entries restored", "count", len(entries), "last_entry_id", lastID)
+	return nil
+}
+
+func (r Repository) ListJournalEntries(ctx context.Context, query ledger.EntryQuery) (ledger.EntryPage, error) {
+	ctx, span := tracing.Start(ctx, "repository.ListJournalEntries", tracing.Int64("loan_id", query.LoanID))
+	defer span.End()
+
+	lastID, _, err := r.journal.lastID.Get(ctx, CacheKeyLastJournalEntryID)
+	if err != nil {
+		return ledger.EntryPage{}, fmt.Errorf("failed to get last journal entry ID from memcache: %w", err)
+	}
+
+	page := ledger.EntryPage{Entries: make([]ledger.Entry, 0)}
+	for id := query.AfterID + 1; id <= lastID; id++ {
+		entry, exists, err := r.journal.entries.Get(ctx, strconv.FormatInt(id, 10))
+		if err != nil {
+			return ledger.EntryPage{}, fmt.Errorf("failed to get journal entry %d from memcache: %w", id, err)
+		}
+		if !exists && query.Complete {
+			return ledger.EntryPage{}, fmt.Errorf("%w: entry %d was evicted", ledger.ErrEntriesMissing, id)
+		}
+		if !exists || !query.Matches(entry) {
+			continue
+		}
+
+		// One more match than requested means there is a next page
+		if query.Limit > 0 && len(page.Entries) == query.Limit {
+			page.NextAfter = page.Entries[len(page.Entries)-1].EntryID
+			break
+		}
+		page.Entries = append(page.Entries, entry)
+	}
+
+	return page, nil
+}
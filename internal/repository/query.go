@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+// loanCursor marks the last loan of a page. Pages continue strictly after it
+// in (sort value, loan ID) order, so loans added meanwhile do not shift pages.
+type loanCursor struct {
+	SortBy     model.LoanSortField `json:"s"`
+	Descending bool                `json:"d"`
+	Value      float64             `json:"v"`
+	LoanID     int64               `json:"id"`
+}
+
+// QueryLoans returns a page of the loans matching query. There is no
+// secondary index: the whole loan book is filtered and sorted in memory for
+// every page.
+func (r Repository) QueryLoans(ctx context.Context, query model.LoanQuery) (model.LoanPage, error) {
+	ctx, span := tracing.Start(ctx, "repository.QueryLoans", tracing.String("sort_by", string(query.SortBy)))
+	defer span.End()
+
+	if query.SortBy == "" {
+		query.SortBy = model.LoanSortCreatedAt
+	}
+	if !slices.Contains(model.LoanSortFields, query.SortBy) {
+		return model.LoanPage{}, fmt.Errorf("%w: unknown sort field %q", model.ErrInvalidQuery, query.SortBy)
+	}
+
+	var after *loanCursor
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return model.LoanPage{}, err
+		}
+		if cursor.SortBy != query.SortBy || cursor.Descending != query.Descending {
+			return model.LoanPage{}, fmt.Errorf("%w: cursor was issued for another sort order", model.ErrInvalidQuery)
+		}
+		after = &cursor
+	}
+
+	loans, err := r.loanStore.all(ctx)
+	if err != nil {
+		return model.LoanPage{}, err
+	}
+
+	// Filter
+	matches := make([]model.Loan, 0, len(loans))
+	for _, loan := range loans {
+		if matchesQuery(loan, query) {
+			matches = append(matches, loan)
+		}
+	}
+
+	// Sort by the requested field, ties broken by loan ID in the same direction
+	sort.Slice(matches, func(i, j int) bool {
+		return before(sortValue(matches[i], query.SortBy), matches[i].LoanID, sortValue(matches[j], query.SortBy), matches[j].LoanID, query.Descending)
+	})
+
+	page := model.LoanPage{
+		Loans:      make([]model.Loan, 0, query.Limit),
+		TotalCount: len(matches),
+	}
+
+	// Skip everything up to and including the cursor
+	start := 0
+	if after != nil {
+		start = sort.Search(len(matches), func(i int) bool {
+			return before(after.Value, after.LoanID, sortValue(matches[i], query.SortBy), matches[i].LoanID, query.Descending)
+		})
+	}
+
+	end := len(matches)
+	if query.Limit > 0 && start+query.Limit < end {
+		end = start + query.Limit
+	}
+	page.Loans = append(page.Loans, matches[start:end]...)
+
+	// Only hand out a cursor when there is something after this page
+	if end < len(matches) && end > start {
+		last := matches[end-1]
+		page.NextCursor = encodeCursor(loanCursor{
+			SortBy:     query.SortBy,
+			Descending: query.Descending,
+			Value:      sortValue(last, query.SortBy),
+			LoanID:     last.LoanID,
+		})
+	}
+
+	return page, nil
+}
+
+func matchesQuery(loan model.Loan, query model.LoanQuery) bool {
+	if len(query.States) > 0 && !slices.Contains(query.States, loan.State) {
+		return false
+	}
+	if query.BorrowerID != 0 && loan.BorrowerID != query.BorrowerID {
+		return false
+	}
+	if !query.PrincipalAmount.Contains(loan.PrincipalAmount) ||
+		!query.Rate.Contains(loan.Rate) ||
+		!query.ROI.Contains(loan.ROI) ||
+		!query.RemainingAmount.Contains(loan.RemainingAmount()) {
+		return false
+	}
+	if !query.CreatedFrom.IsZero() && loan.CreatedAt.Before(query.CreatedFrom) {
+		return false
+	}
+	if !query.CreatedTo.IsZero() && !loan.CreatedAt.Before(query.CreatedTo) {
+		return false
+	}
+	return true
+}
+
+func sortValue(loan model.Loan, field model.LoanSortField) float64 {
+	switch field {
+	case model.LoanSortPrincipalAmount:
+		return loan.PrincipalAmount
+	case model.LoanSortRate:
+		return loan.Rate
+	case model.LoanSortROI:
+		return loan.ROI
+	case model.LoanSortRemainingAmount:
+		return loan.RemainingAmount()
+	default:
+		// Millisecond precision keeps the value exact in a float64
+		return float64(loan.CreatedAt.UnixMilli())
+	}
+}
+
+// before reports whether (a, aID) sorts strictly before (b, bID).
+func before(a float64, aID int64, b float64, bID int64, descending bool) bool {
+	if a != b {
+		return (a < b) != descending
+	}
+	if aID != bID {
+		return (aID < bID) != descending
+	}
+	return false
+}
+
+func encodeCursor(cursor loanCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string) (loanCursor, error) {
+	var cursor loanCursor
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil || json.Unmarshal(data, &cursor) != nil {
+		return loanCursor{}, fmt.Errorf("%w: malformed cursor", model.ErrInvalidQuery)
+	}
+	return cursor, nil
+}
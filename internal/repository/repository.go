@@ -1,145 +1,369 @@
 package repository
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
 
+	"github.com/timotiusas11/amartha-assignment/common/driver/blob"
 	"github.com/timotiusas11/amartha-assignment/common/driver/http"
 	"github.com/timotiusas11/amartha-assignment/common/driver/inmemlib"
 	"github.com/timotiusas11/amartha-assignment/common/driver/nsq"
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/ledger"
 	"github.com/timotiusas11/amartha-assignment/internal/model"
 )
 
 type RepositoryInterface interface {
-	InsertLoan(loan model.Loan) error
-	GetLoans() ([]model.Loan, error)
-	GetLoan(loanID int64) (model.Loan, error)
-	UpdateLoan(loan model.Loan) error
-	Publish(loanID int64, invesment model.Investment) error
-	GenerateAgreementLetter(loanID int64) error
+	InsertLoan(ctx context.Context, loan model.Loan) error
+	GetLoans(ctx context.Context) ([]model.Loan, error)
+	QueryLoans(ctx context.Context, query model.LoanQuery) (model.LoanPage, error)
+	GetLoan(ctx context.Context, loanID int64) (model.Loan, error)
+	UpdateLoan(ctx context.Context, loan model.Loan) error
+	DeleteLoan(ctx context.Context, loanID int64) error
+	ReplaceLoans(ctx context.Context, loans []model.Loan) error
+	Publish(ctx context.Context, loanID int64, invesment model.Investment) error
+	GenerateAgreementLetter(ctx context.Context, loanID int64) error
+	CacheStats(ctx context.Context) inmemlib.Stats
+	AppendEvents(ctx context.Context, events ...model.Event) ([]model.Event, error)
+	ListEvents(ctx context.Context, query model.EventQuery) (model.EventPage, error)
+	RestoreEvents(ctx context.Context, events []model.Event) error
+	PutDocument(ctx context.Context, document model.Document, content []byte) error
+	OpenDocument(ctx context.Context, sha256 string) (io.ReadCloser, error)
+	InsertBorrower(ctx context.Context, borrower model.Borrower) error
+	GetBorrower(ctx context.Context, borrowerID int64) (model.Borrower, error)
+	GetBorrowers(ctx context.Context) ([]model.Borrower, error)
+	UpdateBorrower(ctx context.Context, borrower model.Borrower) error
+	DeleteBorrower(ctx context.Context, borrowerID int64) error
+	RestoreBorrowers(ctx context.Context, borrowers []model.Borrower) error
+	PostWalletEntries(ctx context.Context, entries ...model.WalletEntry) ([]model.WalletEntry, error)
+	RestoreWalletEntries(ctx context.Context, entries []model.WalletEntry) error
+	GetWallet(ctx context.Context, investorID int64) (model.Wallet, error)
+	GetWallets(ctx context.Context) ([]model.Wallet, error)
+	ListWalletEntries(ctx context.Context, query model.WalletEntryQuery) (model.WalletEntryPage, error)
+	PostJournalEntries(ctx context.Context, entries ...ledger.Entry) ([]ledger.Entry, error)
+	RestoreJournalEntries(ctx context.Context, entries []ledger.Entry) error
+	ListJournalEntries(ctx context.Context, query ledger.EntryQuery) (ledger.EntryPage, error)
+	GetInvestorLoans(ctx context.Context, investorID int64) ([]model.Loan, error)
+	GetRestoreInProgress(ctx context.Context) (string, error)
+	SetRestoreInProgress(ctx context.Context, checksum string) error
 }
 
 type Repository struct {
-	inmemlib   inmemlib.InMemLibInterface
-	nsqClient  nsq.NSQInterface
-	httpClient http.HTTPInterface
+	inmemlib      inmemlib.InMemLibInterface
+	loanStore     *loanStore
+	eventLog      *eventLog
+	borrowerStore *borrowerStore
+	walletLedger  *walletLedger
+	journal       *journal
+	investorIndex *investorIndex
+	restore       *inmemlib.Cache[string]
+	nsqClient     nsq.NSQInterface
+	httpClient    http.HTTPInterface
+	blobStore     blob.BlobInterface
 }
 
-func NewRepository() Repository {
+func NewRepository(inmemlibClient inmemlib.InMemLibInterface, nsqClient nsq.NSQInterface, httpClient http.HTTPInterface, blobStore blob.BlobInterface) Repository {
+	// Losing the marker of an unfinished restore would block retrying it
+	inmemlibClient.Watch(CacheKeyRestoreInProgress)
+
 	return Repository{
-		inmemlib:   inmemlib.New(),
-		nsqClient:  nsq.New(),
-		httpClient: http.New(),
+		inmemlib:      inmemlibClient,
+		loanStore:     newLoanStore(inmemlibClient),
+		eventLog:      newEventLog(inmemlibClient),
+		borrowerStore: newBorrowerStore(inmemlibClient),
+		walletLedger:  newWalletLedger(inmemlibClient),
+		journal:       newJournal(inmemlibClient),
+		investorIndex: newInvestorIndex(inmemlibClient),
+		restore:       inmemlib.NewCache[string](inmemlibClient),
+		nsqClient:     nsqClient,
+		httpClient:    httpClient,
+		blobStore:     blobStore,
 	}
 }
 
 const (
-	CacheKeyLoans = "loans"
+	CacheKeyPrefixLoanIDs = "loans:ids:"
+	CacheKeyPrefixLoan    = "loan:"
 )
 
-func (r Repository) InsertLoan(loan model.Loan) error {
-	var loanMap map[int64]model.Loan
+// loanIDShards is the number of keys the loan IDs are spread over. An ID takes
+// about 14 bytes, so with the default 10MB cache, whose entries are limited to
+// 10KB, a shard lists some 700 loans and the loan book some 45000.
+const loanIDShards = 64
 
-	// Retrieve existing loan map from memcache
-	exists, err := r.inmemlib.Get(CacheKeyLoans, func(val []byte) error {
-		return json.Unmarshal(val, &loanMap)
-	})
+// loanStore keeps each loan under its own key, so the loan book is not bound
+// by the size of a single cache entry, plus the loan IDs spread over
+// loanIDShards lists.
+type loanStore struct {
+	mu    sync.Mutex // Guards the ID lists and the existence checks
+	ids   *inmemlib.Cache[[]int64]
+	loans *inmemlib.Cache[model.Loan]
+}
+
+func newLoanStore(store inmemlib.InMemLibInterface) *loanStore {
+	// Losing a list would hide its loans from listings, losing a loan loses it
+	for shard := range loanIDShards {
+		store.Watch(CacheKeyPrefixLoanIDs + strconv.Itoa(shard))
+	}
+	store.WatchPrefix(CacheKeyPrefixLoan)
 
+	return &loanStore{
+		ids:   inmemlib.NewCache[[]int64](store, inmemlib.WithPrefix(CacheKeyPrefixLoanIDs)),
+		loans: inmemlib.NewCache[model.Loan](store, inmemlib.WithPrefix(CacheKeyPrefixLoan)),
+	}
+}
+
+func loanKey(loanID int64) string {
+	return strconv.FormatInt(loanID, 10)
+}
+
+func loanIDShard(loanID int64) string {
+	return strconv.FormatInt(loanID%loanIDShards, 10)
+}
+
+// listIDs returns the IDs of every loan in the loan book.
+func (s *loanStore) listIDs(ctx context.Context) ([]int64, error) {
+	var ids []int64
+	for shard := range loanIDShards {
+		shardIDs, _, err := s.ids.Get(ctx, strconv.Itoa(shard))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get loan IDs from memcache: %w", err)
+		}
+		ids = append(ids, shardIDs...)
+	}
+	return ids, nil
+}
+
+// getMany returns the loans stored for ids, in the order of ids. IDs without a
+// loan are skipped.
+func (s *loanStore) getMany(ctx context.Context, ids []int64) ([]model.Loan, error) {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = loanKey(id)
+	}
+	found, err := s.loans.GetMany(ctx, keys)
 	if err != nil {
-		return fmt.Errorf("failed to get loans from memcache: %w", err)
+		return nil, fmt.Errorf("failed to get loans from memcache: %w", err)
 	}
 
-	if !exists {
-		// Cache miss, create a new map
-		loanMap = make(map[int64]model.Loan)
+	loans := make([]model.Loan, 0, len(found))
+	for _, key := range keys {
+		if loan, ok := found[key]; ok {
+			loans = append(loans, loan)
+		}
 	}
+	return loans, nil
+}
 
-	// Add the new loan to the map
-	loanMap[loan.LoanID] = loan
+// all returns every loan in the loan book.
+func (s *loanStore) all(ctx context.Context) ([]model.Loan, error) {
+	ids, err := s.listIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.getMany(ctx, ids)
+}
 
-	// Set the updated map back into memcache
-	err = r.inmemlib.Set(CacheKeyLoans, loanMap)
+// set stores the loan and lists its ID. The caller holds the lock.
+func (s *loanStore) set(ctx context.Context, loan model.Loan) error {
+	err := s.loans.Set(ctx, loanKey(loan.LoanID), loan)
 	if err != nil {
-		return fmt.Errorf("failed to set updated loan data in memcache: %w", err)
+		return fmt.Errorf("failed to set loan in memcache: %w", err)
 	}
 
+	shard := loanIDShard(loan.LoanID)
+	ids, _, err := s.ids.Get(ctx, shard)
+	if err != nil {
+		return fmt.Errorf("failed to get loan IDs from memcache: %w", err)
+	}
+	if slices.Contains(ids, loan.LoanID) {
+		return nil
+	}
+	err = s.ids.Set(ctx, shard, append(ids, loan.LoanID))
+	if err != nil {
+		return fmt.Errorf("failed to set loan IDs in memcache: %w", err)
+	}
 	return nil
 }
 
-func (r Repository) GetLoans() ([]model.Loan, error) {
-	var loanMap map[int64]model.Loan
+func (r Repository) InsertLoan(ctx context.Context, loan model.Loan) error {
+	ctx, span := tracing.Start(ctx, "repository.InsertLoan", tracing.Int64("loan_id", loan.LoanID))
+	defer span.End()
 
-	// Retrieve existing loan map from memcache
-	_, err := r.inmemlib.Get(CacheKeyLoans, func(val []byte) error {
-		return json.Unmarshal(val, &loanMap)
-	})
+	r.loanStore.mu.Lock()
+	defer r.loanStore.mu.Unlock()
 
+	// Index the investors first, a failure then leaves the loan book as it
+	// was. An entry left behind by a failed write is skipped by portfolios.
+	err := r.indexInvestors(ctx, loan)
 	if err != nil {
-		return []model.Loan{}, fmt.Errorf("failed to get loans from memcache: %w", err)
+		return err
 	}
 
-	var loans []model.Loan = make([]model.Loan, 0)
-
-	for _, v := range loanMap {
-		loans = append(loans, v)
+	err = r.loanStore.set(ctx, loan)
+	if err != nil {
+		return err
 	}
+	slog.DebugContext(ctx, "loan inserted", "loan_id", loan.LoanID, "state", loan.State.String())
 
-	return loans, nil
+	return nil
 }
 
-func (r Repository) GetLoan(loanID int64) (model.Loan, error) {
-	var loanMap map[int64]model.Loan
+func (r Repository) GetLoans(ctx context.Context) ([]model.Loan, error) {
+	ctx, span := tracing.Start(ctx, "repository.GetLoans")
+	defer span.End()
 
-	// Retrieve existing loan map from memcache
-	_, err := r.inmemlib.Get(CacheKeyLoans, func(val []byte) error {
-		return json.Unmarshal(val, &loanMap)
-	})
+	return r.loanStore.all(ctx)
+}
 
+func (r Repository) GetLoan(ctx context.Context, loanID int64) (model.Loan, error) {
+	ctx, span := tracing.Start(ctx, "repository.GetLoan", tracing.Int64("loan_id", loanID))
+	defer span.End()
+
+	loan, _, err := r.loanStore.loans.Get(ctx, loanKey(loanID))
 	if err != nil {
-		return model.Loan{}, fmt.Errorf("failed to get loans from memcache: %w", err)
+		return model.Loan{}, fmt.Errorf("failed to get loan from memcache: %w", err)
 	}
 
-	return loanMap[loanID], nil
+	return loan, nil
 }
 
-func (r Repository) UpdateLoan(loan model.Loan) error {
-	loanMap := make(map[int64]model.Loan)
+func (r Repository) UpdateLoan(ctx context.Context, loan model.Loan) error {
+	ctx, span := tracing.Start(ctx, "repository.UpdateLoan", tracing.Int64("loan_id", loan.LoanID))
+	defer span.End()
 
-	// Retrieve existing loan map from memcache
-	_, err := r.inmemlib.Get(CacheKeyLoans, func(val []byte) error {
-		return json.Unmarshal(val, &loanMap)
-	})
+	r.loanStore.mu.Lock()
+	defer r.loanStore.mu.Unlock()
+
+	// Index the investors first, like InsertLoan
+	err := r.indexInvestors(ctx, loan)
 	if err != nil {
-		return fmt.Errorf("failed to get loans from cache: %w", err)
+		return err
 	}
 
-	// Update the loan in the map
-	loanMap[loan.LoanID] = loan
+	err = r.loanStore.set(ctx, loan)
+	if err != nil {
+		return err
+	}
+	slog.DebugContext(ctx, "loan updated", "loan_id", loan.LoanID, "state", loan.State.String())
+
+	return nil
+}
+
+// DeleteLoan removes a loan from the loan book. The investor index may keep
+// pointing at it, portfolios skip the loans that no longer exist.
+func (r Repository) DeleteLoan(ctx context.Context, loanID int64) error {
+	ctx, span := tracing.Start(ctx, "repository.DeleteLoan", tracing.Int64("loan_id", loanID))
+	defer span.End()
 
-	// Set the updated map back into memcache
-	err = r.inmemlib.Set(CacheKeyLoans, loanMap)
+	r.loanStore.mu.Lock()
+	defer r.loanStore.mu.Unlock()
+
+	_, err := r.loanStore.loans.Delete(ctx, loanKey(loanID))
+	if err != nil {
+		return fmt.Errorf("failed to delete loan from memcache: %w", err)
+	}
+
+	shard := loanIDShard(loanID)
+	ids, _, err := r.loanStore.ids.Get(ctx, shard)
 	if err != nil {
-		return fmt.Errorf("failed to update loans in cache: %w", err)
+		return fmt.Errorf("failed to get loan IDs from memcache: %w", err)
 	}
+	if !slices.Contains(ids, loanID) {
+		return nil
+	}
+	ids = slices.DeleteFunc(ids, func(id int64) bool { return id == loanID })
+	err = r.loanStore.ids.Set(ctx, shard, ids)
+	if err != nil {
+		return fmt.Errorf("failed to set loan IDs in memcache: %w", err)
+	}
+	slog.DebugContext(ctx, "loan deleted", "loan_id", loanID)
 
 	return nil
 }
 
+// ReplaceLoans swaps the whole loan book for loans, used when rebuilding it.
+// The investor index is rebuilt with it.
+func (r Repository) ReplaceLoans(ctx context.Context, loans []model.Loan) error {
+	ctx, span := tracing.Start(ctx, "repository.ReplaceLoans", tracing.Int64("count", int64(len(loans))))
+	defer span.End()
+
+	r.loanStore.mu.Lock()
+	defer r.loanStore.mu.Unlock()
+
+	previous, err := r.loanStore.listIDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	shards := make([][]int64, loanIDShards)
+	kept := make(map[int64]bool, len(loans))
+	for _, loan := range loans {
+		err = r.loanStore.loans.Set(ctx, loanKey(loan.LoanID), loan)
+		if err != nil {
+			return fmt.Errorf("failed to replace loans in memcache: %w", err)
+		}
+		if !kept[loan.LoanID] {
+			shard := loan.LoanID % loanIDShards
+			shards[shard] = append(shards[shard], loan.LoanID)
+		}
+		kept[loan.LoanID] = true
+	}
+
+	// Drop the loans the new book no longer has
+	for _, loanID := range previous {
+		if kept[loanID] {
+			continue
+		}
+		_, err = r.loanStore.loans.Delete(ctx, loanKey(loanID))
+		if err != nil {
+			return fmt.Errorf("failed to delete loan from memcache: %w", err)
+		}
+	}
+
+	for shard, ids := range shards {
+		err = r.loanStore.ids.Set(ctx, strconv.Itoa(shard), ids)
+		if err != nil {
+			return fmt.Errorf("failed to set loan IDs in memcache: %w", err)
+		}
+	}
+	slog.InfoContext(ctx, "loan book replaced", "loans", len(kept))
+
+	return r.reindexInvestors(ctx, loans)
+}
+
 const (
 	EmailAgreementLetterChannel    = "email_agreement_letter"
 	GenerateAgreementLetterChannel = "generate_agreement_letter"
 )
 
-func (r Repository) Publish(loanID int64, invesment model.Investment) error {
-	return r.nsqClient.Send(EmailAgreementLetterChannel, map[string]interface{}{
+func (r Repository) Publish(ctx context.Context, loanID int64, invesment model.Investment) error {
+	ctx, span := tracing.Start(ctx, "repository.Publish", tracing.Int64("loan_id", loanID))
+	defer span.End()
+
+	slog.DebugContext(ctx, "publishing message", "channel", EmailAgreementLetterChannel, "loan_id", loanID, "investor_id", invesment.InvestorID)
+	return r.nsqClient.Send(ctx, EmailAgreementLetterChannel, map[string]interface{}{
 		"loan_id":         loanID,
 		"investor_id":     invesment.InvestorID,
 		"invested_amount": invesment.InvestedAmount,
 	})
 }
 
-func (r Repository) GenerateAgreementLetter(loanID int64) error {
-	return r.nsqClient.Send(GenerateAgreementLetterChannel, map[string]interface{}{
+func (r Repository) GenerateAgreementLetter(ctx context.Context, loanID int64) error {
+	ctx, span := tracing.Start(ctx, "repository.GenerateAgreementLetter", tracing.Int64("loan_id", loanID))
+	defer span.End()
+
+	slog.DebugContext(ctx, "publishing message", "channel", GenerateAgreementLetterChannel, "loan_id", loanID)
+	return r.nsqClient.Send(ctx, GenerateAgreementLetterChannel, map[string]interface{}{
 		"loan_id": loanID,
 	})
 }
+
+func (r Repository) CacheStats(_ context.Context) inmemlib.Stats {
+	return r.inmemlib.Stats()
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+)
+
+const CacheKeyRestoreInProgress = "restore:in_progress"
+
+// GetRestoreInProgress returns the checksum of the archive whose restore
+// started but did not finish, empty when there is none.
+func (r Repository) GetRestoreInProgress(ctx context.Context) (string, error) {
+	ctx, span := tracing.Start(ctx, "repository.GetRestoreInProgress")
+	defer span.End()
+
+	checksum, _, err := r.restore.Get(ctx, CacheKeyRestoreInProgress)
+	if err != nil {
+		return "", fmt.Errorf("failed to get restore in progress from memcache: %w", err)
+	}
+	return checksum, nil
+}
+
+// SetRestoreInProgress records the checksum of the archive being restored, an
+// empty checksum clears it once the restore is done.
+func (r Repository) SetRestoreInProgress(ctx context.Context, checksum string) error {
+	ctx, span := tracing.Start(ctx, "repository.SetRestoreInProgress")
+	defer span.End()
+
+	if checksum == "" {
+		_, err := r.restore.Delete(ctx, CacheKeyRestoreInProgress)
+		if err != nil {
+			return fmt.Errorf("failed to clear restore in progress in memcache: %w", err)
+		}
+		return nil
+	}
+
+	err := r.restore.Set(ctx, CacheKeyRestoreInProgress, checksum)
+	if err != nil {
+		return fmt.Errorf("failed to set restore in progress in memcache: %w", err)
+	}
+	slog.InfoContext(ctx, "restore started", "checksum", checksum)
+	return nil
+}
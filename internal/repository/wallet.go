@@ -0,0 +1,283 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/timotiusas11/amartha-assignment/common/driver/inmemlib"
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+const (
+	CacheKeyLastWalletEntryID    = "wallet_entries:last_id"
+	CacheKeyWalletIDs            = "wallets:ids"
+	CacheKeyPrefixWallet         = "wallet:"
+	CacheKeyPrefixWalletEntry    = "wallet_entry:"
+	CacheKeyPrefixInvestorStream = "investor_wallet_entries:"
+)
+
+// walletLedger stores each wallet entry under its own key, like the event log,
+// plus the balances and the entry IDs of every investor.
+type walletLedger struct {
+	mu      sync.Mutex // Serializes postings, balances are checked and written together
+	lastID  *inmemlib.Cache[int64]
+	ids     *inmemlib.Cache[[]int64]
+	wallets *inmemlib.Cache[model.Wallet]
+	entries *inmemlib.Cache[model.WalletEntry]
+	streams *inmemlib.Cache[[]int64]
+}
+
+func newWalletLedger(store inmemlib.InMemLibInterface) *walletLedger {
+	// Losing the counter would make new entries overwrite old ones, losing
+	// the list would hide wallets from the backup
+	store.Watch(CacheKeyLastWalletEntryID)
+	store.Watch(CacheKeyWalletIDs)
+
+	return &walletLedger{
+		lastID:  inmemlib.NewCache[int64](store),
+		ids:     inmemlib.NewCache[[]int64](store),
+		wallets: inmemlib.NewCache[model.Wallet](store, inmemlib.WithPrefix(CacheKeyPrefixWallet)),
+		entries: inmemlib.NewCache[model.WalletEntry](store, inmemlib.WithPrefix(CacheKeyPrefixWalletEntry)),
+		streams: inmemlib.NewCache[[]int64](store, inmemlib.WithPrefix(CacheKeyPrefixInvestorStream)),
+	}
+}
+
+// PostWalletEntries applies the entries to the wallets of their investors and
+// appends them to the ledger. Every entry is checked before anything is
+// written, so a rejected one, such as one failing with
+// model.ErrInsufficientFunds because it would overdraw an account, posts none.
+// The entries are written before the wallets, as the balances are rebuilt from
+// them on restore, and a cache write failing part way returns the entries
+// written so far with the error.
+func (r Repository) PostWalletEntries(ctx context.Context, entries ...model.WalletEntry) ([]model.WalletEntry, error) {
+	ctx, span := tracing.Start(ctx, "repository.PostWalletEntries", tracing.Int64("count", int64(len(entries))))
+	defer span.End()
+
+	r.walletLedger.mu.Lock()
+	defer r.walletLedger.mu.Unlock()
+
+	// Apply every entry before writing, so a rejected one leaves no trace
+	wallets := make(map[int64]model.Wallet)
+	var created []int64
+	for _, entry := range entries {
+		wallet, ok := wallets[entry.InvestorID]
+		if !ok {
+			var exists bool
+			var err error
+			wallet, exists, err = r.walletLedger.wallets.Get(ctx, strconv.FormatInt(entry.InvestorID, 10))
+			if err != nil {
+				return nil, fmt.Errorf("failed to get wallet from memcache: %w", err)
+			}
+			if !exists {
+				created = append(created, entry.InvestorID)
+			}
+		}
+		if err := wallet.Apply(entry); err != nil {
+			return nil, err
+		}
+		wallets[entry.InvestorID] = wallet
+	}
+
+	lastID, _, err := r.walletLedger.lastID.Get(ctx, CacheKeyLastWalletEntryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last wallet entry ID from memcache: %w", err)
+	}
+
+	posted := make([]model.WalletEntry, 0, len(entries))
+	for _, entry := range entries {
+		lastID++
+		entry.EntryID = lastID
+
+		err = r.walletLedger.entries.Set(ctx, strconv.FormatInt(entry.EntryID, 10), entry)
+		if err != nil {
+			return posted, fmt.Errorf("failed to set wallet entry in memcache: %w", err)
+		}
+
+		streamKey := strconv.FormatInt(entry.InvestorID, 10)
+		stream, _, err := r.walletLedger.streams.Get(ctx, streamKey)
+		if err != nil {
+			return posted, fmt.Errorf("failed to get investor wallet entries from memcache: %w", err)
+		}
+		err = r.walletLedger.streams.Set(ctx, streamKey, append(stream, entry.EntryID))
+		if err != nil {
+			return posted, fmt.Errorf("failed to set investor wallet entries in memcache: %w", err)
+		}
+
+		err = r.walletLedger.lastID.Set(ctx, CacheKeyLastWalletEntryID, lastID)
+		if err != nil {
+			return posted, fmt.Errorf("failed to set last wallet entry ID in memcache: %w", err)
+		}
+		posted = append(posted, entry)
+	}
+
+	for investorID, wallet := range wallets {
+		err = r.walletLedger.wallets.Set(ctx, strconv.FormatInt(investorID, 10), wallet)
+		if err != nil {
+			return posted, fmt.Errorf("failed to set wallet in memcache: %w", err)
+		}
+	}
+	if len(created) > 0 {
+		ids, _, err := r.walletLedger.ids.Get(ctx, CacheKeyWalletIDs)
+		if err != nil {
+			return posted, fmt.Errorf("failed to get wallet IDs from memcache: %w", err)
+		}
+		err = r.walletLedger.ids.Set(ctx, CacheKeyWalletIDs, append(ids, created...))
+		if err != nil {
+			return posted, fmt.Errorf("failed to set wallet IDs in memcache: %w", err)
+		}
+	}
+	slog.DebugContext(ctx, "wallet entries posted", "count", len(posted), "wallets", len(wallets))
+
+	return posted, nil
+}
+
+// RestoreWalletEntries writes entries with their original IDs into an empty
+// ledger, used when restoring a backup. The balances are rebuilt from them.
+func (r Repository) RestoreWalletEntries(ctx context.Context, entries []model.WalletEntry) error {
+	ctx, span := tracing.Start(ctx, "repository.RestoreWalletEntries", tracing.Int64("count", int64(len(entries))))
+	defer span.End()
+
+	r.walletLedger.mu.Lock()
+	defer r.walletLedger.mu.Unlock()
+
+	var (
+		lastID  int64
+		ids     []int64
+		wallets = make(map[int64]model.Wallet)
+		streams = make(map[int64][]int64)
+	)
+	for _, entry := range entries {
+		wallet, ok := wallets[entry.InvestorID]
+		if !ok {
+			ids = append(ids, entry.InvestorID)
+		}
+		if err := wallet.Apply(entry); err != nil {
+			return fmt.Errorf("failed to apply wallet entry %d: %w", entry.EntryID, err)
+		}
+		wallets[entry.InvestorID] = wallet
+
+		err := r.walletLedger.entries.Set(ctx, strconv.FormatInt(entry.EntryID, 10), entry)
+		if err != nil {
+			return fmt.Errorf("failed to set wallet entry in memcache: %w", err)
+		}
+		streams[entry.InvestorID] = append(streams[entry.InvestorID], entry.EntryID)
+		lastID = max(lastID, entry.EntryID)
+	}
+
+	for investorID, stream := range streams {
+		slices.Sort(stream)
+		err := r.walletLedger.streams.Set(ctx, strconv.FormatInt(investorID, 10), stream)
+		if err != nil {
+			return fmt.Errorf("failed to set investor wallet entries in memcache: %w", err)
+		}
+	}
+	for investorID, wallet := range wallets {
+		err := r.walletLedger.wallets.Set(ctx, strconv.FormatInt(investorID, 10), wallet)
+		if err != nil {
+			return fmt.Errorf("failed to set wallet in memcache: %w", err)
+		}
+	}
+	err := r.walletLedger.ids.Set(ctx, CacheKeyWalletIDs, ids)
+	if err != nil {
+		return fmt.Errorf("failed to set wallet IDs in memcache: %w", err)
+	}
+	err = r.walletLedger.lastID.Set(ctx, CacheKeyLastWalletEntryID, lastID)
+	if err != nil {
+		return fmt.Errorf("failed to set last wallet entry ID in memcache: %w", err)
+	}
+	slog.InfoContext(ctx, "wallet entries restored", "count", len(entries), "wallets", len(ids))
+	return nil
+}
+
+// GetWallet returns the wallet of the investor, the zero value when the
+// investor never had an entry posted.
+func (r Repository) GetWallet(ctx context.Context, investorID int64) (model.Wallet, error) {
+	ctx, span := tracing.Start(ctx, "repository.GetWallet", tracing.Int64("investor_id", investorID))
+	defer span.End()
+
+	wallet, _, err := r.walletLedger.wallets.Get(ctx, strconv.FormatInt(investorID, 10))
+	if err != nil {
+		return model.Wallet{}, fmt.Errorf("failed to get wallet from memcache: %w", err)
+	}
+
+	return wallet, nil
+}
+
+func (r Repository) GetWallets(ctx context.Context) ([]model.Wallet, error) {
+	ctx, span := tracing.Start(ctx, "repository.GetWallets")
+	defer span.End()
+
+	ids, _, err := r.walletLedger.ids.Get(ctx, CacheKeyWalletIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet IDs from memcache: %w", err)
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = strconv.FormatInt(id, 10)
+	}
+	found, err := r.walletLedger.wallets.GetMany(ctx, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallets from memcache: %w", err)
+	}
+
+	wallets := make([]model.Wallet, 0, len(found))
+	for _, key := range keys {
+		if wallet, ok := found[key]; ok {
+			wallets = append(wallets, wallet)
+		}
+	}
+	return wallets, nil
+}
+
+func (r Repository) ListWalletEntries(ctx context.Context, query model.WalletEntryQuery) (model.WalletEntryPage, error) {
+	ctx, span := tracing.Start(ctx, "repository.ListWalletEntries", tracing.Int64("investor_id", query.InvestorID))
+	defer span.End()
+
+	// Candidate entry IDs, in ledger order
+	var ids []int64
+	if query.InvestorID != 0 {
+		stream, _, err := r.walletLedger.streams.Get(ctx, strconv.FormatInt(query.InvestorID, 10))
+		if err != nil {
+			return model.WalletEntryPage{}, fmt.Errorf("failed to get investor wallet entries from memcache: %w", err)
+		}
+		ids = stream
+	} else {
+		lastID, _, err := r.walletLedger.lastID.Get(ctx, CacheKeyLastWalletEntryID)
+		if err != nil {
+			return model.WalletEntryPage{}, fmt.Errorf("failed to get last wallet entry ID from memcache: %w", err)
+		}
+		for id := query.AfterID + 1; id <= lastID; id++ {
+			ids = append(ids, id)
+		}
+	}
+
+	page := model.WalletEntryPage{Entries: make([]model.WalletEntry, 0)}
+	for _, id := range ids {
+		if id <= query.AfterID {
+			continue
+		}
+
+		entry, exists, err := r.walletLedger.entries.Get(ctx, strconv.FormatInt(id, 10))
+		if err != nil {
+			return model.WalletEntryPage{}, fmt.Errorf("failed to get wallet entry %d from memcache: %w", id, err)
+		}
+		if !exists || (query.LoanID != 0 && entry.LoanID != query.LoanID) {
+			continue
+		}
+
+		// One more match than requested means there is a next page
+		if query.Limit > 0 && len(page.Entries) == query.Limit {
+			page.NextAfter = page.Entries[len(page.Entries)-1].EntryID
+			break
+		}
+		page.Entries = append(page.Entries, entry)
+	}
+
+	return page, nil
+}
@@ -0,0 +1,185 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+	"github.com/timotiusas11/amartha-assignment/internal/repository"
+)
+
+// AdminForceTransition moves a loan to any state, bypassing the state machine,
+// to repair loans stuck by a bug or an outside event. The reason is kept in
+// the audit log.
+func (u Usecase) AdminForceTransition(ctx context.Context, loanID int64, to model.StateEnum, reason string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.AdminForceTransition", tracing.Int64("loan_id", loanID), tracing.String("to_state", to.String()))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Keep failed attempts in the audit log as well
+	defer func() {
+		if err != nil {
+			u.recordRejection(ctx, "AdminForceTransition", loanID, err)
+		}
+	}()
+
+	// Only admins may override the state machine
+	principal, err := authorize(ctx, auth.PermissionAdmin)
+	if err != nil {
+		return err
+	}
+
+	// An override without a reason cannot be audited
+	if reason == "" {
+		return errors.New("reason is required")
+	}
+	if to.String() == "unknown" {
+		return fmt.Errorf("unknown loan state %d", to)
+	}
+
+	// Hold the loan until the change is stored, concurrent commands on it wait
+	defer u.locks.lock(loanID)()
+
+	// Retrieve the loan from the repository
+	loan, err := u.RepositoryInterface.GetLoan(ctx, loanID)
+	if err != nil {
+		return fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	// Return error if loan not found
+	if loan.LoanID == 0 {
+		return model.ErrLoanNotFound
+	}
+
+	if loan.State == to {
+		return fmt.Errorf("loan is already %s", to)
+	}
+	if slices.Contains(settledStates, loan.State) {
+		return fmt.Errorf("%w: loan is %s, its reserved funds are settled and cannot be reserved again", model.ErrInvalidState, loan.State)
+	}
+
+	// Take the settlement back when the override is not recorded after all
+	var posted postings
+	defer func() {
+		if err != nil {
+			u.reverse(ctx, posted)
+		}
+	}()
+
+	// Settle the wallets and the books as the regular cancel or disburse
+	// would, a disbursement withholding the origination fee as well
+	var disbursement *model.DisbursementInfo
+	if entryType, ok := settlementEntry(loan.State, to); ok {
+		if entryType == model.WalletEntryCapture {
+			var originationFee float64
+			originationFee, err = u.disburse(ctx, &posted, loan)
+			disbursement = &model.DisbursementInfo{DisbursementDate: time.Now(), OriginationFee: originationFee}
+		} else {
+			err = u.settle(ctx, &posted, loan, entryType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	previous := loan
+	fromState := loan.State
+	loan.State = to
+	if disbursement != nil {
+		loan.DisbursementInfo = *disbursement
+	}
+
+	// Update loan in the cache and record the override in the audit log
+	err = u.commitLoan(ctx, previous, loan, u.newEvent(ctx, loanID, model.EventLoanStateForced, principal.ActorID, model.LoanStateForcedPayload{
+		From:         fromState,
+		To:           to,
+		Reason:       reason,
+		Disbursement: disbursement,
+	}))
+	if err != nil {
+		return err
+	}
+
+	observeTransition(fromState.String(), loan.State)
+	if disbursement != nil {
+		loanAmounts.WithLabelValues("disbursed").Add(loan.PrincipalAmount)
+	}
+
+	slog.WarnContext(ctx, "loan state forced",
+		"loan_id", loanID,
+		"actor_id", principal.ActorID,
+		"from_state", fromState.String(),
+		"to_state", loan.State.String(),
+		"reason", reason,
+	)
+
+	return nil
+}
+
+// AdminRepublish sends the NSQ messages of a loan again, for consumers that
+// lost them. It returns the number of messages queued.
+func (u Usecase) AdminRepublish(ctx context.Context, loanID int64, channel string) (queued int, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.AdminRepublish", tracing.Int64("loan_id", loanID), tracing.String("channel", channel))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Only admins may replay messages
+	principal, err := authorize(ctx, auth.PermissionAdmin)
+	if err != nil {
+		return 0, err
+	}
+
+	// Retrieve the loan from the repository
+	loan, err := u.RepositoryInterface.GetLoan(ctx, loanID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	// Return error if loan not found
+	if loan.LoanID == 0 {
+		return 0, model.ErrLoanNotFound
+	}
+
+	var sent int
+	switch channel {
+	case repository.GenerateAgreementLetterChannel:
+		err = u.RepositoryInterface.GenerateAgreementLetter(ctx, loanID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to generate agreement letter: %w", err)
+		}
+		sent = 1
+	case repository.EmailAgreementLetterChannel:
+		// Investors are only emailed once the loan is fully funded
+		if loan.State != model.StateEnumInvested && loan.State != model.StateEnumDisbursed {
+			return 0, fmt.Errorf("%w: agreement letters are only sent for invested or disbursed loans", model.ErrInvalidState)
+		}
+		for _, inv := range loan.Investments {
+			err = u.RepositoryInterface.Publish(ctx, loan.LoanID, inv)
+			if err != nil {
+				return sent, fmt.Errorf("failed to publish agreement letter: %w", err)
+			}
+			sent++
+		}
+	default:
+		return 0, fmt.Errorf("unknown channel %q, use %s or %s", channel, repository.GenerateAgreementLetterChannel, repository.EmailAgreementLetterChannel)
+	}
+
+	slog.InfoContext(ctx, "messages republished",
+		"loan_id", loanID,
+		"actor_id", principal.ActorID,
+		"channel", channel,
+		"messages", sent,
+	)
+
+	return sent, nil
+}
@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+func TestAdminForceTransitionDisbursement(t *testing.T) {
+	const investorID = 7
+
+	tests := []struct {
+		name      string
+		invested  float64 // Of the principal of 1000, the loan is invested when it is all taken
+		disburse  func(t *testing.T, u Usecase, loanID int64)
+		wantFee   float64
+		wantState model.StateEnum
+	}{
+		{
+			name:     "regular disbursement",
+			invested: 1000,
+			disburse: disburseLoan,
+			wantFee:  10,
+		},
+		{
+			name:     "forced from invested",
+			invested: 1000,
+			disburse: forceDisbursed,
+			wantFee:  10,
+		},
+		{
+			name:     "forced from approved",
+			invested: 400,
+			disburse: forceDisbursed,
+			wantFee:  4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, repo := newTestUsecase(t)
+			admin := as(auth.RoleAdmin, testAdminID)
+			loanID := createLoan(t, u, 1000)
+			approveLoan(t, u, loanID)
+			deposit(t, u, investorID, tt.invested)
+			invest(t, u, loanID, investorID, tt.invested)
+
+			tt.disburse(t, u, loanID)
+
+			loan, err := repo.GetLoan(context.Background(), loanID)
+			if err != nil {
+				t.Fatalf("GetLoan() error = %v", err)
+			}
+			if loan.State != model.StateEnumDisbursed || loan.DisbursementInfo.OriginationFee != tt.wantFee || loan.DisbursementInfo.DisbursementDate.IsZero() {
+				t.Errorf("loan = %s with %+v, want disbursed with a fee of %v", loan.State, loan.DisbursementInfo, tt.wantFee)
+			}
+
+			// Replaying the events gives the same disbursement
+			replayed, err := u.AdminLoanAsOf(admin, loanID, time.Time{})
+			if err != nil {
+				t.Fatalf("AdminLoanAsOf() error = %v", err)
+			}
+			if !replayed.DisbursementInfo.DisbursementDate.Equal(loan.DisbursementInfo.DisbursementDate) || replayed.DisbursementInfo.OriginationFee != tt.wantFee {
+				t.Errorf("replayed disbursement = %+v, want %+v", replayed.DisbursementInfo, loan.DisbursementInfo)
+			}
+
+			wallet, err := u.GetWallet(as(auth.RoleInvestor, investorID), investorID)
+			if err != nil {
+				t.Fatalf("GetWallet() error = %v", err)
+			}
+			if wallet.Reserved != 0 || wallet.Invested != tt.invested {
+				t.Errorf("wallet = %+v, want %v invested and nothing reserved", wallet, tt.invested)
+			}
+
+			revenue, err := u.AdminRevenue(admin, model.RevenueQuery{})
+			if err != nil {
+				t.Fatalf("AdminRevenue() error = %v", err)
+			}
+			if revenue.Total != tt.wantFee {
+				t.Errorf("AdminRevenue() total = %v, want %v", revenue.Total, tt.wantFee)
+			}
+			checkBooks(t, u)
+		})
+	}
+}
+
+// forceDisbursed forces the loan to disbursed.
+func forceDisbursed(t *testing.T, u Usecase, loanID int64) {
+	t.Helper()
+	err := u.AdminForceTransition(as(auth.RoleAdmin, testAdminID), loanID, model.StateEnumDisbursed, "paid out by hand")
+	if err != nil {
+		t.Fatalf("AdminForceTransition() error = %v", err)
+	}
+}
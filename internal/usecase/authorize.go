@@ -0,0 +1,49 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+// ErrForbidden is returned when the caller in the context may not perform the action.
+var ErrForbidden = errors.New("forbidden")
+
+// authorize returns the caller in ctx when it holds permission. The delivery
+// layer checks the same permissions, this keeps the usecase safe on its own.
+func authorize(ctx context.Context, permission auth.Permission) (auth.Principal, error) {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return auth.Principal{}, fmt.Errorf("%w: no authenticated caller", ErrForbidden)
+	}
+	if !principal.Can(permission) {
+		return auth.Principal{}, fmt.Errorf("%w: missing %s permission", ErrForbidden, permission)
+	}
+	return principal, nil
+}
+
+// isStaff reports whether the principal works for the platform rather than
+// borrowing or investing.
+func isStaff(principal auth.Principal) bool {
+	return principal.Can(auth.PermissionApproveLoan) || principal.Can(auth.PermissionDisburseLoan) || principal.Can(auth.PermissionAdmin)
+}
+
+// authorizeOwner lets staff through for every loan, borrowers only for their own.
+func authorizeOwner(principal auth.Principal, loan model.Loan) error {
+	if !isStaff(principal) && loan.BorrowerID != principal.ActorID {
+		return fmt.Errorf("%w: loan belongs to another borrower", ErrForbidden)
+	}
+	return nil
+}
+
+// authorizeBorrower lets staff through for every borrower, borrowers only for
+// themselves.
+func authorizeBorrower(principal auth.Principal, borrowerID int64) error {
+	if !isStaff(principal) && borrowerID != principal.ActorID {
+		return fmt.Errorf("%w: not the borrower", ErrForbidden)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package usecase
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/archive"
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/ledger"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+// ErrStoreNotEmpty is returned when restoring into a store that holds data.
+var ErrStoreNotEmpty = errors.New("store is not empty")
+
+// AdminExport takes a snapshot of every loan, event, borrower and wallet entry.
+// Commands on loans wait for the export, so the loans match the events up to
+// LastEventID. It fails when events or journal entries are missing from the
+// store, restoring such an archive would lose them for good.
+func (u Usecase) AdminExport(ctx context.Context) (snapshot archive.Snapshot, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.AdminExport")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Only admins may export the loan book
+	principal, err := authorize(ctx, auth.PermissionAdmin)
+	if err != nil {
+		return archive.Snapshot{}, err
+	}
+
+	snapshot = archive.Snapshot{CreatedAt: time.Now().UTC()}
+
+	// Hold every loan, existing or new, until everything is read
+	defer u.locks.lockAll()()
+
+	// Call the repository's ListEvents method, without a limit
+	page, err := u.RepositoryInterface.ListEvents(ctx, model.EventQuery{Complete: true})
+	if err != nil {
+		return archive.Snapshot{}, fmt.Errorf("failed to list events from repository: %w", err)
+	}
+	snapshot.Events = page.Events
+	if len(page.Events) > 0 {
+		snapshot.LastEventID = page.Events[len(page.Events)-1].EventID
+	}
+
+	// Call the repository's GetLoans method
+	snapshot.Loans, err = u.RepositoryInterface.GetLoans(ctx)
+	if err != nil {
+		return archive.Snapshot{}, fmt.Errorf("failed to get loans from repository: %w", err)
+	}
+	slices.SortFunc(snapshot.Loans, func(a, b model.Loan) int {
+		return cmp.Compare(a.LoanID, b.LoanID)
+	})
+
+	// Call the repository's GetBorrowers method
+	snapshot.Borrowers, err = u.RepositoryInterface.GetBorrowers(ctx)
+	if err != nil {
+		return archive.Snapshot{}, fmt.Errorf("failed to get borrowers from repository: %w", err)
+	}
+	slices.SortFunc(snapshot.Borrowers, func(a, b model.Borrower) int {
+		return cmp.Compare(a.BorrowerID, b.BorrowerID)
+	})
+
+	// Call the repository's ListWalletEntries method, without a limit
+	entries, err := u.RepositoryInterface.ListWalletEntries(ctx, model.WalletEntryQuery{})
+	if err != nil {
+		return archive.Snapshot{}, fmt.Errorf("failed to list wallet entries from repository: %w", err)
+	}
+	snapshot.WalletEntries = entries.Entries
+
+	// Call the repository's ListJournalEntries method, without a limit
+	journal, err := u.RepositoryInterface.ListJournalEntries(ctx, ledger.EntryQuery{Complete: true})
+	if err != nil {
+		return archive.Snapshot{}, fmt.Errorf("failed to list journal entries from repository: %w", err)
+	}
+	snapshot.Journal = journal.Entries
+
+	slog.InfoContext(ctx, "loan book exported",
+		"actor_id", principal.ActorID,
+		"loans", len(snapshot.Loans),
+		"events", len(snapshot.Events),
+		"borrowers", len(snapshot.Borrowers),
+		"wallet_entries", len(snapshot.WalletEntries),
+		"journal_entries", len(snapshot.Journal),
+		"last_event_id", snapshot.LastEventID,
+	)
+
+	return snapshot, nil
+}
+
+// AdminRestore loads a snapshot into an empty store, keeping event IDs. Every
+// step overwrites what it restores, so a restore that failed part way is
+// resumed by retrying it with the same archive.
+func (u Usecase) AdminRestore(ctx context.Context, snapshot archive.Snapshot) (report model.RestoreReport, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.AdminRestore")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Only admins may restore the loan book
+	principal, err := authorize(ctx, auth.PermissionAdmin)
+	if err != nil {
+		return model.RestoreReport{}, err
+	}
+
+	// Nothing may change the store while it is restored
+	defer u.locks.lockAll()()
+
+	// A restore that failed part way left data behind, only the same archive
+	// may go on with it
+	inProgress, err := u.RepositoryInterface.GetRestoreInProgress(ctx)
+	if err != nil {
+		return model.RestoreReport{}, fmt.Errorf("failed to get restore in progress from repository: %w", err)
+	}
+	resumed := inProgress != "" && inProgress == snapshot.Checksum
+	if inProgress != "" && !resumed {
+		return model.RestoreReport{}, fmt.Errorf("%w: the restore of another archive did not finish, retry it with that archive", ErrStoreNotEmpty)
+	}
+	if !resumed {
+		err = u.requireEmptyStore(ctx)
+		if err != nil {
+			return model.RestoreReport{}, err
+		}
+	}
+	err = u.RepositoryInterface.SetRestoreInProgress(ctx, snapshot.Checksum)
+	if err != nil {
+		return model.RestoreReport{}, fmt.Errorf("failed to start restore: %w", err)
+	}
+
+	// Events first, a partial restore then shows up as missing loans in a rebuild
+	err = u.RepositoryInterface.RestoreEvents(ctx, snapshot.Events)
+	if err != nil {
+		return model.RestoreReport{}, fmt.Errorf("failed to restore events: %w", err)
+	}
+	err = u.RepositoryInterface.ReplaceLoans(ctx, snapshot.Loans)
+	if err != nil {
+		return model.RestoreReport{}, fmt.Errorf("failed to restore loans: %w", err)
+	}
+	resetLoanAmounts(snapshot.Loans)
+	err = u.RepositoryInterface.RestoreBorrowers(ctx, snapshot.Borrowers)
+	if err != nil {
+		return model.RestoreReport{}, fmt.Errorf("failed to restore borrowers: %w", err)
+	}
+	err = u.RepositoryInterface.RestoreWalletEntries(ctx, snapshot.WalletEntries)
+	if err != nil {
+		return model.RestoreReport{}, fmt.Errorf("failed to restore wallet entries: %w", err)
+	}
+	err = u.RepositoryInterface.RestoreJournalEntries(ctx, snapshot.Journal)
+	if err != nil {
+		return model.RestoreReport{}, fmt.Errorf("failed to restore journal entries: %w", err)
+	}
+	err = u.RepositoryInterface.SetRestoreInProgress(ctx, "")
+	if err != nil {
+		return model.RestoreReport{}, fmt.Errorf("failed to finish restore: %w", err)
+	}
+
+	report = model.RestoreReport{
+		Loans:          len(snapshot.Loans),
+		Events:         len(snapshot.Events),
+		Borrowers:      len(snapshot.Borrowers),
+		WalletEntries:  len(snapshot.WalletEntries),
+		JournalEntries: len(snapshot.Journal),
+		LastEventID:    snapshot.LastEventID,
+		Resumed:        resumed,
+	}
+
+	slog.InfoContext(ctx, "loan book restored",
+		"actor_id", principal.ActorID,
+		"loans", report.Loans,
+		"events", report.Events,
+		"borrowers", report.Borrowers,
+		"wallet_entries", report.WalletEntries,
+		"journal_entries", report.JournalEntries,
+		"last_event_id", report.LastEventID,
+		"archive_created_at", snapshot.CreatedAt,
+		"resumed", report.Resumed,
+	)
+
+	return report, nil
+}
+
+// requireEmptyStore fails when the store holds any data, restoring next to it
+// would mix two histories.
+func (u Usecase) requireEmptyStore(ctx context.Context) error {
+	loans, err := u.RepositoryInterface.GetLoans(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get loans from repository: %w", err)
+	}
+	events, err := u.RepositoryInterface.ListEvents(ctx, model.EventQuery{Limit: 1})
+	if err != nil {
+		return fmt.Errorf("failed to list events from repository: %w", err)
+	}
+	borrowers, err := u.RepositoryInterface.GetBorrowers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get borrowers from repository: %w", err)
+	}
+	wallets, err := u.RepositoryInterface.GetWallets(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get wallets from repository: %w", err)
+	}
+	journal, err := u.RepositoryInterface.ListJournalEntries(ctx, ledger.EntryQuery{Limit: 1})
+	if err != nil {
+		return fmt.Errorf("failed to list journal entries from repository: %w", err)
+	}
+	if len(loans) > 0 || len(events.Events) > 0 || len(borrowers) > 0 || len(wallets) > 0 || len(journal.Entries) > 0 {
+		return fmt.Errorf("%w: found %d loans, %d borrowers, %d wallets, at least %d journal entries and at least %d events",
+			ErrStoreNotEmpty, len(loans), len(borrowers), len(wallets), len(journal.Entries), len(events.Events))
+	}
+
+	return nil
+}
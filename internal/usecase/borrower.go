@@ -0,0 +1,497 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+// borrowerDocumentTypes are the content types accepted for KYC documents,
+// detected from the content.
+var borrowerDocumentTypes = map[model.DocumentKind][]string{
+	model.DocumentIdentityCard: {"application/pdf", "image/jpeg", "image/png"},
+	model.DocumentSelfie:       {"image/jpeg", "image/png"},
+}
+
+// maxBorrowerDocumentsPerKind bounds the documents listed on a borrower, which
+// is stored as a single cache entry. Older uploads of a kind drop off the list.
+const maxBorrowerDocumentsPerKind = 5
+
+// activeStates are the states in which a loan still depends on its borrower.
+var activeStates = []model.StateEnum{model.StateEnumProposed, model.StateEnumApproved, model.StateEnumInvested}
+
+func (u Usecase) RegisterBorrower(ctx context.Context, borrowerID int64, profile model.BorrowerProfile) (borrower model.Borrower, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.RegisterBorrower", tracing.Int64("borrower_id", borrowerID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Borrowers register themselves, admins may register anyone
+	principal, err := authorize(ctx, auth.PermissionWriteBorrower)
+	if err != nil {
+		return model.Borrower{}, err
+	}
+	if borrowerID == 0 {
+		borrowerID = principal.ActorID
+	}
+	if borrowerID != principal.ActorID && !principal.Can(auth.PermissionAdmin) {
+		return model.Borrower{}, fmt.Errorf("%w: borrowers may only register themselves", ErrForbidden)
+	}
+
+	// Validate the profile
+	profile = normalizeBorrowerProfile(profile)
+	err = validateBorrowerProfile(profile)
+	if err != nil {
+		return model.Borrower{}, err
+	}
+
+	// Create a new borrower, waiting for a KYC review
+	now := time.Now()
+	borrower = model.Borrower{
+		BorrowerID:     borrowerID,
+		Name:           profile.Name,
+		IdentityNumber: profile.IdentityNumber,
+		DateOfBirth:    profile.DateOfBirth,
+		Contact:        profile.Contact,
+		KYC:            model.KYC{Status: model.KYCStatusPending},
+		Documents:      []model.Document{},
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	// Call the repository's InsertBorrower method
+	err = u.RepositoryInterface.InsertBorrower(ctx, borrower)
+	if errors.Is(err, model.ErrBorrowerExists) {
+		return model.Borrower{}, err
+	}
+	if err != nil {
+		return model.Borrower{}, fmt.Errorf("failed to insert borrower: %w", err)
+	}
+
+	slog.InfoContext(ctx, "borrower registered",
+		"borrower_id", borrowerID,
+		"actor_id", principal.ActorID,
+	)
+
+	return borrower, nil
+}
+
+func (u Usecase) GetBorrower(ctx context.Context, borrowerID int64) (borrower model.Borrower, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.GetBorrower", tracing.Int64("borrower_id", borrowerID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Staff see every borrower, borrowers only themselves
+	principal, err := authorize(ctx, auth.PermissionReadBorrower)
+	if err != nil {
+		return model.Borrower{}, err
+	}
+	err = authorizeBorrower(principal, borrowerID)
+	if err != nil {
+		return model.Borrower{}, err
+	}
+
+	return u.getBorrower(ctx, borrowerID)
+}
+
+func (u Usecase) GetBorrowers(ctx context.Context) (borrowers []model.Borrower, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.GetBorrowers")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Only staff may list borrowers
+	principal, err := authorize(ctx, auth.PermissionReadBorrower)
+	if err != nil {
+		return nil, err
+	}
+	if !isStaff(principal) {
+		return nil, fmt.Errorf("%w: only staff may list borrowers", ErrForbidden)
+	}
+
+	// Call the repository's GetBorrowers method
+	borrowers, err = u.RepositoryInterface.GetBorrowers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get borrowers from repository: %w", err)
+	}
+
+	return borrowers, nil
+}
+
+func (u Usecase) UpdateBorrower(ctx context.Context, borrowerID int64, profile model.BorrowerProfile) (borrower model.Borrower, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.UpdateBorrower", tracing.Int64("borrower_id", borrowerID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Borrowers update themselves, admins may update anyone
+	principal, err := authorize(ctx, auth.PermissionWriteBorrower)
+	if err != nil {
+		return model.Borrower{}, err
+	}
+	if borrowerID != principal.ActorID && !principal.Can(auth.PermissionAdmin) {
+		return model.Borrower{}, fmt.Errorf("%w: not the borrower", ErrForbidden)
+	}
+
+	// Validate the profile
+	profile = normalizeBorrowerProfile(profile)
+	err = validateBorrowerProfile(profile)
+	if err != nil {
+		return model.Borrower{}, err
+	}
+
+	// Hold the borrower until the change is stored
+	defer u.borrowerLocks.lock(borrowerID)()
+
+	borrower, err = u.getBorrower(ctx, borrowerID)
+	if err != nil {
+		return model.Borrower{}, err
+	}
+
+	// A verified identity that changes must be reviewed again
+	if borrower.IdentityChanged(profile) && borrower.KYC.Status != model.KYCStatusPending {
+		borrower.KYC = model.KYC{Status: model.KYCStatusPending, Reason: "identity changed since the last review"}
+	}
+	borrower.Name = profile.Name
+	borrower.IdentityNumber = profile.IdentityNumber
+	borrower.DateOfBirth = profile.DateOfBirth
+	borrower.Contact = profile.Contact
+	borrower.UpdatedAt = time.Now()
+
+	// Call the repository's UpdateBorrower method
+	err = u.RepositoryInterface.UpdateBorrower(ctx, borrower)
+	if err != nil {
+		return model.Borrower{}, fmt.Errorf("failed to update borrower: %w", err)
+	}
+
+	slog.InfoContext(ctx, "borrower updated",
+		"borrower_id", borrowerID,
+		"actor_id", principal.ActorID,
+		"kyc_status", borrower.KYC.Status,
+	)
+
+	return borrower, nil
+}
+
+func (u Usecase) DeleteBorrower(ctx context.Context, borrowerID int64) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.DeleteBorrower", tracing.Int64("borrower_id", borrowerID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Only admins may delete borrowers
+	principal, err := authorize(ctx, auth.PermissionAdmin)
+	if err != nil {
+		return err
+	}
+
+	// Hold the borrower until it is gone
+	defer u.borrowerLocks.lock(borrowerID)()
+
+	_, err = u.getBorrower(ctx, borrowerID)
+	if err != nil {
+		return err
+	}
+
+	// Loans still running need their borrower, finished ones keep the ID only
+	page, err := u.RepositoryInterface.QueryLoans(ctx, model.LoanQuery{BorrowerID: borrowerID, States: activeStates, Limit: 1})
+	if err != nil {
+		return fmt.Errorf("failed to query loans from repository: %w", err)
+	}
+	if page.TotalCount > 0 {
+		return fmt.Errorf("borrower has %d active loans", page.TotalCount)
+	}
+
+	// Call the repository's DeleteBorrower method
+	err = u.RepositoryInterface.DeleteBorrower(ctx, borrowerID)
+	if err != nil {
+		return fmt.Errorf("failed to delete borrower: %w", err)
+	}
+
+	slog.InfoContext(ctx, "borrower deleted",
+		"borrower_id", borrowerID,
+		"actor_id", principal.ActorID,
+	)
+
+	return nil
+}
+
+// ReviewKYC records the outcome of a review of the borrower's identity.
+// Verifying needs an identity card, rejecting needs a reason.
+func (u Usecase) ReviewKYC(ctx context.Context, borrowerID int64, status model.KYCStatus, reason string) (borrower model.Borrower, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.ReviewKYC", tracing.Int64("borrower_id", borrowerID), tracing.String("status", string(status)))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Field validators and admins review borrowers, never themselves
+	principal, err := authorize(ctx, auth.PermissionReviewKYC)
+	if err != nil {
+		return model.Borrower{}, err
+	}
+	if borrowerID == principal.ActorID {
+		return model.Borrower{}, fmt.Errorf("%w: borrowers may not review themselves", ErrForbidden)
+	}
+
+	reason = strings.TrimSpace(reason)
+	switch status {
+	case model.KYCStatusVerified:
+	case model.KYCStatusRejected:
+		if reason == "" {
+			return model.Borrower{}, errors.New("a rejection needs a reason")
+		}
+	default:
+		return model.Borrower{}, fmt.Errorf("status must be %s or %s", model.KYCStatusVerified, model.KYCStatusRejected)
+	}
+
+	// Hold the borrower until the change is stored
+	defer u.borrowerLocks.lock(borrowerID)()
+
+	borrower, err = u.getBorrower(ctx, borrowerID)
+	if err != nil {
+		return model.Borrower{}, err
+	}
+
+	if status == model.KYCStatusVerified && !slices.ContainsFunc(borrower.Documents, func(document model.Document) bool {
+		return document.Kind == model.DocumentIdentityCard
+	}) {
+		return model.Borrower{}, fmt.Errorf("%s must be uploaded before verifying", model.DocumentIdentityCard)
+	}
+
+	// Update the borrower's KYC status
+	borrower.KYC = model.KYC{
+		Status:     status,
+		Reason:     reason,
+		ReviewedBy: principal.ActorID,
+		ReviewedAt: time.Now(),
+	}
+	borrower.UpdatedAt = borrower.KYC.ReviewedAt
+
+	// Call the repository's UpdateBorrower method
+	err = u.RepositoryInterface.UpdateBorrower(ctx, borrower)
+	if err != nil {
+		return model.Borrower{}, fmt.Errorf("failed to update borrower: %w", err)
+	}
+
+	slog.InfoContext(ctx, "borrower reviewed",
+		"borrower_id", borrowerID,
+		"actor_id", principal.ActorID,
+		"kyc_status", status,
+	)
+
+	return borrower, nil
+}
+
+// UploadBorrowerDocument stores a KYC document of the borrower. Uploading the
+// same content again replaces the earlier upload, a different identity card
+// sends a verified borrower back to review.
+func (u Usecase) UploadBorrowerDocument(ctx context.Context, borrowerID int64, kind model.DocumentKind, filename string, content io.Reader) (document model.Document, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.UploadBorrowerDocument", tracing.Int64("borrower_id", borrowerID), tracing.String("kind", string(kind)))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// The borrower, or a field validator during the visit, uploads the documents
+	principal, err := authorize(ctx, auth.PermissionUploadDocument)
+	if err != nil {
+		return model.Document{}, err
+	}
+	if borrowerID != principal.ActorID && !principal.Can(auth.PermissionReviewKYC) {
+		return model.Document{}, fmt.Errorf("%w: not the borrower", ErrForbidden)
+	}
+
+	contentTypes, ok := borrowerDocumentTypes[kind]
+	if !ok {
+		return model.Document{}, fmt.Errorf("unknown document kind %q", kind)
+	}
+
+	data, document, err := u.readDocument(content, contentTypes)
+	if err != nil {
+		return model.Document{}, err
+	}
+	document.BorrowerID = borrowerID
+	document.Kind = kind
+	document.Filename = filename
+	document.UploadedBy = principal.ActorID
+	document.URL = model.BorrowerDocumentURL(borrowerID, document.SHA256)
+
+	// Hold the borrower until the change is stored
+	defer u.borrowerLocks.lock(borrowerID)()
+
+	borrower, err := u.getBorrower(ctx, borrowerID)
+	if err != nil {
+		return model.Document{}, err
+	}
+
+	// Store the content before the borrower, so a listed document can always be read
+	err = u.RepositoryInterface.PutDocument(ctx, document, data)
+	if err != nil {
+		return model.Document{}, fmt.Errorf("failed to store document: %w", err)
+	}
+
+	uploaded := len(borrower.Documents)
+	borrower.Documents = slices.DeleteFunc(borrower.Documents, func(existing model.Document) bool {
+		return existing.SHA256 == document.SHA256 && existing.Kind == document.Kind
+	})
+	replaced := len(borrower.Documents) < uploaded
+	borrower.Documents = trimDocuments(append(borrower.Documents, document), kind, maxBorrowerDocumentsPerKind)
+	if kind == model.DocumentIdentityCard && !replaced && borrower.KYC.Status == model.KYCStatusVerified {
+		borrower.KYC = model.KYC{Status: model.KYCStatusPending, Reason: "identity card replaced since the last review"}
+	}
+	borrower.UpdatedAt = document.UploadedAt
+
+	// Call the repository's UpdateBorrower method
+	err = u.RepositoryInterface.UpdateBorrower(ctx, borrower)
+	if err != nil {
+		return model.Document{}, fmt.Errorf("failed to update borrower: %w", err)
+	}
+
+	slog.InfoContext(ctx, "borrower document uploaded",
+		"borrower_id", borrowerID,
+		"actor_id", principal.ActorID,
+		"kind", kind,
+		"sha256", document.SHA256,
+		"size", document.Size,
+	)
+
+	return document, nil
+}
+
+// trimDocuments drops the oldest documents of kind until at most limit are
+// left. Documents are listed in upload order.
+func trimDocuments(documents []model.Document, kind model.DocumentKind, limit int) []model.Document {
+	excess := -limit
+	for _, document := range documents {
+		if document.Kind == kind {
+			excess++
+		}
+	}
+	return slices.DeleteFunc(documents, func(document model.Document) bool {
+		if excess > 0 && document.Kind == kind {
+			excess--
+			return true
+		}
+		return false
+	})
+}
+
+// OpenBorrowerDocument returns a KYC document of the borrower and its content,
+// which the caller must close.
+func (u Usecase) OpenBorrowerDocument(ctx context.Context, borrowerID int64, sha256 string) (document model.Document, content io.ReadCloser, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.OpenBorrowerDocument", tracing.Int64("borrower_id", borrowerID), tracing.String("sha256", sha256))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Staff see the documents of every borrower, borrowers only their own
+	principal, err := authorize(ctx, auth.PermissionReadBorrower)
+	if err != nil {
+		return model.Document{}, nil, err
+	}
+	err = authorizeBorrower(principal, borrowerID)
+	if err != nil {
+		return model.Document{}, nil, err
+	}
+
+	borrower, err := u.getBorrower(ctx, borrowerID)
+	if err != nil {
+		return model.Document{}, nil, err
+	}
+	index := slices.IndexFunc(borrower.Documents, func(document model.Document) bool {
+		return document.SHA256 == sha256
+	})
+	if index < 0 {
+		return model.Document{}, nil, errors.New("document not found")
+	}
+
+	content, err = u.RepositoryInterface.OpenDocument(ctx, sha256)
+	if err != nil {
+		return model.Document{}, nil, fmt.Errorf("failed to open document: %w", err)
+	}
+	return borrower.Documents[index], content, nil
+}
+
+// requireEligibleBorrower checks that the borrower is registered and verified.
+func (u Usecase) requireEligibleBorrower(ctx context.Context, borrowerID int64) error {
+	borrower, err := u.RepositoryInterface.GetBorrower(ctx, borrowerID)
+	if err != nil {
+		return fmt.Errorf("failed to get borrower: %w", err)
+	}
+	if borrower.BorrowerID == 0 {
+		return fmt.Errorf("%w: borrower %d is not registered", model.ErrBorrowerNotEligible, borrowerID)
+	}
+	if borrower.KYC.Status != model.KYCStatusVerified {
+		return fmt.Errorf("%w: KYC of borrower %d is %s", model.ErrBorrowerNotEligible, borrowerID, borrower.KYC.Status)
+	}
+	return nil
+}
+
+// getBorrower retrieves a borrower that must exist.
+func (u Usecase) getBorrower(ctx context.Context, borrowerID int64) (model.Borrower, error) {
+	// Call the repository's GetBorrower method
+	borrower, err := u.RepositoryInterface.GetBorrower(ctx, borrowerID)
+	if err != nil {
+		return model.Borrower{}, fmt.Errorf("failed to get borrower from repository: %w", err)
+	}
+
+	// Return error if borrower not found
+	if borrower.BorrowerID == 0 {
+		return model.Borrower{}, errors.New("borrower not found")
+	}
+
+	return borrower, nil
+}
+
+func normalizeBorrowerProfile(profile model.BorrowerProfile) model.BorrowerProfile {
+	profile.Name = strings.TrimSpace(profile.Name)
+	profile.IdentityNumber = strings.TrimSpace(profile.IdentityNumber)
+	profile.DateOfBirth = strings.TrimSpace(profile.DateOfBirth)
+	profile.Contact.Email = strings.TrimSpace(profile.Contact.Email)
+	profile.Contact.Phone = strings.TrimSpace(profile.Contact.Phone)
+	profile.Contact.Address = strings.TrimSpace(profile.Contact.Address)
+	return profile
+}
+
+func validateBorrowerProfile(profile model.BorrowerProfile) error {
+	if profile.Name == "" {
+		return errors.New("name is empty")
+	}
+	if profile.IdentityNumber == "" {
+		return errors.New("identity number is empty")
+	}
+	dateOfBirth, err := time.Parse(time.DateOnly, profile.DateOfBirth)
+	if err != nil {
+		return errors.New("date of birth must be a YYYY-MM-DD date")
+	}
+	if !dateOfBirth.Before(time.Now()) {
+		return errors.New("date of birth must be in the past")
+	}
+	if profile.Contact.Email == "" && profile.Contact.Phone == "" {
+		return errors.New("contact needs an email or a phone number")
+	}
+	if profile.Contact.Email != "" {
+		if _, err := mail.ParseAddress(profile.Contact.Email); err != nil {
+			return errors.New("contact email is invalid")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+// documentRules are who may upload each kind of document, in which state of
+// the loan, and which content types are accepted. Content types are detected
+// from the content, the type claimed by the uploader is ignored.
+var documentRules = map[model.DocumentKind]struct {
+	permission   auth.Permission
+	state        model.StateEnum
+	contentTypes []string
+}{
+	model.DocumentPictureProof:          {auth.PermissionApproveLoan, model.StateEnumProposed, []string{"image/jpeg", "image/png"}},
+	model.DocumentSignedAgreementLetter: {auth.PermissionDisburseLoan, model.StateEnumInvested, []string{"application/pdf", "image/jpeg", "image/png"}},
+}
+
+func (u Usecase) UploadDocument(ctx context.Context, loanID int64, kind model.DocumentKind, filename string, content io.Reader) (document model.Document, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.UploadDocument", tracing.Int64("loan_id", loanID), tracing.String("kind", string(kind)))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Keep failed attempts in the audit log as well
+	defer func() {
+		if err != nil {
+			u.recordRejection(ctx, "UploadDocument", loanID, err)
+		}
+	}()
+
+	rules, ok := documentRules[kind]
+	if !ok {
+		return model.Document{}, fmt.Errorf("unknown document kind %q", kind)
+	}
+
+	// Picture proofs come from field validators, signed letters from field officers
+	principal, err := authorize(ctx, rules.permission)
+	if err != nil {
+		return model.Document{}, err
+	}
+
+	// Retrieve the loan from the repository
+	loan, err := u.RepositoryInterface.GetLoan(ctx, loanID)
+	if err != nil {
+		return model.Document{}, fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	// Return error if loan not found
+	if loan.LoanID == 0 {
+		return model.Document{}, model.ErrLoanNotFound
+	}
+
+	// The document is only useful for the next step of the loan
+	if loan.State != rules.state {
+		return model.Document{}, fmt.Errorf("%s can only be uploaded while the loan is %s", kind, rules.state)
+	}
+
+	data, document, err := u.readDocument(content, rules.contentTypes)
+	if err != nil {
+		return model.Document{}, err
+	}
+	document.LoanID = loanID
+	document.Kind = kind
+	document.Filename = filename
+	document.UploadedBy = principal.ActorID
+	document.URL = model.DocumentURL(loanID, document.SHA256)
+
+	// Store the content before the event, so a listed document can always be read
+	err = u.RepositoryInterface.PutDocument(ctx, document, data)
+	if err != nil {
+		return model.Document{}, fmt.Errorf("failed to store document: %w", err)
+	}
+
+	// Record the upload in the audit log, it is also where documents are listed from
+	err = u.appendEvents(ctx, u.newEvent(ctx, loanID, model.EventDocumentUploaded, principal.ActorID, document))
+	if err != nil {
+		return model.Document{}, err
+	}
+
+	slog.InfoContext(ctx, "document uploaded",
+		"loan_id", loanID,
+		"actor_id", principal.ActorID,
+		"kind", kind,
+		"sha256", document.SHA256,
+		"size", document.Size,
+	)
+
+	return document, nil
+}
+
+// readDocument reads an upload within the size limit and returns its content
+// with the detected content type, size, hash and upload time filled in.
+func (u Usecase) readDocument(content io.Reader, contentTypes []string) ([]byte, model.Document, error) {
+	// Read one byte past the limit to tell a full file from a truncated one
+	data, err := io.ReadAll(io.LimitReader(content, u.limits.MaxDocumentSize+1))
+	if err != nil {
+		return nil, model.Document{}, fmt.Errorf("failed to read document: %w", err)
+	}
+	if int64(len(data)) > u.limits.MaxDocumentSize {
+		return nil, model.Document{}, fmt.Errorf("%w: at most %d bytes", model.ErrDocumentTooLarge, u.limits.MaxDocumentSize)
+	}
+	if len(data) == 0 {
+		return nil, model.Document{}, errors.New("document is empty")
+	}
+
+	contentType := http.DetectContentType(data)
+	if !slices.Contains(contentTypes, contentType) {
+		return nil, model.Document{}, fmt.Errorf("%w: %s is not one of %v", model.ErrUnsupportedDocumentType, contentType, contentTypes)
+	}
+
+	sum := sha256.Sum256(data)
+	return data, model.Document{
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		UploadedAt:  time.Now(),
+	}, nil
+}
+
+func (u Usecase) GetLoanDocuments(ctx context.Context, loanID int64) (documents []model.Document, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.GetLoanDocuments", tracing.Int64("loan_id", loanID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if err := u.authorizeLoanDocuments(ctx, loanID); err != nil {
+		return nil, err
+	}
+
+	return u.loanDocuments(ctx, loanID)
+}
+
+// OpenLoanDocument returns a document of the loan and its content, which the
+// caller must close.
+func (u Usecase) OpenLoanDocument(ctx context.Context, loanID int64, sha256 string) (document model.Document, content io.ReadCloser, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.OpenLoanDocument", tracing.Int64("loan_id", loanID), tracing.String("sha256", sha256))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if err := u.authorizeLoanDocuments(ctx, loanID); err != nil {
+		return model.Document{}, nil, err
+	}
+
+	document, err = u.findDocument(ctx, loanID, sha256)
+	if err != nil {
+		return model.Document{}, nil, err
+	}
+
+	content, err = u.RepositoryInterface.OpenDocument(ctx, sha256)
+	if err != nil {
+		return model.Document{}, nil, fmt.Errorf("failed to open document: %w", err)
+	}
+	return document, content, nil
+}
+
+// authorizeLoanDocuments lets staff see the documents of every loan, borrowers
+// only of their own.
+func (u Usecase) authorizeLoanDocuments(ctx context.Context, loanID int64) error {
+	principal, err := authorize(ctx, auth.PermissionReadDocuments)
+	if err != nil {
+		return err
+	}
+
+	// Retrieve the loan from the repository
+	loan, err := u.RepositoryInterface.GetLoan(ctx, loanID)
+	if err != nil {
+		return fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	// Return error if loan not found
+	if loan.LoanID == 0 {
+		return model.ErrLoanNotFound
+	}
+
+	return authorizeOwner(principal, loan)
+}
+
+// loanDocuments reads the documents of a loan from its DocumentUploaded events.
+func (u Usecase) loanDocuments(ctx context.Context, loanID int64) ([]model.Document, error) {
+	page, err := u.RepositoryInterface.ListEvents(ctx, model.EventQuery{
+		LoanID: loanID,
+		Types:  []model.EventType{model.EventDocumentUploaded},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list document events: %w", err)
+	}
+
+	documents := make([]model.Document, 0, len(page.Events))
+	for _, event := range page.Events {
+		var document model.Document
+		if err := json.Unmarshal(event.Payload, &document); err != nil {
+			return nil, fmt.Errorf("failed to decode payload of event %d: %w", event.EventID, err)
+		}
+		documents = append(documents, document)
+	}
+	return documents, nil
+}
+
+// findDocument returns the latest upload of the content to the loan.
+func (u Usecase) findDocument(ctx context.Context, loanID int64, sha256 string) (model.Document, error) {
+	documents, err := u.loanDocuments(ctx, loanID)
+	if err != nil {
+		return model.Document{}, err
+	}
+	for i := len(documents) - 1; i >= 0; i-- {
+		if documents[i].SHA256 == sha256 {
+			return documents[i], nil
+		}
+	}
+	return model.Document{}, errors.New("document not found")
+}
+
+// requireDocument checks that url is the internal URL of a document of kind
+// uploaded to the loan.
+func (u Usecase) requireDocument(ctx context.Context, loanID int64, kind model.DocumentKind, url string) error {
+	urlLoanID, sha256, ok := model.ParseDocumentURL(url)
+	if !ok {
+		return fmt.Errorf("%s must be the URL returned by the document upload, got %q", kind, url)
+	}
+	if urlLoanID != loanID {
+		return fmt.Errorf("%s was uploaded for loan %d", kind, urlLoanID)
+	}
+
+	documents, err := u.loanDocuments(ctx, loanID)
+	if err != nil {
+		return err
+	}
+	for _, document := range documents {
+		if document.SHA256 == sha256 && document.Kind == kind {
+			return nil
+		}
+	}
+	return fmt.Errorf("no %s with SHA-256 %s was uploaded for the loan", kind, sha256)
+}
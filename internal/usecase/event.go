@@ -0,0 +1,170 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/common/logger"
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+const (
+	DefaultEventPageSize = 100
+	MaxEventPageSize     = 1000
+)
+
+func (u Usecase) newEvent(ctx context.Context, loanID int64, eventType model.EventType, actorID int64, payload interface{}) model.Event {
+	// Payloads are plain structs, they always marshal
+	data, _ := json.Marshal(payload)
+	return model.Event{
+		LoanID:     loanID,
+		Type:       eventType,
+		ActorID:    actorID,
+		OccurredAt: time.Now(),
+		RequestID:  logger.RequestID(ctx),
+		Payload:    data,
+	}
+}
+
+// appendEvents writes events to the audit log.
+func (u Usecase) appendEvents(ctx context.Context, events ...model.Event) error {
+	_, err := u.RepositoryInterface.AppendEvents(ctx, events...)
+	if err != nil {
+		return fmt.Errorf("failed to append events: %w", err)
+	}
+	return nil
+}
+
+// commitLoan stores the changed loan, then records the events of the change.
+// The events come last so that a failed write never leaves an event behind
+// that the loan does not reflect: when they cannot be appended, the previous
+// snapshot is put back and the command fails.
+func (u Usecase) commitLoan(ctx context.Context, previous, loan model.Loan, events ...model.Event) error {
+	err := u.RepositoryInterface.UpdateLoan(ctx, loan)
+	if err != nil {
+		return fmt.Errorf("failed to update loan: %w", err)
+	}
+
+	err = u.appendEvents(ctx, events...)
+	if err != nil {
+		// The caller already fails, a broken snapshot must not hide why
+		restoreErr := u.RepositoryInterface.UpdateLoan(context.WithoutCancel(ctx), previous)
+		if restoreErr != nil {
+			slog.ErrorContext(ctx, "failed to restore loan after its events were lost", "loan_id", loan.LoanID, "error", restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// recordRejection logs a failed attempt as a CommandRejected event. Abandoned
+// requests are not attempts worth keeping and are skipped.
+func (u Usecase) recordRejection(ctx context.Context, command string, loanID int64, cause error) {
+	if errors.Is(cause, context.Canceled) || errors.Is(cause, context.DeadlineExceeded) {
+		return
+	}
+
+	payload := model.CommandRejectedPayload{
+		Command: command,
+		Reason:  cause.Error(),
+	}
+
+	// Replays expect the events of a loan to start with LoanCreated, so a
+	// command on a loan that was never created is kept out of the loan events
+	if loanID != 0 && !u.loanHasEvents(ctx, loanID) {
+		payload.LoanID = loanID
+		loanID = 0
+	}
+
+	principal, _ := auth.PrincipalFromContext(ctx)
+	event := u.newEvent(ctx, loanID, model.EventCommandRejected, principal.ActorID, payload)
+
+	// The caller already fails with cause, a broken audit log must not hide it
+	_, err := u.RepositoryInterface.AppendEvents(context.WithoutCancel(ctx), event)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to record rejected command", "command", command, "loan_id", loanID, "error", err)
+	}
+}
+
+// loanHasEvents reports whether the event log holds events of the loan. A
+// failed lookup counts as none.
+func (u Usecase) loanHasEvents(ctx context.Context, loanID int64) bool {
+	page, err := u.RepositoryInterface.ListEvents(context.WithoutCancel(ctx), model.EventQuery{LoanID: loanID, Limit: 1})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to list loan events", "loan_id", loanID, "error", err)
+		return false
+	}
+	return len(page.Events) > 0
+}
+
+func (u Usecase) GetLoanEvents(ctx context.Context, loanID int64, query model.EventQuery) (page model.EventPage, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.GetLoanEvents", tracing.Int64("loan_id", loanID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Staff and borrowers may see the history of a loan
+	principal, err := authorize(ctx, auth.PermissionReadEvents)
+	if err != nil {
+		return model.EventPage{}, err
+	}
+
+	// Retrieve the loan from the repository
+	loan, err := u.RepositoryInterface.GetLoan(ctx, loanID)
+	if err != nil {
+		return model.EventPage{}, fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	// Return error if loan not found
+	if loan.LoanID == 0 {
+		return model.EventPage{}, model.ErrLoanNotFound
+	}
+
+	// Staff see every loan, borrowers only their own
+	if err := authorizeOwner(principal, loan); err != nil {
+		return model.EventPage{}, err
+	}
+
+	query.LoanID = loanID
+	return u.listEvents(ctx, query)
+}
+
+func (u Usecase) AdminEvents(ctx context.Context, query model.EventQuery) (page model.EventPage, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.AdminEvents")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Only admins may browse the whole audit log
+	if _, err := authorize(ctx, auth.PermissionAdmin); err != nil {
+		return model.EventPage{}, err
+	}
+
+	return u.listEvents(ctx, query)
+}
+
+func (u Usecase) listEvents(ctx context.Context, query model.EventQuery) (model.EventPage, error) {
+	switch {
+	case query.Limit < 0:
+		return model.EventPage{}, fmt.Errorf("%w: limit must not be negative", model.ErrInvalidQuery)
+	case query.Limit == 0:
+		query.Limit = DefaultEventPageSize
+	case query.Limit > MaxEventPageSize:
+		query.Limit = MaxEventPageSize
+	}
+
+	// Call the repository's ListEvents method
+	page, err := u.RepositoryInterface.ListEvents(ctx, query)
+	if err != nil {
+		return model.EventPage{}, fmt.Errorf("failed to list events from repository: %w", err)
+	}
+	return page, nil
+}
@@ -0,0 +1,88 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+// expiryReason is the cancellation reason of loans whose funding period is over.
+const expiryReason = "funding period expired"
+
+// fundingExpired reports whether the loan is still approved after its funding
+// period. Such a loan takes no more investments and is cancelled by the next
+// expiry sweep. Loans forced into approved without an approval date never expire.
+func (u Usecase) fundingExpired(loan model.Loan, now time.Time) bool {
+	if u.limits.FundingPeriod <= 0 || loan.State != model.StateEnumApproved || loan.ApprovalInfo.ApprovalDate.IsZero() {
+		return false
+	}
+	return now.After(loan.ApprovalInfo.ApprovalDate.Add(u.limits.FundingPeriod))
+}
+
+func (u Usecase) AdminExpireLoans(ctx context.Context) (report model.ExpiryReport, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.AdminExpireLoans")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Only admins may expire loans
+	principal, err := authorize(ctx, auth.PermissionAdmin)
+	if err != nil {
+		return model.ExpiryReport{}, err
+	}
+
+	report = model.ExpiryReport{Expired: make([]int64, 0)}
+
+	// Call the repository's GetLoans method, approved loans are not indexed
+	loans, err := u.RepositoryInterface.GetLoans(ctx)
+	if err != nil {
+		return model.ExpiryReport{}, fmt.Errorf("failed to get loans from repository: %w", err)
+	}
+
+	now := time.Now()
+	for _, loan := range loans {
+		if !u.fundingExpired(loan, now) {
+			continue
+		}
+		expired, err := u.expireLoan(ctx, principal.ActorID, loan.LoanID, now)
+		if err != nil {
+			return report, fmt.Errorf("failed to expire loan %d: %w", loan.LoanID, err)
+		}
+		if expired {
+			report.Expired = append(report.Expired, loan.LoanID)
+		}
+	}
+
+	return report, nil
+}
+
+// expireLoan cancels the loan if it is still past its funding period once
+// locked, an investment may have closed it in the meantime.
+func (u Usecase) expireLoan(ctx context.Context, actorID, loanID int64, now time.Time) (bool, error) {
+	// Hold the loan until the change is stored, concurrent commands on it wait
+	defer u.locks.lock(loanID)()
+
+	// Retrieve the loan again, the scanned copy may be stale
+	loan, err := u.RepositoryInterface.GetLoan(ctx, loanID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get loan: %w", err)
+	}
+	if loan.LoanID == 0 || !u.fundingExpired(loan, now) {
+		return false, nil
+	}
+
+	err = u.cancelLoan(ctx, loan, model.CancellationInfo{
+		Reason:           expiryReason,
+		CancelledBy:      actorID,
+		CancellationDate: now,
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+// FeeRules are the platform fees charged on new loans: the default terms, and
+// the terms of every other product loans may be proposed for.
+type FeeRules struct {
+	Default  model.FeeTerms
+	Products map[string]model.FeeTerms
+}
+
+// Terms returns the fee terms of the product. ok is false for a product that is
+// not offered.
+func (r FeeRules) Terms(product string) (terms model.FeeTerms, ok bool) {
+	if product == model.DefaultProduct {
+		return r.Default, true
+	}
+	terms, ok = r.Products[product]
+	return terms, ok
+}
+
+// feeTerms returns the fee terms the loan was proposed under. Loans proposed
+// before fees existed pay the current terms of their product.
+func (u Usecase) feeTerms(loan model.Loan) model.FeeTerms {
+	if loan.FeeTerms != nil {
+		return *loan.FeeTerms
+	}
+	terms, _ := u.fees.Terms(loan.ProductName())
+	return terms
+}
+
+// AdminRevenue reports the fees the platform earned, grouped by period and
+// product.
+func (u Usecase) AdminRevenue(ctx context.Context, query model.RevenueQuery) (report model.RevenueReport, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.AdminRevenue")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Only admins may see the revenue
+	if _, err := authorize(ctx, auth.PermissionAdmin); err != nil {
+		return model.RevenueReport{}, err
+	}
+
+	if query.Period == "" {
+		query.Period = model.RevenueByMonth
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return model.RevenueReport{}, fmt.Errorf("%w: from must be before to", model.ErrInvalidQuery)
+	}
+
+	// Call the repository's GetLoans method
+	loans, err := u.RepositoryInterface.GetLoans(ctx)
+	if err != nil {
+		return model.RevenueReport{}, fmt.Errorf("failed to get loans from repository: %w", err)
+	}
+
+	return model.NewRevenueReport(loans, query), nil
+}
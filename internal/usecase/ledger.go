@@ -0,0 +1,188 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/common/logger"
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/ledger"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+func (u Usecase) TrialBalance(ctx context.Context) (balance ledger.TrialBalance, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.TrialBalance")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Only admins may see the books
+	if _, err := authorize(ctx, auth.PermissionAdmin); err != nil {
+		return ledger.TrialBalance{}, err
+	}
+
+	// Call the repository's ListJournalEntries method, without a limit, the
+	// balances are summed from every entry
+	page, err := u.RepositoryInterface.ListJournalEntries(ctx, ledger.EntryQuery{Complete: true})
+	if err != nil {
+		return ledger.TrialBalance{}, fmt.Errorf("failed to list journal entries from repository: %w", err)
+	}
+	balance = ledger.NewTrialBalance(page.Entries)
+
+	// Each investor wallet account owes what the wallet holds. Commands post
+	// to the wallet first, so one running meanwhile may show as a difference.
+	wallets, err := u.RepositoryInterface.GetWallets(ctx)
+	if err != nil {
+		return ledger.TrialBalance{}, fmt.Errorf("failed to get wallets from repository: %w", err)
+	}
+	held := make(map[ledger.Account]float64, len(wallets))
+	for _, wallet := range wallets {
+		held[ledger.InvestorWallet(wallet.InvestorID)] = wallet.Deposited + wallet.Earned
+	}
+	balance.Reconcile(ledger.KindInvestorWallet, held)
+
+	return balance, nil
+}
+
+func (u Usecase) GetJournalEntries(ctx context.Context, query ledger.EntryQuery) (page ledger.EntryPage, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.GetJournalEntries")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Only admins may see the books
+	if _, err := authorize(ctx, auth.PermissionAdmin); err != nil {
+		return ledger.EntryPage{}, err
+	}
+
+	// Apply the page size limits of the event log
+	switch {
+	case query.Limit < 0:
+		return ledger.EntryPage{}, fmt.Errorf("%w: limit must not be negative", model.ErrInvalidQuery)
+	case query.Limit == 0:
+		query.Limit = DefaultEventPageSize
+	case query.Limit > MaxEventPageSize:
+		query.Limit = MaxEventPageSize
+	}
+
+	// Call the repository's ListJournalEntries method
+	page, err = u.RepositoryInterface.ListJournalEntries(ctx, query)
+	if err != nil {
+		return ledger.EntryPage{}, fmt.Errorf("failed to list journal entries from repository: %w", err)
+	}
+
+	return page, nil
+}
+
+// postJournal stamps the entries with the caller and the request, then posts
+// them and adds them to posted. Commands post right after moving the wallets,
+// which check the funds.
+func (u Usecase) postJournal(ctx context.Context, posted *postings, entries ...ledger.Entry) error {
+	principal, _ := auth.PrincipalFromContext(ctx)
+	for i := range entries {
+		entries[i].ActorID = principal.ActorID
+		entries[i].OccurredAt = time.Now()
+		entries[i].RequestID = logger.RequestID(ctx)
+	}
+
+	written, err := u.RepositoryInterface.PostJournalEntries(ctx, entries...)
+	if err != nil {
+		return fmt.Errorf("failed to post journal entries: %w", err)
+	}
+	posted.journal = append(posted.journal, written...)
+	return nil
+}
+
+// postings are the wallet and journal entries a command posted. When a later
+// step of the command fails, reverse takes them back, so the wallets and the
+// books never show money moving for a change that was not stored. A post that
+// fails itself is not taken back: its entries are checked before any is
+// written, only a failed cache write can leave part of them, and the balances
+// are rebuilt from the entries on restore.
+type postings struct {
+	wallet  []model.WalletEntry
+	journal []ledger.Entry
+}
+
+// reverse posts the reversal of every entry in posted, newest first. The
+// caller already fails, so a reversal that cannot be posted, such as one whose
+// funds the investor moved meanwhile, is logged for repair instead of hiding
+// why.
+func (u Usecase) reverse(ctx context.Context, posted postings) {
+	ctx = context.WithoutCancel(ctx)
+	for i := len(posted.journal) - 1; i >= 0; i-- {
+		entry := posted.journal[i]
+		err := u.postJournal(ctx, &postings{}, entry.Reversal())
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to reverse journal entry", "entry_id", entry.EntryID, "loan_id", entry.LoanID, "type", entry.Type, "error", err)
+		}
+	}
+	for i := len(posted.wallet) - 1; i >= 0; i-- {
+		entry := posted.wallet[i]
+		_, err := u.RepositoryInterface.PostWalletEntries(ctx, stampWalletEntry(ctx, entry.Reversal()))
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to reverse wallet entry", "entry_id", entry.EntryID, "investor_id", entry.InvestorID, "loan_id", entry.LoanID, "type", entry.Type, "amount", entry.Amount, "error", err)
+		}
+	}
+}
+
+// settlementJournal is the journal entry matching the wallet entries of
+// investmentEntries, for the sum of the rounded amounts they move: the money
+// set aside for the loan goes back to cash on a release and to the borrower on
+// a capture. ok is false when the entries move nothing.
+func settlementJournal(loanID int64, entryType model.WalletEntryType, entries []model.WalletEntry) (entry ledger.Entry, ok bool) {
+	var settled float64
+	for _, walletEntry := range entries {
+		settled += walletEntry.Amount
+	}
+	settled = model.RoundAmount(settled)
+	if settled <= 0 {
+		return ledger.Entry{}, false
+	}
+
+	switch entryType {
+	case model.WalletEntryRelease:
+		return ledger.Release(loanID, settled), true
+	case model.WalletEntryCapture:
+		return ledger.Disburse(loanID, settled), true
+	default:
+		return ledger.Entry{}, false
+	}
+}
+
+// settle posts the wallet entries and the journal entry of a release or a
+// capture of the investments in the loan, adding them to posted.
+func (u Usecase) settle(ctx context.Context, posted *postings, loan model.Loan, entryType model.WalletEntryType) error {
+	entries := u.investmentEntries(ctx, loan, entryType)
+	err := u.postWalletEntries(ctx, posted, entries...)
+	if err != nil {
+		return err
+	}
+	if entry, ok := settlementJournal(loan.LoanID, entryType, entries); ok {
+		return u.postJournal(ctx, posted, entry)
+	}
+	return nil
+}
+
+// disburse posts the capture of the investments in the loan for the borrower
+// and the origination fee the platform withholds from it, adding them to
+// posted. It returns the fee.
+func (u Usecase) disburse(ctx context.Context, posted *postings, loan model.Loan) (originationFee float64, err error) {
+	err = u.settle(ctx, posted, loan, model.WalletEntryCapture)
+	if err != nil {
+		return 0, err
+	}
+	originationFee = model.RoundAmount(loan.InvestedAmount() * u.feeTerms(loan).OriginationPercent / 100)
+	if originationFee > 0 {
+		err = u.postJournal(ctx, posted, ledger.OriginationFee(loan.LoanID, originationFee))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return originationFee, nil
+}
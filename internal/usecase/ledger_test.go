@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/ledger"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+func TestSettleFractionalCents(t *testing.T) {
+	tests := []struct {
+		name    string
+		settle  func(u Usecase, loanID int64) error
+		account func(loanID int64) ledger.Account // Holds the settled amount afterwards, if any
+	}{
+		{
+			name: "release",
+			settle: func(u Usecase, loanID int64) error {
+				return u.Cancel(as(auth.RoleAdmin, testAdminID), loanID, "withdrawn")
+			},
+		},
+		{
+			name: "capture",
+			settle: func(u Usecase, loanID int64) error {
+				return u.AdminForceTransition(as(auth.RoleAdmin, testAdminID), loanID, model.StateEnumDisbursed, "paid out by hand")
+			},
+			account: ledger.BorrowerReceivable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, repo := newTestUsecase(t)
+			loanID := createLoan(t, u, 100)
+			approveLoan(t, u, loanID)
+			for _, investorID := range []int64{7, 8, 9} {
+				deposit(t, u, investorID, 100)
+				invest(t, u, loanID, investorID, 33.33)
+			}
+
+			// Loans stored before amounts had to be whole cents hold the
+			// fractions the investors asked for, their wallets the rounded
+			// amounts
+			loan, err := repo.GetLoan(context.Background(), loanID)
+			if err != nil {
+				t.Fatalf("GetLoan() error = %v", err)
+			}
+			for i := range loan.Investments {
+				loan.Investments[i].InvestedAmount = 33.333
+			}
+			if err := repo.UpdateLoan(context.Background(), loan); err != nil {
+				t.Fatalf("UpdateLoan() error = %v", err)
+			}
+
+			if err := tt.settle(u, loanID); err != nil {
+				t.Fatalf("settling error = %v", err)
+			}
+
+			// The books move what the wallets did, the money set aside for
+			// the loan is all gone
+			balance, err := u.TrialBalance(as(auth.RoleAdmin, testAdminID))
+			if err != nil {
+				t.Fatalf("TrialBalance() error = %v", err)
+			}
+			balances := make(map[ledger.Account]float64)
+			for _, account := range balance.Accounts {
+				balances[account.Account] = account.Balance
+			}
+			if got := balances[ledger.DisbursementClearing(loanID)]; got != 0 {
+				t.Errorf("disbursement clearing balance = %v, want 0", got)
+			}
+			if tt.account != nil {
+				if got := balances[tt.account(loanID)]; got != 99.99 {
+					t.Errorf("%s balance = %v, want 99.99", tt.account(loanID), got)
+				}
+			}
+			checkBooks(t, u)
+		})
+	}
+}
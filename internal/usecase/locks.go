@@ -0,0 +1,77 @@
+package usecase
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// loanLocks serializes the commands on a loan, so that two concurrent
+// investments cannot both pass the principal check. Commands on different
+// loans still run in parallel, unless all loans are locked at once.
+type loanLocks struct {
+	all   sync.RWMutex // Held for reading by every loan lock
+	mu    sync.Mutex
+	locks map[int64]*loanLock
+}
+
+type loanLock struct {
+	sync.Mutex
+	waiters int
+}
+
+func newLoanLocks() *loanLocks {
+	return &loanLocks{locks: make(map[int64]*loanLock)}
+}
+
+// lock blocks until the loan is free and returns the function releasing it.
+// A command must not hold two loans at a time.
+func (l *loanLocks) lock(loanID int64) func() {
+	l.all.RLock()
+	l.mu.Lock()
+	lock, ok := l.locks[loanID]
+	if !ok {
+		lock = &loanLock{}
+		l.locks[loanID] = lock
+	}
+	lock.waiters++
+	l.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		// Forget the loan once nobody waits for it
+		l.mu.Lock()
+		lock.waiters--
+		if lock.waiters == 0 {
+			delete(l.locks, loanID)
+		}
+		l.mu.Unlock()
+		l.all.RUnlock()
+	}
+}
+
+// lockAll blocks until no loan is held and keeps every loan, including those
+// not created yet, until the returned function is called.
+func (l *loanLocks) lockAll() func() {
+	l.all.Lock()
+	return l.all.Unlock
+}
+
+var lastLoanID atomic.Int64
+
+// newLoanID returns the creation time in milliseconds, bumped past the last
+// ID handed out so that loans created in the same millisecond stay distinct.
+func newLoanID(now time.Time) int64 {
+	for {
+		last := lastLoanID.Load()
+		id := now.UnixMilli()
+		if id <= last {
+			id = last + 1
+		}
+		if lastLoanID.CompareAndSwap(last, id) {
+			return id
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"github.com/timotiusas11/amartha-assignment/common/metrics"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+var (
+	loanTransitionsTotal = metrics.NewCounterVec(
+		"loan_state_transitions_total",
+		"Number of loan state transitions. Created loans transition from \"none\".",
+		"from", "to",
+	)
+	loanAmounts = metrics.NewGaugeVec(
+		"loan_amount",
+		"Cumulative amount of loans by kind since the log began: principal proposed, invested and disbursed, and repaid. Cancelling a loan does not lower it.",
+		"kind",
+	)
+)
+
+func init() {
+	metrics.Default.MustRegister(loanTransitionsTotal, loanAmounts)
+}
+
+func observeTransition(from string, to model.StateEnum) {
+	loanTransitionsTotal.WithLabelValues(from, to.String()).Inc()
+}
+
+// resetLoanAmounts recomputes the loan amount totals from the whole loan book,
+// counting what the commands add as they run: every loan proposed and every
+// investment, cancelled or not, and the loans paid out by Disburse or by a
+// forced disbursement of reserved funds.
+func resetLoanAmounts(loans []model.Loan) {
+	var proposed, invested, disbursed, repaid float64
+	for _, loan := range loans {
+		proposed += loan.PrincipalAmount
+		invested += loan.InvestedAmount()
+		if !loan.DisbursementInfo.DisbursementDate.IsZero() {
+			disbursed += loan.PrincipalAmount
+		}
+		repaid += loan.RepaidAmount()
+	}
+	loanAmounts.WithLabelValues("proposed").Set(proposed)
+	loanAmounts.WithLabelValues("invested").Set(invested)
+	loanAmounts.WithLabelValues("disbursed").Set(disbursed)
+	loanAmounts.WithLabelValues("repaid").Set(repaid)
+}
@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+func TestResetLoanAmounts(t *testing.T) {
+	disbursed := model.Loan{
+		LoanID:           1,
+		State:            model.StateEnumDisbursed,
+		PrincipalAmount:  1000,
+		Investments:      []model.Investment{{InvestorID: 7, InvestedAmount: 400}, {InvestorID: 8, InvestedAmount: 600}},
+		DisbursementInfo: model.DisbursementInfo{DisbursementDate: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+		Repayments:       []model.Repayment{{Amount: 300}, {Amount: 200.5}},
+	}
+	cancelled := model.Loan{
+		LoanID:          2,
+		State:           model.StateEnumCancelled,
+		PrincipalAmount: 500,
+		Investments:     []model.Investment{{InvestorID: 7, InvestedAmount: 250}},
+	}
+	undated := model.Loan{
+		LoanID:          3,
+		State:           model.StateEnumDisbursed,
+		PrincipalAmount: 200,
+		Investments:     []model.Investment{{InvestorID: 9, InvestedAmount: 200}},
+	}
+
+	tests := []struct {
+		name  string
+		loans []model.Loan
+		want  map[string]float64
+	}{
+		{
+			name: "empty loan book",
+			want: map[string]float64{"proposed": 0, "invested": 0, "disbursed": 0, "repaid": 0},
+		},
+		{
+			name:  "disbursed and repaid",
+			loans: []model.Loan{disbursed},
+			want:  map[string]float64{"proposed": 1000, "invested": 1000, "disbursed": 1000, "repaid": 500.5},
+		},
+		{
+			name:  "cancelled loans still count",
+			loans: []model.Loan{disbursed, cancelled},
+			want:  map[string]float64{"proposed": 1500, "invested": 1250, "disbursed": 1000, "repaid": 500.5},
+		},
+		{
+			name:  "only loans paid out count as disbursed",
+			loans: []model.Loan{cancelled, undated},
+			want:  map[string]float64{"proposed": 700, "invested": 450, "disbursed": 0, "repaid": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Totals are replaced, not added to what the previous rebuild left
+			loanAmounts.WithLabelValues("proposed").Set(99999)
+			resetLoanAmounts(tt.loans)
+			for kind, want := range tt.want {
+				if got := loanAmounts.WithLabelValues(kind).Value(); got != want {
+					t.Errorf("loan_amount{kind=%q} = %v, want %v", kind, got, want)
+				}
+			}
+		})
+	}
+}
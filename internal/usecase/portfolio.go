@@ -0,0 +1,36 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+func (u Usecase) GetPortfolio(ctx context.Context, investorID int64) (portfolio model.Portfolio, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.GetPortfolio", tracing.Int64("investor_id", investorID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Investors see their own portfolio, admins every portfolio
+	principal, err := authorize(ctx, auth.PermissionReadWallet)
+	if err != nil {
+		return model.Portfolio{}, err
+	}
+	err = authorizeInvestor(principal, investorID)
+	if err != nil {
+		return model.Portfolio{}, err
+	}
+
+	// Call the repository's GetInvestorLoans method
+	loans, err := u.RepositoryInterface.GetInvestorLoans(ctx, investorID)
+	if err != nil {
+		return model.Portfolio{}, fmt.Errorf("failed to get investor loans from repository: %w", err)
+	}
+
+	return model.NewPortfolio(investorID, loans, u.feeTerms), nil
+}
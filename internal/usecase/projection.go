@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+// AdminLoanAsOf replays the events of a loan up to and including asOf. A zero
+// asOf replays the whole log.
+func (u Usecase) AdminLoanAsOf(ctx context.Context, loanID int64, asOf time.Time) (loan model.Loan, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.AdminLoanAsOf", tracing.Int64("loan_id", loanID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Only admins may see the full loan details
+	if _, err := authorize(ctx, auth.PermissionAdmin); err != nil {
+		return model.Loan{}, err
+	}
+
+	// Call the repository's ListEvents method
+	page, err := u.RepositoryInterface.ListEvents(ctx, model.EventQuery{LoanID: loanID, Complete: true})
+	if err != nil {
+		return model.Loan{}, fmt.Errorf("failed to list events from repository: %w", err)
+	}
+
+	events := page.Events
+	if !asOf.IsZero() {
+		events = slices.DeleteFunc(events, func(event model.Event) bool {
+			return event.OccurredAt.After(asOf)
+		})
+	}
+
+	loan, err = model.ReplayLoan(events)
+	if errors.Is(err, model.ErrNoEvents) {
+		return model.Loan{}, model.ErrLoanNotFound
+	}
+	if err != nil {
+		return model.Loan{}, fmt.Errorf("failed to replay loan %d: %w", loanID, err)
+	}
+	return loan, nil
+}
+
+// AdminRebuildProjections replays the whole event log and, unless dryRun,
+// replaces the loan book and the loan amount totals with the result. Commands
+// on loans wait for the rebuild, so none is lost by it. It fails without
+// changing anything when events are missing from the log. A loan whose events
+// do not replay, such as a stream that does not start with LoanCreated, is
+// skipped and reported, the other loans are still rebuilt.
+func (u Usecase) AdminRebuildProjections(ctx context.Context, dryRun bool) (report model.RebuildReport, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.AdminRebuildProjections", tracing.Bool("dry_run", dryRun))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Only admins may rebuild projections
+	principal, err := authorize(ctx, auth.PermissionAdmin)
+	if err != nil {
+		return model.RebuildReport{}, err
+	}
+
+	started := time.Now()
+	report = model.RebuildReport{
+		DryRun:       dryRun,
+		ChangedLoans: make([]int64, 0),
+		MissingLoans: make([]int64, 0),
+		OrphanLoans:  make([]int64, 0),
+		SkippedLoans: make([]int64, 0),
+	}
+
+	// Hold every loan, existing or new, until the loan book is replaced
+	defer u.locks.lockAll()()
+
+	// Call the repository's ListEvents method, without a limit
+	page, err := u.RepositoryInterface.ListEvents(ctx, model.EventQuery{Complete: true})
+	if err != nil {
+		return model.RebuildReport{}, fmt.Errorf("failed to list events from repository: %w", err)
+	}
+	report.Events = len(page.Events)
+
+	// Replay every loan, in the order loans were created
+	replayed := make(map[int64]*model.Loan)
+	skipped := make(map[int64]bool)
+	var order []int64
+	for _, event := range page.Events {
+		if event.LoanID == 0 || skipped[event.LoanID] {
+			continue
+		}
+		loan, ok := replayed[event.LoanID]
+		if !ok {
+			loan = &model.Loan{}
+			replayed[event.LoanID] = loan
+			order = append(order, event.LoanID)
+		}
+		if err := loan.Apply(event); err != nil {
+			slog.WarnContext(ctx, "skipping loan whose events do not replay", "loan_id", event.LoanID, "error", err)
+			skipped[event.LoanID] = true
+			delete(replayed, event.LoanID)
+			report.SkippedLoans = append(report.SkippedLoans, event.LoanID)
+		}
+	}
+	order = slices.DeleteFunc(order, func(loanID int64) bool { return skipped[loanID] })
+	slices.Sort(report.SkippedLoans)
+	report.Loans = len(order)
+
+	// Call the repository's GetLoans method to compare against the current snapshot
+	current, err := u.RepositoryInterface.GetLoans(ctx)
+	if err != nil {
+		return model.RebuildReport{}, fmt.Errorf("failed to get loans from repository: %w", err)
+	}
+	snapshot := make(map[int64]model.Loan, len(current))
+	for _, loan := range current {
+		snapshot[loan.LoanID] = loan
+	}
+
+	loans := make([]model.Loan, 0, len(order)+len(snapshot))
+	for _, loanID := range order {
+		loan := *replayed[loanID]
+		old, ok := snapshot[loanID]
+		switch {
+		case !ok:
+			report.MissingLoans = append(report.MissingLoans, loanID)
+		case !sameLoan(old, loan):
+			report.ChangedLoans = append(report.ChangedLoans, loanID)
+		}
+		loans = append(loans, loan)
+	}
+	for _, loan := range current {
+		if _, ok := replayed[loan.LoanID]; !ok {
+			if !skipped[loan.LoanID] {
+				report.OrphanLoans = append(report.OrphanLoans, loan.LoanID)
+			}
+			loans = append(loans, loan)
+		}
+	}
+	slices.Sort(report.OrphanLoans)
+
+	if !dryRun {
+		// Call the repository's ReplaceLoans method
+		err = u.RepositoryInterface.ReplaceLoans(ctx, loans)
+		if err != nil {
+			return model.RebuildReport{}, fmt.Errorf("failed to replace loans: %w", err)
+		}
+		resetLoanAmounts(loans)
+	}
+
+	report.DurationMS = time.Since(started).Milliseconds()
+
+	slog.InfoContext(ctx, "projections rebuilt",
+		"actor_id", principal.ActorID,
+		"dry_run", dryRun,
+		"events", report.Events,
+		"loans", report.Loans,
+		"changed_loans", len(report.ChangedLoans),
+		"missing_loans", len(report.MissingLoans),
+		"orphan_loans", len(report.OrphanLoans),
+		"skipped_loans", len(report.SkippedLoans),
+	)
+
+	return report, nil
+}
+
+// sameLoan compares loans by their stored form, which is what readers see.
+func sameLoan(a, b model.Loan) bool {
+	dataA, errA := json.Marshal(a)
+	dataB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(dataA, dataB)
+}
@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+func TestAdminRebuildProjectionsSkipsBrokenStreams(t *testing.T) {
+	const unknownLoanID = 424242
+
+	rejected, _ := json.Marshal(model.CommandRejectedPayload{Command: "Invest", Reason: model.ErrLoanNotFound.Error()})
+	approved, _ := json.Marshal(model.ApprovalInfo{PictureProofURL: "https://example.com/proof.png", FieldValidatorID: testValidatorID})
+
+	tests := []struct {
+		name        string
+		broken      []model.Event // Appended as is, like logs written before rejections were kept out of the loan events
+		wantSkipped []int64
+	}{
+		{
+			name: "no broken stream",
+		},
+		{
+			name:        "rejection on a loan that was never created",
+			broken:      []model.Event{{LoanID: unknownLoanID, Type: model.EventCommandRejected, Payload: rejected}},
+			wantSkipped: []int64{unknownLoanID},
+		},
+		{
+			name:        "stream without LoanCreated",
+			broken:      []model.Event{{LoanID: unknownLoanID, Type: model.EventLoanApproved, Payload: approved}},
+			wantSkipped: []int64{unknownLoanID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, repo := newTestUsecase(t)
+			admin := as(auth.RoleAdmin, testAdminID)
+			loanID := createLoan(t, u, 1000)
+
+			// A rejected command on a loan that does not exist stays out of
+			// the loan events
+			err := u.Invest(as(auth.RoleInvestor, 7), unknownLoanID, model.Investment{InvestorID: 7, InvestedAmount: 100})
+			if !errors.Is(err, model.ErrLoanNotFound) {
+				t.Fatalf("Invest() on an unknown loan error = %v, want %v", err, model.ErrLoanNotFound)
+			}
+			page, err := u.AdminEvents(admin, model.EventQuery{Types: []model.EventType{model.EventCommandRejected}})
+			if err != nil {
+				t.Fatalf("AdminEvents() error = %v", err)
+			}
+			if len(page.Events) != 1 || page.Events[0].LoanID != 0 {
+				t.Fatalf("rejected events = %+v, want one with loan ID 0", page.Events)
+			}
+			var payload model.CommandRejectedPayload
+			if err := json.Unmarshal(page.Events[0].Payload, &payload); err != nil || payload.LoanID != unknownLoanID {
+				t.Errorf("rejected event payload = %s, want the loan ID %d", page.Events[0].Payload, unknownLoanID)
+			}
+
+			for _, event := range tt.broken {
+				event.OccurredAt = time.Now()
+				if _, err := repo.AppendEvents(context.Background(), event); err != nil {
+					t.Fatalf("AppendEvents() error = %v", err)
+				}
+			}
+
+			report, err := u.AdminRebuildProjections(admin, false)
+			if err != nil {
+				t.Fatalf("AdminRebuildProjections() error = %v", err)
+			}
+			wantSkipped := tt.wantSkipped
+			if wantSkipped == nil {
+				wantSkipped = []int64{}
+			}
+			if report.Loans != 1 || !reflect.DeepEqual(report.SkippedLoans, wantSkipped) || len(report.OrphanLoans) != 0 {
+				t.Errorf("AdminRebuildProjections() = %+v, want 1 loan rebuilt and %v skipped", report, wantSkipped)
+			}
+			loan, err := repo.GetLoan(context.Background(), loanID)
+			if err != nil || loan.LoanID != loanID {
+				t.Errorf("rebuilt loan %d = %+v, %v, want it kept", loanID, loan, err)
+			}
+
+			// The unknown loan has no history to replay, the other one does
+			_, err = u.AdminLoanAsOf(admin, unknownLoanID, time.Time{})
+			if !errors.Is(err, model.ErrLoanNotFound) {
+				t.Errorf("AdminLoanAsOf() of the unknown loan error = %v, want %v", err, model.ErrLoanNotFound)
+			}
+			loan, err = u.AdminLoanAsOf(admin, loanID, time.Time{})
+			if err != nil || loan.LoanID != loanID {
+				t.Errorf("AdminLoanAsOf() = %+v, %v, want loan %d", loan, err, loanID)
+			}
+		})
+	}
+}
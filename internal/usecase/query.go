@@ -0,0 +1,40 @@
+package usecase
+
+import (
+	"fmt"
+
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// validateLoanQuery rejects contradictory filters and clamps the page size.
+func validateLoanQuery(query *model.LoanQuery) error {
+	ranges := map[string]model.Range{
+		"principal_amount": query.PrincipalAmount,
+		"rate":             query.Rate,
+		"roi":              query.ROI,
+		"remaining_amount": query.RemainingAmount,
+	}
+	for name, r := range ranges {
+		if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+			return fmt.Errorf("%w: min_%s is greater than max_%s", model.ErrInvalidQuery, name, name)
+		}
+	}
+	if !query.CreatedFrom.IsZero() && !query.CreatedTo.IsZero() && !query.CreatedFrom.Before(query.CreatedTo) {
+		return fmt.Errorf("%w: created_from must be before created_to", model.ErrInvalidQuery)
+	}
+
+	switch {
+	case query.Limit < 0:
+		return fmt.Errorf("%w: limit must not be negative", model.ErrInvalidQuery)
+	case query.Limit == 0:
+		query.Limit = DefaultPageSize
+	case query.Limit > MaxPageSize:
+		query.Limit = MaxPageSize
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/ledger"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+// Repay records money paid back on a disbursed loan and credits the investors
+// their share of principal and return. A late fee is paid on top of the amount
+// and shared between the investors and the platform.
+func (u Usecase) Repay(ctx context.Context, loanID int64, amount float64, lateFee float64) (repayment model.Repayment, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.Repay", tracing.Int64("loan_id", loanID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Keep failed attempts in the audit log as well
+	defer func() {
+		if err != nil {
+			u.recordRejection(ctx, "Repay", loanID, err)
+		}
+	}()
+
+	// Borrowers repay their own loans, field officers collect for any loan
+	principal, err := authorize(ctx, auth.PermissionRepayLoan)
+	if err != nil {
+		return model.Repayment{}, err
+	}
+
+	// Amounts are kept in cents
+	amount = model.RoundAmount(amount)
+	if amount <= 0 {
+		return model.Repayment{}, errors.New("repayment amount must be at least 0.01")
+	}
+	lateFee = model.RoundAmount(lateFee)
+	if lateFee < 0 {
+		return model.Repayment{}, errors.New("late fee must not be negative")
+	}
+
+	// Hold the loan until the change is stored, concurrent commands on it wait
+	defer u.locks.lock(loanID)()
+
+	// Retrieve the loan from the repository
+	loan, err := u.RepositoryInterface.GetLoan(ctx, loanID)
+	if err != nil {
+		return model.Repayment{}, fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	// Return error if loan not found
+	if loan.LoanID == 0 {
+		return model.Repayment{}, model.ErrLoanNotFound
+	}
+
+	err = authorizeOwner(principal, loan)
+	if err != nil {
+		return model.Repayment{}, err
+	}
+
+	// Only the money lent out can be paid back
+	if loan.State != model.StateEnumDisbursed {
+		return model.Repayment{}, fmt.Errorf("%w: loan can only be repaid once disbursed", model.ErrInvalidState)
+	}
+	if outstanding := loan.OutstandingAmount(); amount > outstanding {
+		return model.Repayment{}, fmt.Errorf("repayment exceeds the outstanding amount of %v", outstanding)
+	}
+
+	// The investors get the return the platform leaves them of the rate, and
+	// what it does not keep of the late fee
+	terms := u.feeTerms(loan)
+	repayment = model.Repayment{
+		Amount:       amount,
+		LateFee:      lateFee,
+		PaidBy:       principal.ActorID,
+		PaidAt:       time.Now(),
+		Payouts:      loan.Payouts(amount, terms.ReturnPercent(loan.Rate, loan.ROI)),
+		LateFeeShare: model.RoundAmount(lateFee * terms.LateFeeSharePercent / 100),
+	}
+	loan.ShareLateFee(repayment.Payouts, model.RoundAmount(lateFee-repayment.LateFeeShare))
+
+	// Whatever the payouts leave of the amount is the servicing fee
+	paidOut := 0.0
+	for _, payout := range repayment.Payouts {
+		paidOut += payout.Principal + payout.Return
+	}
+	repayment.ServicingFee = model.RoundAmount(amount - paidOut)
+
+	// Take the credits back when the repayment is not recorded after all
+	var posted postings
+	defer func() {
+		if err != nil {
+			u.reverse(ctx, posted)
+		}
+	}()
+
+	// Credit the investors, principal back from the loan and the return on top
+	var entries []model.WalletEntry
+	for _, payout := range repayment.Payouts {
+		if payout.Principal > 0 {
+			entries = append(entries, u.newWalletEntry(ctx, payout.InvestorID, loanID, model.WalletEntryPayout, payout.Principal))
+		}
+		if earned := model.RoundAmount(payout.Return + payout.LateFee); earned > 0 {
+			entries = append(entries, u.newWalletEntry(ctx, payout.InvestorID, loanID, model.WalletEntryReturn, earned))
+		}
+	}
+	err = u.postWalletEntries(ctx, &posted, entries...)
+	if err != nil {
+		return model.Repayment{}, err
+	}
+
+	// Book the money received, then its split: the principal settles the
+	// receivable, the returns go to the investors and the fees to the platform
+	shares := make([]ledger.Share, len(repayment.Payouts))
+	for i, payout := range repayment.Payouts {
+		shares[i] = ledger.Share{InvestorID: payout.InvestorID, Principal: payout.Principal, Return: payout.Return + payout.LateFee}
+	}
+	err = u.postJournal(ctx, &posted,
+		ledger.Repayment(loanID, amount+lateFee),
+		ledger.Payout(loanID, shares, repayment.ServicingFee+repayment.LateFeeShare),
+	)
+	if err != nil {
+		return model.Repayment{}, err
+	}
+
+	// Update loan in the cache and record the repayment in the audit log
+	previous := loan
+	loan.Repayments = append(loan.Repayments, repayment)
+	err = u.commitLoan(ctx, previous, loan, u.newEvent(ctx, loanID, model.EventRepaymentRecorded, principal.ActorID, repayment))
+	if err != nil {
+		return model.Repayment{}, err
+	}
+
+	loanAmounts.WithLabelValues("repaid").Add(amount)
+
+	slog.InfoContext(ctx, "repayment recorded",
+		"loan_id", loanID,
+		"actor_id", principal.ActorID,
+		"amount", amount,
+		"late_fee", lateFee,
+		"outstanding_amount", loan.OutstandingAmount(),
+	)
+
+	return repayment, nil
+}
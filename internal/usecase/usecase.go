@@ -1,182 +1,457 @@
 package usecase
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"time"
 
+	"github.com/timotiusas11/amartha-assignment/common/driver/inmemlib"
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/archive"
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/ledger"
 	"github.com/timotiusas11/amartha-assignment/internal/model"
 	"github.com/timotiusas11/amartha-assignment/internal/repository"
 )
 
 type UsecaseInterface interface {
-	CreateLoan(borrowerID int64, principalAmount float64, rate float64, roi float64) error
-	GetLoans() ([]model.LoanInformation, error)
-	GetLoan(loanID int64) (model.LoanInformation, error)
-	Approve(loanID int64, pictureProofURL string, fieldValidatorID int64) error
-	Invest(loanID int64, investment model.Investment) error
-	Disburse(loanID int64, agreementLetterURL string, fieldOfficerID int64) error
-	AdminViewLoans() ([]model.Loan, error)
+	CreateLoan(ctx context.Context, borrowerID int64, principalAmount float64, rate float64, roi float64, product string) (int64, error)
+	GetLoans(ctx context.Context, query model.LoanQuery) (model.LoanInformationPage, error)
+	GetLoan(ctx context.Context, loanID int64) (model.LoanInformation, error)
+	Approve(ctx context.Context, loanID int64, pictureProofURL string, fieldValidatorID int64) error
+	Invest(ctx context.Context, loanID int64, investment model.Investment) error
+	Disburse(ctx context.Context, loanID int64, agreementLetterURL string, fieldOfficerID int64) error
+	Cancel(ctx context.Context, loanID int64, reason string) error
+	AdminViewLoans(ctx context.Context) ([]model.Loan, error)
+	AdminCacheStats(ctx context.Context) (inmemlib.Stats, error)
+	GetLoanEvents(ctx context.Context, loanID int64, query model.EventQuery) (model.EventPage, error)
+	AdminEvents(ctx context.Context, query model.EventQuery) (model.EventPage, error)
+	AdminLoanAsOf(ctx context.Context, loanID int64, asOf time.Time) (model.Loan, error)
+	AdminRebuildProjections(ctx context.Context, dryRun bool) (model.RebuildReport, error)
+	AdminExport(ctx context.Context) (archive.Snapshot, error)
+	AdminRestore(ctx context.Context, snapshot archive.Snapshot) (model.RestoreReport, error)
+	AdminForceTransition(ctx context.Context, loanID int64, to model.StateEnum, reason string) error
+	AdminExpireLoans(ctx context.Context) (model.ExpiryReport, error)
+	AdminRepublish(ctx context.Context, loanID int64, channel string) (int, error)
+	UploadDocument(ctx context.Context, loanID int64, kind model.DocumentKind, filename string, content io.Reader) (model.Document, error)
+	GetLoanDocuments(ctx context.Context, loanID int64) ([]model.Document, error)
+	OpenLoanDocument(ctx context.Context, loanID int64, sha256 string) (model.Document, io.ReadCloser, error)
+	RegisterBorrower(ctx context.Context, borrowerID int64, profile model.BorrowerProfile) (model.Borrower, error)
+	GetBorrower(ctx context.Context, borrowerID int64) (model.Borrower, error)
+	GetBorrowers(ctx context.Context) ([]model.Borrower, error)
+	UpdateBorrower(ctx context.Context, borrowerID int64, profile model.BorrowerProfile) (model.Borrower, error)
+	DeleteBorrower(ctx context.Context, borrowerID int64) error
+	ReviewKYC(ctx context.Context, borrowerID int64, status model.KYCStatus, reason string) (model.Borrower, error)
+	UploadBorrowerDocument(ctx context.Context, borrowerID int64, kind model.DocumentKind, filename string, content io.Reader) (model.Document, error)
+	OpenBorrowerDocument(ctx context.Context, borrowerID int64, sha256 string) (model.Document, io.ReadCloser, error)
+	Deposit(ctx context.Context, investorID int64, amount float64) (model.Wallet, error)
+	GetWallet(ctx context.Context, investorID int64) (model.Wallet, error)
+	GetWalletEntries(ctx context.Context, investorID int64, query model.WalletEntryQuery) (model.WalletEntryPage, error)
+	AdminWallets(ctx context.Context) ([]model.Wallet, error)
+	GetPortfolio(ctx context.Context, investorID int64) (model.Portfolio, error)
+	TrialBalance(ctx context.Context) (ledger.TrialBalance, error)
+	GetJournalEntries(ctx context.Context, query ledger.EntryQuery) (ledger.EntryPage, error)
+	Repay(ctx context.Context, loanID int64, amount float64, lateFee float64) (model.Repayment, error)
+	AdminRevenue(ctx context.Context, query model.RevenueQuery) (model.RevenueReport, error)
+}
+
+// Limits are the business limits applied when creating and investing in loans.
+// A zero maximum means there is no upper bound.
+type Limits struct {
+	MinPrincipalAmount  float64
+	MaxPrincipalAmount  float64
+	MaxRate             float64
+	MaxROI              float64
+	MinInvestmentAmount float64
+	MaxDocumentSize     int64         // In bytes
+	FundingPeriod       time.Duration // How long an approved loan takes investments, 0 for ever
 }
 
 type Usecase struct {
 	repository.RepositoryInterface
+	limits Limits
+	fees   FeeRules
+	locks  *loanLocks
+	// borrowerLocks serializes the changes to a borrower the same way
+	borrowerLocks *loanLocks
 }
 
-func NewUsecase(repository repository.RepositoryInterface) Usecase {
+func NewUsecase(repository repository.RepositoryInterface, limits Limits, fees FeeRules) Usecase {
 	return Usecase{
 		RepositoryInterface: repository,
+		limits:              limits,
+		fees:                fees,
+		locks:               newLoanLocks(),
+		borrowerLocks:       newLoanLocks(),
 	}
 }
 
-func (u Usecase) CreateLoan(borrowerID int64, principalAmount float64, rate float64, roi float64) error {
+func (u Usecase) CreateLoan(ctx context.Context, borrowerID int64, principalAmount float64, rate float64, roi float64, product string) (loanID int64, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.CreateLoan", tracing.Int64("borrower_id", borrowerID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Keep failed attempts in the audit log as well
+	defer func() {
+		if err != nil {
+			u.recordRejection(ctx, "CreateLoan", 0, err)
+		}
+	}()
+
+	// Only borrowers may propose loans
+	if _, err := authorize(ctx, auth.PermissionCreateLoan); err != nil {
+		return 0, err
+	}
+
+	// Validate the loan details against the business limits
+	if borrowerID == 0 {
+		return 0, errors.New("borrower ID is empty")
+	}
+	if principalAmount < u.limits.MinPrincipalAmount || principalAmount <= 0 {
+		return 0, fmt.Errorf("principal amount must be at least %v", u.limits.MinPrincipalAmount)
+	}
+	// Amounts are kept in cents, investments could not fund a fraction of one
+	if model.RoundAmount(principalAmount) != principalAmount {
+		return 0, errors.New("principal amount must be in whole cents")
+	}
+	if u.limits.MaxPrincipalAmount > 0 && principalAmount > u.limits.MaxPrincipalAmount {
+		return 0, fmt.Errorf("principal amount must not exceed %v", u.limits.MaxPrincipalAmount)
+	}
+	if rate <= 0 || rate > u.limits.MaxRate {
+		return 0, fmt.Errorf("rate must be greater than 0 and at most %v", u.limits.MaxRate)
+	}
+	if roi <= 0 || roi > u.limits.MaxROI {
+		return 0, fmt.Errorf("roi must be greater than 0 and at most %v", u.limits.MaxROI)
+	}
+	// Investors cannot be promised more than the borrower pays
+	if roi > rate {
+		return 0, fmt.Errorf("roi must not exceed the rate of %v", rate)
+	}
+
+	// The loan keeps the fee terms of its product, later changes to the rules
+	// only apply to new loans
+	if product == "" {
+		product = model.DefaultProduct
+	}
+	terms, ok := u.fees.Terms(product)
+	if !ok {
+		return 0, fmt.Errorf("product %q is not offered", product)
+	}
+
+	// Only registered borrowers who passed KYC may borrow
+	err = u.requireEligibleBorrower(ctx, borrowerID)
+	if err != nil {
+		return 0, err
+	}
+
 	// Create a new loan object
+	now := time.Now()
 	loan := model.Loan{
-		LoanID:          time.Now().UnixMilli(),
+		LoanID:          newLoanID(now),
 		BorrowerID:      borrowerID,
 		PrincipalAmount: principalAmount,
 		Rate:            rate,
 		ROI:             roi,
+		Product:         product,
+		FeeTerms:        &terms,
 		State:           model.StateEnumProposed,
+		CreatedAt:       now,
 	}
 
+	// Hold the new loan until it is stored, a rebuild must not miss it
+	defer u.locks.lock(loan.LoanID)()
+
+	// Record the creation in the audit log, replaying it yields the same loan
+	event := u.newEvent(ctx, loan.LoanID, model.EventLoanCreated, borrowerID, model.LoanCreatedPayload{
+		BorrowerID:      borrowerID,
+		PrincipalAmount: principalAmount,
+		Rate:            rate,
+		ROI:             roi,
+		Product:         product,
+		FeeTerms:        &terms,
+	})
+	event.OccurredAt = loan.CreatedAt
+
 	// Call the dependency's InsertLoan method
-	err := u.RepositoryInterface.InsertLoan(loan)
+	err = u.RepositoryInterface.InsertLoan(ctx, loan)
 	if err != nil {
-		return errors.New("failed to insert loan: " + err.Error())
+		return 0, fmt.Errorf("failed to insert loan: %w", err)
+	}
+
+	// A loan missing from the log would be lost by the next rebuild, take it
+	// back out when the event cannot be appended
+	err = u.appendEvents(ctx, event)
+	if err != nil {
+		deleteErr := u.RepositoryInterface.DeleteLoan(context.WithoutCancel(ctx), loan.LoanID)
+		if deleteErr != nil {
+			slog.ErrorContext(ctx, "failed to delete loan after its event was lost", "loan_id", loan.LoanID, "error", deleteErr)
+		}
+		return 0, err
 	}
 
 	// Generate agreement letter
-	err = u.RepositoryInterface.GenerateAgreementLetter(loan.LoanID)
+	err = u.RepositoryInterface.GenerateAgreementLetter(ctx, loan.LoanID)
 	if err != nil {
-		return fmt.Errorf("failed to generate agreement letter: %w", err)
+		return 0, fmt.Errorf("failed to generate agreement letter: %w", err)
 	}
 
-	return nil
+	observeTransition("none", loan.State)
+	loanAmounts.WithLabelValues("proposed").Add(principalAmount)
+
+	slog.InfoContext(ctx, "loan created",
+		"loan_id", loan.LoanID,
+		"actor_id", borrowerID,
+		"to_state", loan.State.String(),
+		"principal_amount", principalAmount,
+	)
+
+	return loan.LoanID, nil
 }
 
-func (u Usecase) GetLoans() ([]model.LoanInformation, error) {
-	// Call the repository's GetLoans method
-	loans, err := u.RepositoryInterface.GetLoans()
+func (u Usecase) GetLoans(ctx context.Context, query model.LoanQuery) (information model.LoanInformationPage, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.GetLoans")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Every role may browse loans
+	if _, err := authorize(ctx, auth.PermissionReadLoan); err != nil {
+		return model.LoanInformationPage{}, err
+	}
+
+	// Validate the query and apply the page size limits
+	err = validateLoanQuery(&query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get loans from repository: %w", err)
+		return model.LoanInformationPage{}, err
 	}
 
-	var loanInformations []model.LoanInformation = make([]model.LoanInformation, 0)
+	// Call the repository's QueryLoans method
+	page, err := u.RepositoryInterface.QueryLoans(ctx, query)
+	if err != nil {
+		return model.LoanInformationPage{}, fmt.Errorf("failed to query loans from repository: %w", err)
+	}
 
-	for _, loan := range loans {
-		loanInformations = append(loanInformations, model.LoanInformation{
-			LoanID:             loan.LoanID,
-			BorrowerID:         loan.BorrowerID,
-			PrincipalAmount:    loan.PrincipalAmount,
-			Rate:               loan.Rate,
-			ROI:                loan.ROI,
-			AgreementLetterURL: loan.AgreementLetterURL,
-		})
+	var loanInformations []model.LoanInformation = make([]model.LoanInformation, 0, len(page.Loans))
+
+	for _, loan := range page.Loans {
+		loanInformations = append(loanInformations, loan.Information())
 	}
 
-	return loanInformations, nil
+	return model.LoanInformationPage{
+		Loans:      loanInformations,
+		NextCursor: page.NextCursor,
+		TotalCount: page.TotalCount,
+	}, nil
 }
 
-func (u Usecase) GetLoan(loanID int64) (model.LoanInformation, error) {
+func (u Usecase) GetLoan(ctx context.Context, loanID int64) (info model.LoanInformation, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.GetLoan", tracing.Int64("loan_id", loanID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Every role may view a loan
+	if _, err := authorize(ctx, auth.PermissionReadLoan); err != nil {
+		return model.LoanInformation{}, err
+	}
+
 	// Call the repository's GetLoan method
-	loan, err := u.RepositoryInterface.GetLoan(loanID)
+	loan, err := u.RepositoryInterface.GetLoan(ctx, loanID)
 	if err != nil {
 		return model.LoanInformation{}, fmt.Errorf("failed to get loan from repository: %w", err)
 	}
 
 	// Return if the loan is not found
 	if loan.LoanID == 0 {
-		return model.LoanInformation{}, errors.New("loan not found")
+		return model.LoanInformation{}, model.ErrLoanNotFound
 	}
 
-	return model.LoanInformation{
-		LoanID:             loan.LoanID,
-		BorrowerID:         loan.BorrowerID,
-		PrincipalAmount:    loan.PrincipalAmount,
-		Rate:               loan.Rate,
-		ROI:                loan.ROI,
-		AgreementLetterURL: loan.AgreementLetterURL,
-	}, nil
+	return loan.Information(), nil
 }
 
-func (u Usecase) Approve(loanID int64, pictureProofURL string, fieldValidatorID int64) error {
+func (u Usecase) Approve(ctx context.Context, loanID int64, pictureProofURL string, fieldValidatorID int64) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.Approve", tracing.Int64("loan_id", loanID), tracing.Int64("actor_id", fieldValidatorID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Keep failed attempts in the audit log as well
+	defer func() {
+		if err != nil {
+			u.recordRejection(ctx, "Approve", loanID, err)
+		}
+	}()
+
+	// Only field validators may approve loans
+	if _, err := authorize(ctx, auth.PermissionApproveLoan); err != nil {
+		return err
+	}
+
 	// Check if any of the approval info fields are empty
 	if pictureProofURL == "" || fieldValidatorID == 0 {
 		return errors.New("approval info is incomplete")
 	}
 
+	// Hold the loan until the change is stored, concurrent commands on it wait
+	defer u.locks.lock(loanID)()
+
 	// Retrieve the loan from the repository
-	loan, err := u.RepositoryInterface.GetLoan(loanID)
+	loan, err := u.RepositoryInterface.GetLoan(ctx, loanID)
 	if err != nil {
 		return fmt.Errorf("failed to get loan: %w", err)
 	}
 
 	// Return if the loan is not found
 	if loan.LoanID == 0 {
-		return errors.New("loan not found")
+		return model.ErrLoanNotFound
 	}
 
 	// Reject if the state of the loan is beyond approved
 	if loan.State != model.StateEnumProposed {
-		return errors.New("loan is already approved, invested, or disbursed")
+		return fmt.Errorf("%w: loan is already approved, invested, or disbursed", model.ErrInvalidState)
+	}
+
+	// The picture proof must have been uploaded for this loan
+	err = u.requireDocument(ctx, loanID, model.DocumentPictureProof, pictureProofURL)
+	if err != nil {
+		return err
 	}
 
 	// Update the loan's approval info and state
+	previous := loan
 	loan.ApprovalInfo = model.ApprovalInfo{
 		PictureProofURL:  pictureProofURL,
 		FieldValidatorID: fieldValidatorID,
 		ApprovalDate:     time.Now(),
 	}
+	fromState := loan.State
 	loan.State = model.StateEnumApproved
 
-	// Update the loan in the repository
-	err = u.RepositoryInterface.UpdateLoan(loan)
+	// Update the loan in the repository and record the approval in the audit log
+	err = u.commitLoan(ctx, previous, loan, u.newEvent(ctx, loanID, model.EventLoanApproved, fieldValidatorID, loan.ApprovalInfo))
 	if err != nil {
-		return fmt.Errorf("failed to update loan: %w", err)
+		return err
 	}
 
+	observeTransition(fromState.String(), loan.State)
+
+	slog.InfoContext(ctx, "loan approved",
+		"loan_id", loanID,
+		"actor_id", fieldValidatorID,
+		"from_state", fromState.String(),
+		"to_state", loan.State.String(),
+	)
+
 	// Return success
 	return nil
 }
 
-func (u Usecase) Invest(loanID int64, investment model.Investment) error {
+func (u Usecase) Invest(ctx context.Context, loanID int64, investment model.Investment) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.Invest", tracing.Int64("loan_id", loanID), tracing.Int64("actor_id", investment.InvestorID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Keep failed attempts in the audit log as well
+	defer func() {
+		if err != nil {
+			u.recordRejection(ctx, "Invest", loanID, err)
+		}
+	}()
+
+	// Only investors may invest
+	if _, err := authorize(ctx, auth.PermissionInvestLoan); err != nil {
+		return err
+	}
+
 	// Validate that the investment details are complete
 	if investment.InvestorID == 0 || investment.InvestedAmount <= 0 {
 		return errors.New("invalid investment details")
 	}
+	// Amounts are kept in cents, a fraction of one would leave the loan short
+	// of its principal for good
+	if model.RoundAmount(investment.InvestedAmount) != investment.InvestedAmount {
+		return errors.New("invested amount must be in whole cents")
+	}
+
+	// Hold the loan until the change is stored, concurrent commands on it wait
+	defer u.locks.lock(loanID)()
 
 	// Retrieve the loan from the repository
-	loan, err := u.RepositoryInterface.GetLoan(loanID)
+	loan, err := u.RepositoryInterface.GetLoan(ctx, loanID)
 	if err != nil {
 		return fmt.Errorf("failed to get loan: %w", err)
 	}
 
 	// Return if the loan is not found
 	if loan.LoanID == 0 {
-		return errors.New("loan not found")
+		return model.ErrLoanNotFound
 	}
 
 	// Ensure the loan can only be invested in if its state is approved
 	if loan.State != model.StateEnumApproved {
-		return errors.New("loan is not in approved state")
+		return fmt.Errorf("%w: loan is not in approved state", model.ErrInvalidState)
+	}
+
+	// Loans stop taking investments once their funding period is over
+	if u.fundingExpired(loan, time.Now()) {
+		return fmt.Errorf("%w: funding period of the loan is over", model.ErrInvalidState)
 	}
 
-	// Calculate the total invested amount
+	// Calculate the total invested amount, rounded so that sums of cents
+	// compare exactly with the principal
 	totalInvestedAmount := investment.InvestedAmount
 	for _, inv := range loan.Investments {
 		totalInvestedAmount += inv.InvestedAmount
 	}
+	totalInvestedAmount = model.RoundAmount(totalInvestedAmount)
 
 	// Ensure the total invested amount does not exceed the loan principal amount
 	if totalInvestedAmount > loan.PrincipalAmount {
-		return errors.New("total invested amount exceeds principal amount")
+		return fmt.Errorf("%w: total invested amount exceeds principal amount", model.ErrInvalidState)
+	}
+
+	// Reject investments below the configured minimum, unless they close the loan
+	if investment.InvestedAmount < u.limits.MinInvestmentAmount && totalInvestedAmount != loan.PrincipalAmount {
+		return fmt.Errorf("invested amount must be at least %v", u.limits.MinInvestmentAmount)
+	}
+
+	// Give the funds back when the investment is not recorded after all
+	var posted postings
+	defer func() {
+		if err != nil {
+			u.reverse(ctx, posted)
+		}
+	}()
+
+	// Hold the amount in the investor's wallet, the investment fails without the funds
+	reserve := u.newWalletEntry(ctx, investment.InvestorID, loanID, model.WalletEntryReserve, investment.InvestedAmount)
+	err = u.postWalletEntries(ctx, &posted, reserve)
+	if err != nil {
+		return err
+	}
+
+	// Set the cash backing the investment aside for the loan
+	err = u.postJournal(ctx, &posted, ledger.Invest(loanID, reserve.Amount))
+	if err != nil {
+		return err
 	}
 
 	// Update the investments of the loan
+	previous := loan
 	loan.Investments = append(loan.Investments, investment)
 
 	// If the total invested amount matches the principal amount, update the loan's status
+	fromState := loan.State
 	if totalInvestedAmount == loan.PrincipalAmount {
 		loan.State = model.StateEnumInvested
 
@@ -185,45 +460,111 @@ func (u Usecase) Invest(loanID int64, investment model.Investment) error {
 
 		// Send agreement letters to investors using a message queue service (NSQ)
 		for _, inv := range loan.Investments {
-			err = u.RepositoryInterface.Publish(loan.LoanID, inv)
+			err = u.RepositoryInterface.Publish(ctx, loan.LoanID, inv)
 			if err != nil {
 				return fmt.Errorf("failed to publish agreement letter: %w", err)
 			}
 		}
 	}
 
-	// Update the loan in the cache
-	err = u.RepositoryInterface.UpdateLoan(loan)
+	// Record the investment, and the loan being fully funded, in the audit log
+	events := []model.Event{u.newEvent(ctx, loanID, model.EventInvestmentRecorded, investment.InvestorID, investment)}
+	if loan.State != fromState {
+		events = append(events, u.newEvent(ctx, loanID, model.EventLoanInvested, investment.InvestorID, model.LoanInvestedPayload{
+			AgreementLetterURL: loan.AgreementLetterURL,
+		}))
+	}
+
+	// Update the loan in the cache along with its events
+	err = u.commitLoan(ctx, previous, loan, events...)
 	if err != nil {
-		return fmt.Errorf("failed to update loan: %w", err)
+		return err
 	}
 
+	loanAmounts.WithLabelValues("invested").Add(investment.InvestedAmount)
+	if loan.State != fromState {
+		observeTransition(fromState.String(), loan.State)
+	}
+
+	slog.InfoContext(ctx, "investment recorded",
+		"loan_id", loanID,
+		"actor_id", investment.InvestorID,
+		"invested_amount", investment.InvestedAmount,
+		"total_invested_amount", totalInvestedAmount,
+		"from_state", fromState.String(),
+		"to_state", loan.State.String(),
+	)
+
 	return nil
 }
 
-func (u Usecase) Disburse(loanID int64, signedAgreementLetterURL string, fieldOfficerID int64) error {
+func (u Usecase) Disburse(ctx context.Context, loanID int64, signedAgreementLetterURL string, fieldOfficerID int64) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.Disburse", tracing.Int64("loan_id", loanID), tracing.Int64("actor_id", fieldOfficerID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Keep failed attempts in the audit log as well
+	defer func() {
+		if err != nil {
+			u.recordRejection(ctx, "Disburse", loanID, err)
+		}
+	}()
+
+	// Only field officers may disburse loans
+	if _, err := authorize(ctx, auth.PermissionDisburseLoan); err != nil {
+		return err
+	}
+
 	// Check if agreement letter URL or field officer ID is empty
 	if signedAgreementLetterURL == "" || fieldOfficerID == 0 {
 		return errors.New("agreement letter URL or field officer ID is empty")
 	}
 
+	// Hold the loan until the change is stored, concurrent commands on it wait
+	defer u.locks.lock(loanID)()
+
 	// Retrieve the loan from the repository
-	loan, err := u.RepositoryInterface.GetLoan(loanID)
+	loan, err := u.RepositoryInterface.GetLoan(ctx, loanID)
 	if err != nil {
 		return fmt.Errorf("failed to get loan: %w", err)
 	}
 
 	// Return error if loan not found
 	if loan.LoanID == 0 {
-		return errors.New("loan not found")
+		return model.ErrLoanNotFound
 	}
 
 	// Loan can only be disbursed if its status is StateEnumInvested
 	if loan.State != model.StateEnumInvested {
-		return errors.New("loan can only be disbursed if status is invested")
+		return fmt.Errorf("%w: loan can only be disbursed if status is invested", model.ErrInvalidState)
+	}
+
+	// The signed agreement letter must have been uploaded for this loan
+	err = u.requireDocument(ctx, loanID, model.DocumentSignedAgreementLetter, signedAgreementLetterURL)
+	if err != nil {
+		return err
+	}
+
+	// Put the reserved funds back when the disbursement is not recorded after all
+	var posted postings
+	defer func() {
+		if err != nil {
+			u.reverse(ctx, posted)
+		}
+	}()
+
+	// The reserved funds of every investor go to the borrower, less the
+	// origination fee the platform withholds
+	originationFee, err := u.disburse(ctx, &posted, loan)
+	if err != nil {
+		return err
 	}
 
 	// Update status of loan to StateEnumDisbursed
+	previous := loan
+	fromState := loan.State
 	loan.State = model.StateEnumDisbursed
 
 	// Update disbursement info of the loan
@@ -231,23 +572,148 @@ func (u Usecase) Disburse(loanID int64, signedAgreementLetterURL string, fieldOf
 		SignedAgreementLetterURL: signedAgreementLetterURL,
 		FieldOfficerID:           fieldOfficerID,
 		DisbursementDate:         time.Now(),
+		OriginationFee:           originationFee,
 	}
 
-	// Update loan in the cache
-	err = u.RepositoryInterface.UpdateLoan(loan)
+	// Update loan in the cache and record the disbursement in the audit log
+	err = u.commitLoan(ctx, previous, loan, u.newEvent(ctx, loanID, model.EventLoanDisbursed, fieldOfficerID, loan.DisbursementInfo))
 	if err != nil {
-		return fmt.Errorf("failed to update loan: %w", err)
+		return err
 	}
 
+	observeTransition(fromState.String(), loan.State)
+	loanAmounts.WithLabelValues("disbursed").Add(loan.PrincipalAmount)
+
+	slog.InfoContext(ctx, "loan disbursed",
+		"loan_id", loanID,
+		"actor_id", fieldOfficerID,
+		"from_state", fromState.String(),
+		"to_state", loan.State.String(),
+	)
+
 	return nil
 }
 
-func (u Usecase) AdminViewLoans() ([]model.Loan, error) {
+func (u Usecase) Cancel(ctx context.Context, loanID int64, reason string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.Cancel", tracing.Int64("loan_id", loanID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Keep failed attempts in the audit log as well
+	defer func() {
+		if err != nil {
+			u.recordRejection(ctx, "Cancel", loanID, err)
+		}
+	}()
+
+	// Borrowers and admins may cancel loans
+	principal, err := authorize(ctx, auth.PermissionCancelLoan)
+	if err != nil {
+		return err
+	}
+
+	// Hold the loan until the change is stored, concurrent commands on it wait
+	defer u.locks.lock(loanID)()
+
+	// Retrieve the loan from the repository
+	loan, err := u.RepositoryInterface.GetLoan(ctx, loanID)
+	if err != nil {
+		return fmt.Errorf("failed to get loan: %w", err)
+	}
+
+	// Return error if loan not found
+	if loan.LoanID == 0 {
+		return model.ErrLoanNotFound
+	}
+
+	// Only the owning borrower may cancel, unless the caller is an admin
+	if !principal.HasRole(auth.RoleAdmin) && loan.BorrowerID != principal.ActorID {
+		return fmt.Errorf("%w: loan belongs to another borrower", ErrForbidden)
+	}
+
+	// Loans can only be cancelled before they are fully funded
+	if loan.State != model.StateEnumProposed && loan.State != model.StateEnumApproved {
+		return fmt.Errorf("%w: loan can only be cancelled while proposed or approved", model.ErrInvalidState)
+	}
+
+	return u.cancelLoan(ctx, loan, model.CancellationInfo{
+		Reason:           reason,
+		CancelledBy:      principal.ActorID,
+		CancellationDate: time.Now(),
+	})
+}
+
+// cancelLoan releases the funds reserved by the investors of the loan and
+// records its cancellation. The caller holds the lock of the loan.
+func (u Usecase) cancelLoan(ctx context.Context, loan model.Loan, info model.CancellationInfo) (err error) {
+	// Reserve the funds again when the cancellation is not recorded after all
+	var posted postings
+	defer func() {
+		if err != nil {
+			u.reverse(ctx, posted)
+		}
+	}()
+
+	// Investors get their reserved funds back
+	err = u.settle(ctx, &posted, loan, model.WalletEntryRelease)
+	if err != nil {
+		return err
+	}
+
+	// Update status of loan to StateEnumCancelled
+	previous := loan
+	fromState := loan.State
+	loan.State = model.StateEnumCancelled
+	loan.CancellationInfo = info
+
+	// Update loan in the cache and record the cancellation in the audit log
+	err = u.commitLoan(ctx, previous, loan, u.newEvent(ctx, loan.LoanID, model.EventLoanCancelled, info.CancelledBy, loan.CancellationInfo))
+	if err != nil {
+		return err
+	}
+
+	observeTransition(fromState.String(), loan.State)
+
+	slog.InfoContext(ctx, "loan cancelled",
+		"loan_id", loan.LoanID,
+		"actor_id", info.CancelledBy,
+		"from_state", fromState.String(),
+		"to_state", loan.State.String(),
+		"reason", info.Reason,
+	)
+
+	return nil
+}
+
+func (u Usecase) AdminViewLoans(ctx context.Context) (loans []model.Loan, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.AdminViewLoans")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Only admins may see the full loan details
+	if _, err := authorize(ctx, auth.PermissionAdmin); err != nil {
+		return nil, err
+	}
+
 	// Call the repository's GetLoans method
-	loans, err := u.RepositoryInterface.GetLoans()
+	loans, err = u.RepositoryInterface.GetLoans(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get loans from repository: %w", err)
 	}
 
 	return loans, nil
 }
+
+func (u Usecase) AdminCacheStats(ctx context.Context) (stats inmemlib.Stats, err error) {
+	// Only admins may see the cache internals
+	if _, err := authorize(ctx, auth.PermissionAdmin); err != nil {
+		return inmemlib.Stats{}, err
+	}
+
+	// Call the repository's CacheStats method
+	return u.RepositoryInterface.CacheStats(ctx), nil
+}
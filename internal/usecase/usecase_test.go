@@ -0,0 +1,403 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/common/driver/blob"
+	httpdriver "github.com/timotiusas11/amartha-assignment/common/driver/http"
+	"github.com/timotiusas11/amartha-assignment/common/driver/inmemlib"
+	"github.com/timotiusas11/amartha-assignment/common/driver/nsq"
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+	"github.com/timotiusas11/amartha-assignment/internal/repository"
+)
+
+const (
+	testBorrowerID  = 101
+	testValidatorID = 201
+	testOfficerID   = 301
+	testAdminID     = 901
+)
+
+var testFees = FeeRules{
+	Default: model.FeeTerms{OriginationPercent: 1, ServicingSpreadPercent: 100, LateFeeSharePercent: 50},
+}
+
+// newTestUsecase returns a usecase over a real repository on a fresh cache,
+// with a KYC verified borrower registered.
+func newTestUsecase(t *testing.T) (Usecase, repository.Repository) {
+	t.Helper()
+	blobStore, err := blob.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	nsqClient := nsq.New("", 64)
+	t.Cleanup(func() { nsqClient.Stop(context.Background()) })
+
+	repo := repository.NewRepository(inmemlib.New(), nsqClient, httpdriver.New("", time.Second), blobStore)
+	err = repo.InsertBorrower(context.Background(), model.Borrower{
+		BorrowerID: testBorrowerID,
+		Name:       "Borrower",
+		KYC:        model.KYC{Status: model.KYCStatusVerified},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewUsecase(repo, Limits{
+		MinPrincipalAmount:  1,
+		MaxRate:             100,
+		MaxROI:              100,
+		MinInvestmentAmount: 1,
+		MaxDocumentSize:     1 << 20,
+	}, testFees), repo
+}
+
+// as returns a context authenticated as the actor with the role.
+func as(role auth.Role, actorID int64) context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{ActorID: actorID, Roles: []string{string(role)}})
+}
+
+// createLoan proposes a loan of the test borrower.
+func createLoan(t *testing.T, u Usecase, principal float64) int64 {
+	t.Helper()
+	loanID, err := u.CreateLoan(as(auth.RoleBorrower, testBorrowerID), testBorrowerID, principal, 10, 8, "")
+	if err != nil {
+		t.Fatalf("CreateLoan() error = %v", err)
+	}
+	return loanID
+}
+
+// deposit tops up the wallet of the investor.
+func deposit(t *testing.T, u Usecase, investorID int64, amount float64) {
+	t.Helper()
+	if _, err := u.Deposit(as(auth.RoleInvestor, investorID), investorID, amount); err != nil {
+		t.Fatalf("Deposit() error = %v", err)
+	}
+}
+
+// approveLoan uploads a picture proof of the proposed loan and approves it.
+func approveLoan(t *testing.T, u Usecase, loanID int64) {
+	t.Helper()
+	ctx := as(auth.RoleFieldValidator, testValidatorID)
+	proof, err := u.UploadDocument(ctx, loanID, model.DocumentPictureProof, "proof.png", strings.NewReader("\x89PNG\r\n\x1a\n"))
+	if err != nil {
+		t.Fatalf("UploadDocument() error = %v", err)
+	}
+	if err := u.Approve(ctx, loanID, proof.URL, testValidatorID); err != nil {
+		t.Fatalf("Approve() error = %v", err)
+	}
+}
+
+// invest invests the amount of the investor in the loan.
+func invest(t *testing.T, u Usecase, loanID, investorID int64, amount float64) {
+	t.Helper()
+	err := u.Invest(as(auth.RoleInvestor, investorID), loanID, model.Investment{InvestorID: investorID, InvestedAmount: amount})
+	if err != nil {
+		t.Fatalf("Invest() error = %v", err)
+	}
+}
+
+// disburseLoan uploads a signed agreement letter of the invested loan and
+// disburses it.
+func disburseLoan(t *testing.T, u Usecase, loanID int64) {
+	t.Helper()
+	ctx := as(auth.RoleFieldOfficer, testOfficerID)
+	letter, err := u.UploadDocument(ctx, loanID, model.DocumentSignedAgreementLetter, "letter.pdf", strings.NewReader("%PDF-1.4"))
+	if err != nil {
+		t.Fatalf("UploadDocument() error = %v", err)
+	}
+	if err := u.Disburse(ctx, loanID, letter.URL, testOfficerID); err != nil {
+		t.Fatalf("Disburse() error = %v", err)
+	}
+}
+
+// checkBooks fails the test when the journal does not balance or does not
+// match the wallets.
+func checkBooks(t *testing.T, u Usecase) {
+	t.Helper()
+	balance, err := u.TrialBalance(as(auth.RoleAdmin, testAdminID))
+	if err != nil {
+		t.Fatalf("TrialBalance() error = %v", err)
+	}
+	if !balance.Balanced || !balance.Reconciled {
+		t.Errorf("TrialBalance() = %+v, want it balanced and reconciled", balance)
+	}
+}
+
+// failingRepository fails AppendEvents while fail is set, so commands fail
+// after posting their money moves.
+type failingRepository struct {
+	repository.RepositoryInterface
+	fail bool
+}
+
+func (r *failingRepository) AppendEvents(ctx context.Context, events ...model.Event) ([]model.Event, error) {
+	if r.fail {
+		return nil, errors.New("event log unavailable")
+	}
+	return r.RepositoryInterface.AppendEvents(ctx, events...)
+}
+
+func TestCreateLoanAmounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal float64
+		wantErr   bool
+	}{
+		{name: "whole amount", principal: 1000},
+		{name: "cents", principal: 1000.01},
+		{name: "fraction of a cent", principal: 1000.005, wantErr: true},
+		{name: "zero", principal: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, _ := newTestUsecase(t)
+			_, err := u.CreateLoan(as(auth.RoleBorrower, testBorrowerID), testBorrowerID, tt.principal, 10, 8, "")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CreateLoan(%v) error = %v, wantErr %v", tt.principal, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInvestAmounts(t *testing.T) {
+	const investorID = 7
+
+	tests := []struct {
+		name      string
+		principal float64
+		amounts   []float64 // Invested in order, the last one checked
+		wantErr   bool
+		wantState model.StateEnum
+	}{
+		{
+			name:      "cents",
+			principal: 100,
+			amounts:   []float64{33.33},
+			wantState: model.StateEnumApproved,
+		},
+		{
+			name:      "fraction of a cent",
+			principal: 100,
+			amounts:   []float64{0.005},
+			wantErr:   true,
+			wantState: model.StateEnumApproved,
+		},
+		{
+			name:      "thirds of the principal",
+			principal: 100,
+			amounts:   []float64{33.333},
+			wantErr:   true,
+			wantState: model.StateEnumApproved,
+		},
+		{
+			name:      "cents that do not sum exactly in floating point close the loan",
+			principal: 3.3,
+			amounts:   []float64{1.1, 2.2},
+			wantState: model.StateEnumInvested,
+		},
+		{
+			name:      "overfunding",
+			principal: 100,
+			amounts:   []float64{60, 40.01},
+			wantErr:   true,
+			wantState: model.StateEnumApproved,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, repo := newTestUsecase(t)
+			loanID := createLoan(t, u, tt.principal)
+			approveLoan(t, u, loanID)
+			deposit(t, u, investorID, 1000)
+
+			last := len(tt.amounts) - 1
+			for _, amount := range tt.amounts[:last] {
+				invest(t, u, loanID, investorID, amount)
+			}
+			err := u.Invest(as(auth.RoleInvestor, investorID), loanID, model.Investment{InvestorID: investorID, InvestedAmount: tt.amounts[last]})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Invest(%v) error = %v, wantErr %v", tt.amounts[last], err, tt.wantErr)
+			}
+
+			loan, err := repo.GetLoan(context.Background(), loanID)
+			if err != nil {
+				t.Fatalf("GetLoan() error = %v", err)
+			}
+			if loan.State != tt.wantState {
+				t.Errorf("loan state = %s, want %s", loan.State, tt.wantState)
+			}
+			checkBooks(t, u)
+		})
+	}
+}
+
+func TestMoneyFlow(t *testing.T) {
+	u, _ := newTestUsecase(t)
+	loanID := createLoan(t, u, 1000)
+	approveLoan(t, u, loanID)
+	deposit(t, u, 7, 500)
+	deposit(t, u, 8, 600)
+
+	steps := []struct {
+		name string
+		run  func(t *testing.T)
+		want map[int64]model.Wallet // By investor, accounts only
+	}{
+		{
+			name: "investments reserve the funds",
+			run: func(t *testing.T) {
+				invest(t, u, loanID, 7, 400)
+				invest(t, u, loanID, 8, 600)
+			},
+			want: map[int64]model.Wallet{
+				7: {Available: 100, Reserved: 400},
+				8: {Reserved: 600},
+			},
+		},
+		{
+			name: "disbursement captures them",
+			run:  func(t *testing.T) { disburseLoan(t, u, loanID) },
+			want: map[int64]model.Wallet{
+				7: {Available: 100, Invested: 400},
+				8: {Invested: 600},
+			},
+		},
+		{
+			name: "a repayment pays out part of the principal and return",
+			run:  func(t *testing.T) { repay(t, u, loanID, 550) },
+			want: map[int64]model.Wallet{
+				7: {Available: 316, Invested: 200, Earned: 16},
+				8: {Available: 324, Invested: 300, Earned: 24},
+			},
+		},
+		{
+			name: "the last repayment pays out the rest",
+			run:  func(t *testing.T) { repay(t, u, loanID, 550) },
+			want: map[int64]model.Wallet{
+				7: {Available: 532, Earned: 32},
+				8: {Available: 648, Earned: 48},
+			},
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			step.run(t)
+			for investorID, want := range step.want {
+				checkWallet(t, u, investorID, want)
+			}
+			checkBooks(t, u)
+		})
+	}
+}
+
+func TestFailedCommitReversesMoney(t *testing.T) {
+	const investorID = 7
+
+	tests := []struct {
+		name string
+		// setup takes the loan to the state the command starts from
+		setup   func(t *testing.T, u Usecase, loanID int64)
+		command func(u Usecase, loanID int64) error
+		want    model.Wallet
+	}{
+		{
+			name:  "investment",
+			setup: func(t *testing.T, u Usecase, loanID int64) {},
+			command: func(u Usecase, loanID int64) error {
+				return u.Invest(as(auth.RoleInvestor, investorID), loanID, model.Investment{InvestorID: investorID, InvestedAmount: 1000})
+			},
+			want: model.Wallet{Available: 1000},
+		},
+		{
+			name: "disbursement",
+			setup: func(t *testing.T, u Usecase, loanID int64) {
+				invest(t, u, loanID, investorID, 1000)
+			},
+			command: func(u Usecase, loanID int64) error {
+				return u.AdminForceTransition(as(auth.RoleAdmin, testAdminID), loanID, model.StateEnumDisbursed, "paid out by hand")
+			},
+			want: model.Wallet{Reserved: 1000},
+		},
+		{
+			name: "cancellation",
+			setup: func(t *testing.T, u Usecase, loanID int64) {
+				invest(t, u, loanID, investorID, 400)
+			},
+			command: func(u Usecase, loanID int64) error {
+				return u.Cancel(as(auth.RoleAdmin, testAdminID), loanID, "withdrawn")
+			},
+			want: model.Wallet{Available: 600, Reserved: 400},
+		},
+		{
+			name: "repayment",
+			setup: func(t *testing.T, u Usecase, loanID int64) {
+				invest(t, u, loanID, investorID, 1000)
+				disburseLoan(t, u, loanID)
+			},
+			command: func(u Usecase, loanID int64) error {
+				_, err := u.Repay(as(auth.RoleBorrower, testBorrowerID), loanID, 1100, 0)
+				return err
+			},
+			want: model.Wallet{Invested: 1000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, repo := newTestUsecase(t)
+			failing := &failingRepository{RepositoryInterface: repo}
+			u := NewUsecase(failing, base.limits, base.fees)
+
+			loanID := createLoan(t, u, 1000)
+			approveLoan(t, u, loanID)
+			deposit(t, u, investorID, 1000)
+			tt.setup(t, u, loanID)
+			before, err := repo.GetLoan(context.Background(), loanID)
+			if err != nil {
+				t.Fatalf("GetLoan() error = %v", err)
+			}
+
+			failing.fail = true
+			if err := tt.command(u, loanID); err == nil {
+				t.Fatal("command succeeded, want the failed commit to fail it")
+			}
+			failing.fail = false
+
+			after, err := repo.GetLoan(context.Background(), loanID)
+			if err != nil {
+				t.Fatalf("GetLoan() error = %v", err)
+			}
+			if after.State != before.State || len(after.Investments) != len(before.Investments) || len(after.Repayments) != len(before.Repayments) {
+				t.Errorf("loan after the failed command = %+v, want it unchanged from %+v", after, before)
+			}
+			checkWallet(t, u, investorID, tt.want)
+			checkBooks(t, u)
+		})
+	}
+}
+
+// repay repays the amount of the loan as its borrower.
+func repay(t *testing.T, u Usecase, loanID int64, amount float64) {
+	t.Helper()
+	if _, err := u.Repay(as(auth.RoleBorrower, testBorrowerID), loanID, amount, 0); err != nil {
+		t.Fatalf("Repay() error = %v", err)
+	}
+}
+
+// checkWallet compares the accounts and earnings of the wallet of the investor
+// with want.
+func checkWallet(t *testing.T, u Usecase, investorID int64, want model.Wallet) {
+	t.Helper()
+	wallet, err := u.GetWallet(as(auth.RoleInvestor, investorID), investorID)
+	if err != nil {
+		t.Fatalf("GetWallet() error = %v", err)
+	}
+	if wallet.Available != want.Available || wallet.Reserved != want.Reserved || wallet.Invested != want.Invested || wallet.Earned != want.Earned {
+		t.Errorf("wallet of investor %d = %+v, want %+v", investorID, wallet, want)
+	}
+}
@@ -0,0 +1,235 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/timotiusas11/amartha-assignment/common/logger"
+	"github.com/timotiusas11/amartha-assignment/common/tracing"
+	"github.com/timotiusas11/amartha-assignment/internal/auth"
+	"github.com/timotiusas11/amartha-assignment/internal/ledger"
+	"github.com/timotiusas11/amartha-assignment/internal/model"
+)
+
+func (u Usecase) Deposit(ctx context.Context, investorID int64, amount float64) (wallet model.Wallet, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.Deposit", tracing.Int64("investor_id", investorID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Investors top up their own wallet, admins any wallet
+	principal, err := authorize(ctx, auth.PermissionDepositWallet)
+	if err != nil {
+		return model.Wallet{}, err
+	}
+	err = authorizeInvestor(principal, investorID)
+	if err != nil {
+		return model.Wallet{}, err
+	}
+
+	// Amounts are kept in cents
+	if model.RoundAmount(amount) <= 0 {
+		return model.Wallet{}, errors.New("deposit amount must be at least 0.01")
+	}
+
+	var posted postings
+	err = u.postWalletEntries(ctx, &posted, u.newWalletEntry(ctx, investorID, 0, model.WalletEntryDeposit, amount))
+	if err != nil {
+		return model.Wallet{}, err
+	}
+
+	// Take the deposit back when it cannot be booked
+	err = u.postJournal(ctx, &posted, ledger.Deposit(investorID, amount))
+	if err != nil {
+		u.reverse(ctx, posted)
+		return model.Wallet{}, err
+	}
+
+	slog.InfoContext(ctx, "wallet deposit",
+		"investor_id", investorID,
+		"actor_id", principal.ActorID,
+		"amount", model.RoundAmount(amount),
+	)
+
+	// Call the repository's GetWallet method
+	wallet, err = u.RepositoryInterface.GetWallet(ctx, investorID)
+	if err != nil {
+		return model.Wallet{}, fmt.Errorf("failed to get wallet from repository: %w", err)
+	}
+
+	return wallet, nil
+}
+
+func (u Usecase) GetWallet(ctx context.Context, investorID int64) (wallet model.Wallet, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.GetWallet", tracing.Int64("investor_id", investorID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Investors see their own wallet, admins every wallet
+	principal, err := authorize(ctx, auth.PermissionReadWallet)
+	if err != nil {
+		return model.Wallet{}, err
+	}
+	err = authorizeInvestor(principal, investorID)
+	if err != nil {
+		return model.Wallet{}, err
+	}
+
+	// Call the repository's GetWallet method
+	wallet, err = u.RepositoryInterface.GetWallet(ctx, investorID)
+	if err != nil {
+		return model.Wallet{}, fmt.Errorf("failed to get wallet from repository: %w", err)
+	}
+
+	// An investor without entries has an empty wallet
+	wallet.InvestorID = investorID
+	return wallet, nil
+}
+
+func (u Usecase) GetWalletEntries(ctx context.Context, investorID int64, query model.WalletEntryQuery) (page model.WalletEntryPage, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.GetWalletEntries", tracing.Int64("investor_id", investorID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Investors see their own entries, admins every investor's
+	principal, err := authorize(ctx, auth.PermissionReadWallet)
+	if err != nil {
+		return model.WalletEntryPage{}, err
+	}
+	err = authorizeInvestor(principal, investorID)
+	if err != nil {
+		return model.WalletEntryPage{}, err
+	}
+
+	// Apply the page size limits of the event log
+	query.InvestorID = investorID
+	switch {
+	case query.Limit < 0:
+		return model.WalletEntryPage{}, fmt.Errorf("%w: limit must not be negative", model.ErrInvalidQuery)
+	case query.Limit == 0:
+		query.Limit = DefaultEventPageSize
+	case query.Limit > MaxEventPageSize:
+		query.Limit = MaxEventPageSize
+	}
+
+	// Call the repository's ListWalletEntries method
+	page, err = u.RepositoryInterface.ListWalletEntries(ctx, query)
+	if err != nil {
+		return model.WalletEntryPage{}, fmt.Errorf("failed to list wallet entries from repository: %w", err)
+	}
+
+	return page, nil
+}
+
+func (u Usecase) AdminWallets(ctx context.Context) (wallets []model.Wallet, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.AdminWallets")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Only admins may see every wallet
+	if _, err := authorize(ctx, auth.PermissionAdmin); err != nil {
+		return nil, err
+	}
+
+	// Call the repository's GetWallets method
+	wallets, err = u.RepositoryInterface.GetWallets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallets from repository: %w", err)
+	}
+
+	return wallets, nil
+}
+
+// authorizeInvestor lets admins through for every wallet, investors only for
+// their own.
+func authorizeInvestor(principal auth.Principal, investorID int64) error {
+	if !principal.Can(auth.PermissionAdmin) && investorID != principal.ActorID {
+		return fmt.Errorf("%w: not the investor", ErrForbidden)
+	}
+	return nil
+}
+
+func (u Usecase) newWalletEntry(ctx context.Context, investorID, loanID int64, entryType model.WalletEntryType, amount float64) model.WalletEntry {
+	return stampWalletEntry(ctx, model.NewWalletEntry(investorID, loanID, entryType, amount))
+}
+
+// stampWalletEntry sets the caller and the request on the entry.
+func stampWalletEntry(ctx context.Context, entry model.WalletEntry) model.WalletEntry {
+	principal, _ := auth.PrincipalFromContext(ctx)
+	entry.ActorID = principal.ActorID
+	entry.OccurredAt = time.Now()
+	entry.RequestID = logger.RequestID(ctx)
+	return entry
+}
+
+// postWalletEntries moves money in the wallets and adds the entries to posted,
+// so the command can take them back when a later step fails. A rejected entry
+// posts none of them. Commands post before recording their events, so a loan
+// never changes without its money moving.
+func (u Usecase) postWalletEntries(ctx context.Context, posted *postings, entries ...model.WalletEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	written, err := u.RepositoryInterface.PostWalletEntries(ctx, entries...)
+	if errors.Is(err, model.ErrInsufficientFunds) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to post wallet entries: %w", err)
+	}
+	posted.wallet = append(posted.wallet, written...)
+	return nil
+}
+
+// investmentEntries returns an entry of entryType per investor of the loan,
+// for the sum of their investments rounded to cents. Cancelling releases the
+// reserved funds, disbursing captures them.
+func (u Usecase) investmentEntries(ctx context.Context, loan model.Loan, entryType model.WalletEntryType) []model.WalletEntry {
+	var (
+		order   []int64
+		amounts = make(map[int64]float64)
+	)
+	for _, inv := range loan.Investments {
+		if _, ok := amounts[inv.InvestorID]; !ok {
+			order = append(order, inv.InvestorID)
+		}
+		amounts[inv.InvestorID] += inv.InvestedAmount
+	}
+
+	entries := make([]model.WalletEntry, 0, len(order))
+	for _, investorID := range order {
+		entries = append(entries, u.newWalletEntry(ctx, investorID, loan.LoanID, entryType, amounts[investorID]))
+	}
+	return entries
+}
+
+// settledStates are the states in which the funds reserved for a loan were
+// already released to the investors or lent to the borrower. Forcing a loan
+// out of them would need that money back, which no wallet entry does, so it
+// is refused.
+var settledStates = []model.StateEnum{model.StateEnumCancelled, model.StateEnumDisbursed}
+
+// settlementEntry is the wallet movement a forced transition implies: funds
+// reserved for the loan are released when it is cancelled and captured when it
+// is disbursed. Other transitions leave the wallets alone.
+func settlementEntry(from, to model.StateEnum) (model.WalletEntryType, bool) {
+	reserved := from == model.StateEnumApproved || from == model.StateEnumInvested
+	switch {
+	case reserved && to == model.StateEnumCancelled:
+		return model.WalletEntryRelease, true
+	case reserved && to == model.StateEnumDisbursed:
+		return model.WalletEntryCapture, true
+	default:
+		return "", false
+	}
+}